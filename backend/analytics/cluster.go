@@ -0,0 +1,58 @@
+package analytics
+
+import "math"
+
+// ClusterOrder orders the labels of a correlation matrix by average linkage
+// hierarchical clustering on the distance sqrt((1 - ρ) / 2), so correlated
+// symbols end up next to each other in a heatmap.
+func ClusterOrder(labels []string, corr [][]float64) []string {
+	n := len(labels)
+	if n == 0 {
+		return []string{}
+	}
+
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+		for j := range dist[i] {
+			dist[i][j] = math.Sqrt(math.Max(0, (1-corr[i][j])/2))
+		}
+	}
+
+	// Each cluster keeps its members in dendrogram leaf order.
+	clusters := make([][]int, n)
+	for i := range clusters {
+		clusters[i] = []int{i}
+	}
+
+	for len(clusters) > 1 {
+		bestA, bestB, best := 0, 1, math.Inf(1)
+		for a := 0; a < len(clusters); a++ {
+			for b := a + 1; b < len(clusters); b++ {
+				if d := linkage(clusters[a], clusters[b], dist); d < best {
+					bestA, bestB, best = a, b, d
+				}
+			}
+		}
+
+		merged := append(append([]int{}, clusters[bestA]...), clusters[bestB]...)
+		clusters[bestA] = merged
+		clusters = append(clusters[:bestB], clusters[bestB+1:]...)
+	}
+
+	order := make([]string, n)
+	for i, idx := range clusters[0] {
+		order[i] = labels[idx]
+	}
+	return order
+}
+
+func linkage(a, b []int, dist [][]float64) float64 {
+	sum := 0.0
+	for _, i := range a {
+		for _, j := range b {
+			sum += dist[i][j]
+		}
+	}
+	return sum / float64(len(a)*len(b))
+}
@@ -0,0 +1,298 @@
+package analytics
+
+import (
+	"cropto-dashboard/exchange"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxSymbols bounds the symbols of one request, each costs an upstream
+// request and clustering grows with the cube of the count.
+const MaxSymbols = 30
+
+// ErrUpstream wraps failures to load candles, as opposed to bad requests.
+var ErrUpstream = errors.New("failed to load candles")
+
+type CorrelationRequest struct {
+	Symbols   []string
+	Interval  string
+	Window    int // number of returns the matrix is computed over
+	Benchmark string
+	Rolling   int // rolling correlation points against the benchmark, 0 for none
+}
+
+type RollingPoint struct {
+	Time        int64   `json:"time"`
+	Correlation float64 `json:"correlation"`
+}
+
+type CorrelationReport struct {
+	Interval  string   `json:"interval"`
+	Window    int      `json:"window"`
+	Benchmark string   `json:"benchmark"`
+	Symbols   []string `json:"symbols"`
+	Missing   []string `json:"missing"`
+	// Matrices are indexed like Symbols.
+	Pearson  [][]float64               `json:"pearson"`
+	Spearman [][]float64               `json:"spearman"`
+	Beta     map[string]float64        `json:"beta"`
+	Order    []string                  `json:"clusterOrder"`
+	Rolling  map[string][]RollingPoint `json:"rolling,omitempty"`
+	Start    int64                     `json:"start"`
+	End      int64                     `json:"end"`
+
+	GeneratedAt int64 `json:"generatedAt"`
+	Cached      bool  `json:"cached"`
+}
+
+// Fetcher loads candles for several symbols, exchange.GetMultipleHistoricalData by default.
+type Fetcher func(symbols []string, interval string, limit int) (map[string]*exchange.ChartData, error)
+
+type correlationEntry struct {
+	report  *CorrelationReport
+	expires time.Time
+}
+
+// Correlations computes correlation reports and caches them for TTL.
+type Correlations struct {
+	TTL   time.Duration
+	Fetch Fetcher
+
+	mutex sync.Mutex
+	cache map[string]correlationEntry
+}
+
+func NewCorrelations(ttl time.Duration) *Correlations {
+	return &Correlations{
+		TTL:   ttl,
+		Fetch: exchange.GetMultipleHistoricalData,
+		cache: make(map[string]correlationEntry),
+	}
+}
+
+func (r *CorrelationRequest) normalize() error {
+	if r.Interval == "" {
+		r.Interval = "1d"
+	}
+	if exchange.IntervalDuration(r.Interval) <= 0 {
+		return fmt.Errorf("invalid interval %q", r.Interval)
+	}
+	if r.Window == 0 {
+		r.Window = 30
+	}
+	if r.Window < 5 {
+		return fmt.Errorf("window must be at least 5")
+	}
+	if r.Rolling < 0 {
+		return fmt.Errorf("rolling cannot be negative")
+	}
+	if r.Window+r.Rolling+1 > 1000 {
+		return fmt.Errorf("window + rolling must stay below 1000 candles")
+	}
+	if r.Benchmark == "" {
+		r.Benchmark = "BTCUSDT"
+	}
+	r.Benchmark = strings.ToUpper(r.Benchmark)
+
+	seen := map[string]bool{}
+	symbols := []string{}
+	for _, s := range append([]string{r.Benchmark}, r.Symbols...) {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) < 2 {
+		return fmt.Errorf("need at least two symbols")
+	}
+	if len(symbols) > MaxSymbols {
+		return fmt.Errorf("at most %d symbols, benchmark included", MaxSymbols)
+	}
+	r.Symbols = symbols
+	return nil
+}
+
+func (r CorrelationRequest) key() string {
+	sorted := append([]string(nil), r.Symbols...)
+	sort.Strings(sorted)
+	return fmt.Sprintf("%s|%d|%s|%d|%s", r.Interval, r.Window, r.Benchmark, r.Rolling, strings.Join(sorted, ","))
+}
+
+// Compute returns the report for req, from the cache when it is fresh.
+func (c *Correlations) Compute(req CorrelationRequest) (*CorrelationReport, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	key := req.key()
+
+	c.mutex.Lock()
+	if entry, ok := c.cache[key]; ok && time.Now().Before(entry.expires) {
+		c.mutex.Unlock()
+		cached := *entry.report
+		cached.Cached = true
+		return &cached, nil
+	}
+	c.mutex.Unlock()
+
+	report, err := c.compute(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	now := time.Now()
+	for k, entry := range c.cache {
+		if now.After(entry.expires) {
+			delete(c.cache, k)
+		}
+	}
+	c.cache[key] = correlationEntry{report: report, expires: now.Add(c.TTL)}
+	c.mutex.Unlock()
+
+	return report, nil
+}
+
+func (c *Correlations) compute(req CorrelationRequest) (*CorrelationReport, error) {
+	limit := req.Window + req.Rolling + 1
+	data, err := c.Fetch(req.Symbols, req.Interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+
+	series := AlignCloses(req.Symbols, data)
+	if _, ok := series.Closes[req.Benchmark]; !ok {
+		return nil, fmt.Errorf("no candles for benchmark %s", req.Benchmark)
+	}
+	if len(series.Symbols) < 2 {
+		return nil, fmt.Errorf("need candles for at least two symbols")
+	}
+	if len(series.Times) < req.Window+1 {
+		return nil, fmt.Errorf("only %d aligned candles, window needs %d", len(series.Times), req.Window+1)
+	}
+
+	report := &CorrelationReport{
+		Interval:    req.Interval,
+		Window:      req.Window,
+		Benchmark:   req.Benchmark,
+		Symbols:     series.Symbols,
+		Missing:     series.Missing,
+		Beta:        make(map[string]float64),
+		GeneratedAt: time.Now().UnixMilli(),
+	}
+
+	returns := make(map[string][]float64, len(series.Symbols))
+	for _, s := range series.Symbols {
+		returns[s] = Returns(series.Closes[s])
+	}
+	times := series.Times[1:]
+
+	// The matrix covers the last Window returns.
+	n := len(times)
+	window := func(s string) []float64 { return returns[s][n-req.Window:] }
+	report.Start, report.End = times[n-req.Window], times[n-1]
+
+	size := len(series.Symbols)
+	report.Pearson = make([][]float64, size)
+	report.Spearman = make([][]float64, size)
+	for i, a := range series.Symbols {
+		report.Pearson[i] = make([]float64, size)
+		report.Spearman[i] = make([]float64, size)
+		for j, b := range series.Symbols {
+			if i == j {
+				report.Pearson[i][j], report.Spearman[i][j] = 1, 1
+				continue
+			}
+			if j < i {
+				report.Pearson[i][j], report.Spearman[i][j] = report.Pearson[j][i], report.Spearman[j][i]
+				continue
+			}
+			report.Pearson[i][j] = round(Pearson(window(a), window(b)), 4)
+			report.Spearman[i][j] = round(Spearman(window(a), window(b)), 4)
+		}
+		report.Beta[a] = round(Beta(window(a), window(req.Benchmark)), 4)
+	}
+	report.Order = ClusterOrder(series.Symbols, report.Pearson)
+
+	if req.Rolling > 0 {
+		report.Rolling = make(map[string][]RollingPoint)
+		bench := returns[req.Benchmark]
+		for _, s := range series.Symbols {
+			if s == req.Benchmark {
+				continue
+			}
+			points := []RollingPoint{}
+			for end := req.Window; end <= n; end++ {
+				points = append(points, RollingPoint{
+					Time:        times[end-1],
+					Correlation: round(Pearson(returns[s][end-req.Window:end], bench[end-req.Window:end]), 4),
+				})
+			}
+			report.Rolling[s] = points
+		}
+	}
+
+	return report, nil
+}
+
+// AlignedSeries holds closes of several symbols on the open times they all share.
+type AlignedSeries struct {
+	Symbols []string
+	Missing []string
+	Times   []int64
+	Closes  map[string][]float64
+}
+
+// AlignCloses keeps only candles whose open time every returned symbol has, so
+// returns are compared period for period.
+func AlignCloses(symbols []string, data map[string]*exchange.ChartData) AlignedSeries {
+	series := AlignedSeries{Missing: []string{}, Closes: make(map[string][]float64)}
+
+	counts := map[int64]int{}
+	for _, s := range symbols {
+		chart, ok := data[s]
+		if !ok || len(chart.Candlesticks) == 0 {
+			series.Missing = append(series.Missing, s)
+			continue
+		}
+		series.Symbols = append(series.Symbols, s)
+		for _, c := range chart.Candlesticks {
+			counts[c.OpenTime]++
+		}
+	}
+
+	for t, count := range counts {
+		if count == len(series.Symbols) {
+			series.Times = append(series.Times, t)
+		}
+	}
+	sort.Slice(series.Times, func(i, j int) bool { return series.Times[i] < series.Times[j] })
+
+	shared := make(map[int64]bool, len(series.Times))
+	for _, t := range series.Times {
+		shared[t] = true
+	}
+	for _, s := range series.Symbols {
+		closes := make([]float64, 0, len(series.Times))
+		for _, c := range data[s].Candlesticks {
+			if shared[c.OpenTime] {
+				closes = append(closes, c.Close.Float64())
+			}
+		}
+		series.Closes[s] = closes
+	}
+	return series
+}
+
+func round(v float64, places int) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
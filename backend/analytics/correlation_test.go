@@ -0,0 +1,37 @@
+package analytics
+
+import (
+	"cropto-dashboard/exchange"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCorrelationsRejectsTooManySymbols(t *testing.T) {
+	c := NewCorrelations(0)
+	c.Fetch = func([]string, string, int) (map[string]*exchange.ChartData, error) {
+		t.Fatal("fetched candles for a request over the limit")
+		return nil, nil
+	}
+
+	req := CorrelationRequest{}
+	for i := 0; i < MaxSymbols; i++ {
+		req.Symbols = append(req.Symbols, fmt.Sprintf("SYM%dUSDT", i))
+	}
+	_, err := c.Compute(req)
+	if err == nil || errors.Is(err, ErrUpstream) {
+		t.Fatalf("expected a request error, got %v", err)
+	}
+}
+
+func TestCorrelationsWrapsFetchErrors(t *testing.T) {
+	c := NewCorrelations(0)
+	c.Fetch = func([]string, string, int) (map[string]*exchange.ChartData, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	_, err := c.Compute(CorrelationRequest{Symbols: []string{"ETHUSDT"}})
+	if !errors.Is(err, ErrUpstream) {
+		t.Fatalf("expected ErrUpstream, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// HistoricalVaR returns the loss, as a positive fraction, that returns
+// exceeded with probability 1 - confidence, and the average loss beyond it
+// (CVaR, or expected shortfall).
+func HistoricalVaR(returns []float64, confidence float64) (float64, float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+
+	// The tail is the worst ceil(n × (1 - confidence)) returns, at least one.
+	tail := int(math.Ceil(float64(len(sorted)) * (1 - confidence)))
+	if tail < 1 {
+		tail = 1
+	}
+	if tail > len(sorted) {
+		tail = len(sorted)
+	}
+
+	cvar := -Mean(sorted[:tail])
+	return math.Max(0, -sorted[tail-1]), math.Max(0, cvar)
+}
+
+// Drawdown is the largest peak to trough fall of a price series.
+type Drawdown struct {
+	Depth  float64 `json:"depth"` // fraction of the peak, 0.25 is a 25% fall
+	Peak   int     `json:"-"`
+	Trough int     `json:"-"`
+}
+
+func MaxDrawdown(prices []float64) Drawdown {
+	dd := Drawdown{}
+	peak := 0
+	for i, p := range prices {
+		if p > prices[peak] {
+			peak = i
+		}
+		if prices[peak] <= 0 {
+			continue
+		}
+		if depth := 1 - p/prices[peak]; depth > dd.Depth {
+			dd = Drawdown{Depth: depth, Peak: peak, Trough: i}
+		}
+	}
+	return dd
+}
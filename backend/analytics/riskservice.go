@@ -0,0 +1,268 @@
+package analytics
+
+import (
+	"context"
+	"cropto-dashboard/exchange"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RiskRequest struct {
+	Symbols    []string
+	Interval   string
+	Window     int // candles the statistics are computed over
+	Confidence float64
+}
+
+// Volatility is annualized, 0.6 means 60% a year.
+type Volatility struct {
+	CloseToClose float64 `json:"closeToClose"`
+	Parkinson    float64 `json:"parkinson"`
+	GarmanKlass  float64 `json:"garmanKlass"`
+	YangZhang    float64 `json:"yangZhang"`
+}
+
+type RiskReport struct {
+	EventType  string     `json:"eventType"`
+	Symbol     string     `json:"symbol"`
+	Interval   string     `json:"interval"`
+	Window     int        `json:"window"`
+	Confidence float64    `json:"confidence"`
+	Volatility Volatility `json:"volatility"`
+	// VaR and CVaR are one period losses as positive fractions.
+	VaR            float64 `json:"var"`
+	CVaR           float64 `json:"cvar"`
+	MaxDrawdown    float64 `json:"maxDrawdown"`
+	DrawdownPeak   int64   `json:"drawdownPeak,omitempty"`
+	DrawdownTrough int64   `json:"drawdownTrough,omitempty"`
+	Start          int64   `json:"start"`
+	End            int64   `json:"end"`
+	Timestamp      int64   `json:"timestamp"`
+}
+
+type riskEntry struct {
+	report  *RiskReport
+	expires time.Time
+}
+
+// Risk computes per symbol volatility and tail risk from candles, caching
+// reports for TTL and pushing the default report for every symbol from Run.
+type Risk struct {
+	TTL      time.Duration
+	Fetch    Fetcher
+	Defaults RiskRequest
+
+	mutex  sync.Mutex
+	cache  map[string]riskEntry
+	notify func(message []byte)
+}
+
+func NewRisk(ttl time.Duration) *Risk {
+	return &Risk{
+		TTL:      ttl,
+		Fetch:    exchange.GetMultipleHistoricalData,
+		Defaults: RiskRequest{Interval: "1d", Window: 30, Confidence: 0.95},
+		cache:    make(map[string]riskEntry),
+	}
+}
+
+// SetNotifier receives the reports Run recomputes, one message per symbol.
+func (r *Risk) SetNotifier(fn func(message []byte)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.notify = fn
+}
+
+func (r *Risk) normalize(req *RiskRequest) error {
+	if req.Interval == "" {
+		req.Interval = r.Defaults.Interval
+	}
+	if exchange.IntervalDuration(req.Interval) <= 0 {
+		return fmt.Errorf("invalid interval %q", req.Interval)
+	}
+	if req.Window == 0 {
+		req.Window = r.Defaults.Window
+	}
+	if req.Window < 5 || req.Window > 999 {
+		return fmt.Errorf("window must be between 5 and 999")
+	}
+	if req.Confidence == 0 {
+		req.Confidence = r.Defaults.Confidence
+	}
+	if req.Confidence < 0.5 || req.Confidence >= 1 {
+		return fmt.Errorf("confidence must be at least 0.5 and below 1")
+	}
+
+	seen := map[string]bool{}
+	symbols := []string{}
+	for _, s := range req.Symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("no symbols")
+	}
+	if len(symbols) > MaxSymbols {
+		return fmt.Errorf("at most %d symbols", MaxSymbols)
+	}
+	req.Symbols = symbols
+	return nil
+}
+
+func riskKey(symbol string, req RiskRequest) string {
+	return fmt.Sprintf("%s|%s|%d|%g", symbol, req.Interval, req.Window, req.Confidence)
+}
+
+// Compute returns a report per symbol and the symbols without enough candles.
+func (r *Risk) Compute(req RiskRequest) ([]*RiskReport, []string, error) {
+	return r.compute(req, false)
+}
+
+func (r *Risk) compute(req RiskRequest, fresh bool) ([]*RiskReport, []string, error) {
+	if err := r.normalize(&req); err != nil {
+		return nil, nil, err
+	}
+
+	reports := make(map[string]*RiskReport)
+	pending := []string{}
+	r.mutex.Lock()
+	now := time.Now()
+	for _, s := range req.Symbols {
+		if entry, ok := r.cache[riskKey(s, req)]; ok && !fresh && now.Before(entry.expires) {
+			reports[s] = entry.report
+			continue
+		}
+		pending = append(pending, s)
+	}
+	r.mutex.Unlock()
+
+	if len(pending) > 0 {
+		// One extra candle gives the first return and Yang-Zhang's previous close.
+		data, err := r.Fetch(pending, req.Interval, req.Window+1)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrUpstream, err)
+		}
+
+		r.mutex.Lock()
+		now = time.Now()
+		for k, entry := range r.cache {
+			if now.After(entry.expires) {
+				delete(r.cache, k)
+			}
+		}
+		for _, s := range pending {
+			chart, ok := data[s]
+			if !ok || len(chart.Candlesticks) < req.Window+1 {
+				continue
+			}
+			report := riskReport(s, chart.Candlesticks, req)
+			reports[s] = report
+			r.cache[riskKey(s, req)] = riskEntry{report: report, expires: now.Add(r.TTL)}
+		}
+		r.mutex.Unlock()
+	}
+
+	out := []*RiskReport{}
+	missing := []string{}
+	for _, s := range req.Symbols {
+		if report, ok := reports[s]; ok {
+			out = append(out, report)
+		} else {
+			missing = append(missing, s)
+		}
+	}
+	return out, missing, nil
+}
+
+func riskReport(symbol string, candles []exchange.CandleStick, req RiskRequest) *RiskReport {
+	candles = candles[len(candles)-req.Window-1:]
+	all := exchange.ExtractOHLC(candles)
+	// Range based estimators use the window itself, the extra candle only
+	// anchors returns.
+	window := exchange.ExtractOHLC(candles[1:])
+	annualize := math.Sqrt(exchange.PeriodsPerYear(req.Interval))
+
+	report := &RiskReport{
+		EventType:  "risk",
+		Symbol:     symbol,
+		Interval:   req.Interval,
+		Window:     req.Window,
+		Confidence: req.Confidence,
+		Volatility: Volatility{
+			CloseToClose: round(CloseToClose(all)*annualize, 4),
+			Parkinson:    round(Parkinson(window)*annualize, 4),
+			GarmanKlass:  round(GarmanKlass(window)*annualize, 4),
+			YangZhang:    round(YangZhang(all)*annualize, 4),
+		},
+		Start:     candles[1].OpenTime,
+		End:       candles[len(candles)-1].CloseTime,
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	v, cv := HistoricalVaR(Returns(all.Close), req.Confidence)
+	report.VaR, report.CVaR = round(v, 4), round(cv, 4)
+
+	dd := MaxDrawdown(all.Close)
+	report.MaxDrawdown = round(dd.Depth, 4)
+	if dd.Depth > 0 {
+		report.DrawdownPeak = candles[dd.Peak].CloseTime
+		report.DrawdownTrough = candles[dd.Trough].CloseTime
+	}
+	return report
+}
+
+// Run recomputes the default report for symbols() every interval and sends
+// each one to the notifier as a "risk" stream event.
+func (r *Risk) Run(ctx context.Context, interval time.Duration, symbols func() []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.push(symbols())
+		}
+	}
+}
+
+func (r *Risk) push(symbols []string) {
+	for len(symbols) > 0 {
+		n := min(len(symbols), MaxSymbols)
+		r.pushBatch(symbols[:n])
+		symbols = symbols[n:]
+	}
+}
+
+func (r *Risk) pushBatch(symbols []string) {
+	req := r.Defaults
+	req.Symbols = symbols
+	reports, _, err := r.compute(req, true)
+	if err != nil {
+		log.Printf("Failed to compute risk metrics: %v", err)
+		return
+	}
+
+	r.mutex.Lock()
+	notify := r.notify
+	r.mutex.Unlock()
+	if notify == nil {
+		return
+	}
+	for _, report := range reports {
+		data, err := json.Marshal(report)
+		if err != nil {
+			continue
+		}
+		notify(data)
+	}
+}
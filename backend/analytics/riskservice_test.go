@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func candles(n int) *exchange.ChartData {
+	chart := &exchange.ChartData{}
+	for i := 0; i < n; i++ {
+		price := 100 + float64(i%7)
+		chart.Candlesticks = append(chart.Candlesticks, exchange.CandleStick{
+			OpenTime:  int64(i) * 60000,
+			CloseTime: int64(i+1)*60000 - 1,
+			Open:      decimal.NewFromFloat(price),
+			High:      decimal.NewFromFloat(price + 2),
+			Low:       decimal.NewFromFloat(price - 2),
+			Close:     decimal.NewFromFloat(price + 1),
+		})
+	}
+	return chart
+}
+
+func TestRiskCachesReports(t *testing.T) {
+	r := NewRisk(time.Minute)
+	fetches := 0
+	r.Fetch = func(symbols []string, interval string, limit int) (map[string]*exchange.ChartData, error) {
+		fetches++
+		data := make(map[string]*exchange.ChartData)
+		for _, s := range symbols {
+			data[s] = candles(limit)
+		}
+		return data, nil
+	}
+
+	req := RiskRequest{Symbols: []string{"btcusdt", "ETHUSDT"}, Interval: "1m"}
+	for i := 0; i < 2; i++ {
+		reports, missing, err := r.Compute(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 2 || len(missing) != 0 {
+			t.Fatalf("got %d reports, missing %v", len(reports), missing)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d times, want the second request served from the cache", fetches)
+	}
+}
+
+func TestRiskRejectsTooManySymbols(t *testing.T) {
+	r := NewRisk(time.Minute)
+	req := RiskRequest{}
+	for i := 0; i <= MaxSymbols; i++ {
+		req.Symbols = append(req.Symbols, fmt.Sprintf("SYM%dUSDT", i))
+	}
+	if _, _, err := r.Compute(req); err == nil {
+		t.Fatal("expected an error over the symbol limit")
+	}
+}
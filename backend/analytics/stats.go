@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Returns converts closes into simple period returns.
+func Returns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(closes)-1)
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] == 0 {
+			returns = append(returns, 0)
+			continue
+		}
+		returns = append(returns, closes[i]/closes[i-1]-1)
+	}
+	return returns
+}
+
+// LogReturns converts closes into log returns.
+func LogReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(closes)-1)
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] <= 0 || closes[i] <= 0 {
+			returns = append(returns, 0)
+			continue
+		}
+		returns = append(returns, math.Log(closes[i]/closes[i-1]))
+	}
+	return returns
+}
+
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// Covariance is the sample covariance of two equally long series.
+func Covariance(a, b []float64) float64 {
+	n := len(a)
+	if n < 2 || len(b) != n {
+		return 0
+	}
+	ma, mb := Mean(a), Mean(b)
+	sum := 0.0
+	for i := range a {
+		sum += (a[i] - ma) * (b[i] - mb)
+	}
+	return sum / float64(n-1)
+}
+
+func Variance(values []float64) float64 {
+	return Covariance(values, values)
+}
+
+func StdDev(values []float64) float64 {
+	return math.Sqrt(Variance(values))
+}
+
+// Pearson is the linear correlation of a and b, 0 when either is flat.
+func Pearson(a, b []float64) float64 {
+	sa, sb := StdDev(a), StdDev(b)
+	if sa == 0 || sb == 0 {
+		return 0
+	}
+	return Covariance(a, b) / (sa * sb)
+}
+
+// Spearman is the Pearson correlation of the ranks.
+func Spearman(a, b []float64) float64 {
+	return Pearson(ranks(a), ranks(b))
+}
+
+// ranks gives tied values the average of the ranks they span.
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+
+	out := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
+
+// Beta is the sensitivity of asset returns to benchmark returns.
+func Beta(asset, benchmark []float64) float64 {
+	v := Variance(benchmark)
+	if v == 0 {
+		return 0
+	}
+	return Covariance(asset, benchmark) / v
+}
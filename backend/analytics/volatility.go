@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"cropto-dashboard/exchange"
+	"math"
+)
+
+// The estimators below return per period volatility of log prices. Multiply by
+// sqrt(exchange.PeriodsPerYear(interval)) to annualize.
+
+// CloseToClose is the standard deviation of log close returns.
+func CloseToClose(s exchange.OHLC) float64 {
+	return StdDev(LogReturns(s.Close))
+}
+
+// Parkinson uses the high-low range, which sees intraperiod moves that
+// closes miss.
+func Parkinson(s exchange.OHLC) float64 {
+	n := len(s.High)
+	if n == 0 {
+		return 0
+	}
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		if hl := logRatio(s.High[i], s.Low[i]); hl != 0 {
+			sum += hl * hl
+		}
+	}
+	return math.Sqrt(sum / (4 * math.Ln2 * float64(n)))
+}
+
+// GarmanKlass adds the open to close move to the Parkinson range.
+func GarmanKlass(s exchange.OHLC) float64 {
+	n := len(s.Close)
+	if n == 0 {
+		return 0
+	}
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		hl := logRatio(s.High[i], s.Low[i])
+		co := logRatio(s.Close[i], s.Open[i])
+		sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+	}
+	return math.Sqrt(math.Max(0, sum/float64(n)))
+}
+
+// YangZhang combines overnight (previous close to open), open to close and
+// Rogers-Satchell variances, so it handles both drift and opening gaps. The
+// first candle only supplies the previous close.
+func YangZhang(s exchange.OHLC) float64 {
+	n := len(s.Close) - 1
+	if n < 2 {
+		return 0
+	}
+
+	overnight := make([]float64, n)
+	openClose := make([]float64, n)
+	rs := 0.0
+	for i := 1; i <= n; i++ {
+		overnight[i-1] = logRatio(s.Open[i], s.Close[i-1])
+		openClose[i-1] = logRatio(s.Close[i], s.Open[i])
+		rs += logRatio(s.High[i], s.Close[i])*logRatio(s.High[i], s.Open[i]) +
+			logRatio(s.Low[i], s.Close[i])*logRatio(s.Low[i], s.Open[i])
+	}
+	rs /= float64(n)
+
+	k := 0.34 / (1.34 + float64(n+1)/float64(n-1))
+	variance := Variance(overnight) + k*Variance(openClose) + (1-k)*rs
+	return math.Sqrt(math.Max(0, variance))
+}
+
+func logRatio(a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	return math.Log(a / b)
+}
@@ -0,0 +1,347 @@
+package anomaly
+
+import (
+	"context"
+	"cropto-dashboard/ids"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypePriceJump   = "price_jump"
+	TypeVolumeSpike = "volume_spike"
+	TypeStall       = "stall"
+	TypeResume      = "resume"
+
+	maxLog = 2000
+)
+
+type Config struct {
+	// Alpha is the EWMA weight of each new trade return.
+	Alpha float64
+	// PriceK is how many standard deviations a trade return must move.
+	PriceK float64
+	// Warmup is the number of returns seen before price jumps are reported.
+	Warmup int
+
+	// Volume is summed per VolumeBucket and compared with an EWMA baseline
+	// of earlier buckets.
+	VolumeBucket   time.Duration
+	VolumeAlpha    float64
+	VolumeK        float64
+	VolumeMinRatio float64
+	VolumeWarmup   int
+
+	// StallAfter is how long an active symbol may go without trades.
+	StallAfter time.Duration
+	// Cooldown keeps a symbol from repeating the same anomaly type.
+	Cooldown time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Alpha:          0.05,
+		PriceK:         5,
+		Warmup:         50,
+		VolumeBucket:   10 * time.Second,
+		VolumeAlpha:    0.1,
+		VolumeK:        4,
+		VolumeMinRatio: 3,
+		VolumeWarmup:   10,
+		StallAfter:     30 * time.Second,
+		Cooldown:       30 * time.Second,
+	}
+}
+
+// Event is one detected anomaly. Value is the observation, Baseline and
+// Threshold what it was compared against: a return for price jumps, the bucket
+// volume for spikes and the seconds without trades for stalls.
+type Event struct {
+	ID        string  `json:"id"`
+	EventType string  `json:"eventType"`
+	Type      string  `json:"type"`
+	Symbol    string  `json:"symbol"`
+	Value     float64 `json:"value"`
+	Baseline  float64 `json:"baseline"`
+	Threshold float64 `json:"threshold"`
+	ZScore    float64 `json:"zScore,omitempty"`
+	Price     float64 `json:"price,omitempty"`
+	Message   string  `json:"message"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// ewma tracks an exponentially weighted mean and variance.
+type ewma struct {
+	mean, variance float64
+	n              int
+}
+
+func (e *ewma) add(x, alpha float64) {
+	if e.n == 0 {
+		e.mean = x
+	} else {
+		delta := x - e.mean
+		e.mean += alpha * delta
+		e.variance = (1 - alpha) * (e.variance + alpha*delta*delta)
+	}
+	e.n++
+}
+
+func (e *ewma) std() float64 {
+	return math.Sqrt(e.variance)
+}
+
+type symbolState struct {
+	lastPrice float64
+	returns   ewma
+
+	bucketStart time.Time
+	bucketVol   float64
+	volumes     ewma
+
+	lastTrade time.Time
+	stalled   bool
+	lastAlert map[string]time.Time
+}
+
+// Detector watches the trade stream for price jumps, volume spikes and
+// stalls. A stall is often the only sign that one stream inside a combined
+// Binance connection has stopped while the others keep flowing.
+type Detector struct {
+	mutex   sync.Mutex
+	cfg     Config
+	symbols map[string]*symbolState
+	log     []Event
+	notify  func(message []byte)
+	now     func() time.Time
+}
+
+// NewDetector rejects durations the detector cannot work with: a zero
+// VolumeBucket never closes a bucket and a zero StallAfter makes every
+// symbol stalled.
+func NewDetector(cfg Config) (*Detector, error) {
+	if cfg.VolumeBucket <= 0 {
+		return nil, fmt.Errorf("volume bucket must be positive, got %v", cfg.VolumeBucket)
+	}
+	if cfg.StallAfter <= 0 {
+		return nil, fmt.Errorf("stall timeout must be positive, got %v", cfg.StallAfter)
+	}
+	return &Detector{
+		cfg:     cfg,
+		symbols: make(map[string]*symbolState),
+		now:     time.Now,
+	}, nil
+}
+
+// SetNotifier receives each anomaly as it is detected, outside the detector lock.
+func (d *Detector) SetNotifier(fn func(message []byte)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.notify = fn
+}
+
+// OnMessage takes trades from the normalized stream.
+func (d *Detector) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.EventType != "trade" || msg.Backfilled {
+		return
+	}
+	price := msg.Price.Float64()
+	if price <= 0 {
+		return
+	}
+	symbol := strings.ToUpper(msg.Symbol)
+
+	d.mutex.Lock()
+	now := d.now()
+	events := []Event{}
+
+	s, ok := d.symbols[symbol]
+	if !ok {
+		s = &symbolState{bucketStart: now, lastAlert: make(map[string]time.Time)}
+		d.symbols[symbol] = s
+	}
+
+	if s.stalled {
+		s.stalled = false
+		gap := now.Sub(s.lastTrade).Seconds()
+		events = append(events, d.eventLocked(TypeResume, symbol, gap, 0, d.cfg.StallAfter.Seconds(), 0, price,
+			fmt.Sprintf("%s trades resumed after %.0fs", symbol, gap)))
+	}
+	s.lastTrade = now
+
+	if s.lastPrice > 0 {
+		r := math.Log(price / s.lastPrice)
+		sample := r
+		if s.returns.n >= d.cfg.Warmup {
+			band := d.cfg.PriceK * s.returns.std()
+			z := (r - s.returns.mean) / math.Max(s.returns.std(), 1e-12)
+			if math.Abs(z) >= d.cfg.PriceK && d.readyLocked(s, TypePriceJump, now) {
+				events = append(events, d.eventLocked(TypePriceJump, symbol, r, s.returns.mean, band, z, price,
+					fmt.Sprintf("%s moved %.2f%% in one trade (%.1fσ)", symbol, (math.Exp(r)-1)*100, z)))
+			}
+			// Outliers are clipped to the band so one jump does not widen it.
+			sample = math.Max(s.returns.mean-band, math.Min(s.returns.mean+band, r))
+		}
+		s.returns.add(sample, d.cfg.Alpha)
+	}
+	s.lastPrice = price
+
+	events = append(events, d.rollBucketLocked(symbol, s, now)...)
+	s.bucketVol += msg.Volume.Float64()
+
+	notify := d.notify
+	d.mutex.Unlock()
+	d.publish(notify, events)
+}
+
+// rollBucketLocked closes every volume bucket that ended before now and checks
+// it against the baseline. Buckets without trades count as zero volume.
+func (d *Detector) rollBucketLocked(symbol string, s *symbolState, now time.Time) []Event {
+	events := []Event{}
+	for now.Sub(s.bucketStart) >= d.cfg.VolumeBucket {
+		vol, sample := s.bucketVol, s.bucketVol
+		if s.volumes.n >= d.cfg.VolumeWarmup {
+			band := s.volumes.mean + d.cfg.VolumeK*s.volumes.std()
+			if vol > band && vol >= s.volumes.mean*d.cfg.VolumeMinRatio && d.readyLocked(s, TypeVolumeSpike, now) {
+				z := (vol - s.volumes.mean) / math.Max(s.volumes.std(), 1e-12)
+				events = append(events, d.eventLocked(TypeVolumeSpike, symbol, vol, s.volumes.mean, band, z, s.lastPrice,
+					fmt.Sprintf("%s traded %.4g in %s, %.1fx its baseline", symbol, vol, d.cfg.VolumeBucket, vol/math.Max(s.volumes.mean, 1e-12))))
+			}
+			sample = math.Min(vol, band)
+		}
+		s.volumes.add(sample, d.cfg.VolumeAlpha)
+		s.bucketVol = 0
+		s.bucketStart = s.bucketStart.Add(d.cfg.VolumeBucket)
+
+		// After a long silence skip ahead instead of adding every empty bucket.
+		if now.Sub(s.bucketStart) > 100*d.cfg.VolumeBucket {
+			s.bucketStart = now
+		}
+	}
+	return events
+}
+
+func (d *Detector) readyLocked(s *symbolState, kind string, now time.Time) bool {
+	if last, ok := s.lastAlert[kind]; ok && now.Sub(last) < d.cfg.Cooldown {
+		return false
+	}
+	s.lastAlert[kind] = now
+	return true
+}
+
+func (d *Detector) eventLocked(kind, symbol string, value, baseline, threshold, z, price float64, message string) Event {
+	ev := Event{
+		ID:        ids.New(8),
+		EventType: "anomaly",
+		Type:      kind,
+		Symbol:    symbol,
+		Value:     round(value),
+		Baseline:  round(baseline),
+		Threshold: round(threshold),
+		ZScore:    math.Round(z*100) / 100,
+		Price:     price,
+		Message:   message,
+		Timestamp: d.now().UnixMilli(),
+	}
+	d.log = append(d.log, ev)
+	if len(d.log) > maxLog {
+		d.log = d.log[len(d.log)-maxLog:]
+	}
+	return ev
+}
+
+func round(v float64) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	// Eight significant digits keep tiny returns readable.
+	p := math.Pow(10, 8-math.Ceil(math.Log10(math.Abs(v))))
+	return math.Round(v*p) / p
+}
+
+// Run checks every second for stalled symbols and closes volume buckets of
+// symbols that stopped trading.
+func (d *Detector) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			now := d.now()
+			events := []Event{}
+			for symbol, s := range d.symbols {
+				if s.stalled {
+					continue
+				}
+				if idle := now.Sub(s.lastTrade); idle >= d.cfg.StallAfter {
+					s.stalled = true
+					events = append(events, d.eventLocked(TypeStall, symbol, idle.Seconds(), 0, d.cfg.StallAfter.Seconds(), 0, s.lastPrice,
+						fmt.Sprintf("no %s trades for %.0fs", symbol, idle.Seconds())))
+					continue
+				}
+				events = append(events, d.rollBucketLocked(symbol, s, now)...)
+			}
+			notify := d.notify
+			d.mutex.Unlock()
+			d.publish(notify, events)
+		}
+	}
+}
+
+func (d *Detector) publish(notify func(message []byte), events []Event) {
+	if notify == nil {
+		return
+	}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		notify(data)
+	}
+}
+
+// Query returns logged events newest first. Empty filters match everything.
+func (d *Detector) Query(symbol, kind string, since int64, limit int) []Event {
+	symbol = strings.ToUpper(symbol)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	out := []Event{}
+	for i := len(d.log) - 1; i >= 0 && len(out) < limit; i-- {
+		ev := d.log[i]
+		if ev.Timestamp < since {
+			break
+		}
+		if (symbol == "" || ev.Symbol == symbol) && (kind == "" || ev.Type == kind) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// Stalled lists the symbols currently without trades.
+func (d *Detector) Stalled() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stalled := []string{}
+	for symbol, s := range d.symbols {
+		if s.stalled {
+			stalled = append(stalled, symbol)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
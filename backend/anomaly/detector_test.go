@@ -0,0 +1,47 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDetectorRejectsZeroBucket(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VolumeBucket = 0
+	if _, err := NewDetector(cfg); err == nil {
+		t.Fatal("expected an error for a zero volume bucket")
+	}
+}
+
+func TestVolumeSpike(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VolumeWarmup = 3
+	cfg.Cooldown = 0
+	d, err := NewDetector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	d.now = func() time.Time { return now }
+
+	trade := func(volume string) {
+		d.OnMessage([]byte(`{"eventType":"trade","symbol":"BTCUSDT","price":"100","volume":"` + volume + `"}`))
+	}
+	for i := 0; i < 5; i++ {
+		trade("1")
+		now = now.Add(cfg.VolumeBucket)
+	}
+	trade("50")
+	now = now.Add(cfg.VolumeBucket)
+	trade("1")
+
+	found := false
+	for _, ev := range d.Query("", TypeVolumeSpike, 0, 10) {
+		if ev.Type == TypeVolumeSpike && ev.Symbol == "BTCUSDT" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected a volume spike")
+	}
+}
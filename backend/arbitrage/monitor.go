@@ -0,0 +1,427 @@
+package arbitrage
+
+import (
+	"context"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/ids"
+	"cropto-dashboard/storage"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VenueBinance is the venue of messages from the main stream.
+const VenueBinance = "binance"
+
+const (
+	StatusOpen   = "open"
+	StatusClosed = "closed"
+
+	maxHistory = 1000
+	bpsPlaces  = 2
+	// saveDelay batches the history writes of opportunities closing together.
+	saveDelay = time.Second
+)
+
+var tenThousand = decimal.NewFromInt(10000)
+
+type Config struct {
+	// ThresholdBps is the net spread, in basis points, an opportunity needs.
+	ThresholdBps decimal.Decimal
+	// MinDuration is how long the net spread must stay above the threshold
+	// before an opportunity is reported.
+	MinDuration time.Duration
+	// TakerFeeBps is the taker fee of each venue in basis points.
+	TakerFeeBps map[string]decimal.Decimal
+	// WithdrawalFee is what moving one transfer of a base asset costs, in
+	// units of that asset. It is spread over Notional.
+	WithdrawalFee map[string]decimal.Decimal
+	// Notional is the trade size in the quote asset withdrawal fees are
+	// charged against.
+	Notional decimal.Decimal
+	// MaxQuoteAge is how old a venue's quote may be and still count.
+	MaxQuoteAge time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ThresholdBps: decimal.NewFromInt(10),
+		MinDuration:  3 * time.Second,
+		TakerFeeBps: map[string]decimal.Decimal{
+			VenueBinance: decimal.NewFromInt(10),
+			"coinbase":   decimal.NewFromInt(60),
+		},
+		WithdrawalFee: map[string]decimal.Decimal{},
+		Notional:      decimal.NewFromInt(10000),
+		MaxQuoteAge:   10 * time.Second,
+	}
+}
+
+// ParseTable reads a list like "binance=10,coinbase=60". Keys are lower cased,
+// or upper cased when upper is set, as for assets in "BTC=0.0002".
+func ParseTable(s string, upper bool) (map[string]decimal.Decimal, error) {
+	table := make(map[string]decimal.Decimal)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid entry %q, expected key=value", part)
+		}
+		v, err := decimal.Parse(strings.TrimSpace(kv[1]))
+		if err != nil || v.IsNegative() {
+			return nil, fmt.Errorf("invalid value for %s", kv[0])
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if upper {
+			key = strings.ToUpper(key)
+		}
+		table[key] = v
+	}
+	return table, nil
+}
+
+type quote struct {
+	Bid decimal.Decimal `json:"bid"`
+	Ask decimal.Decimal `json:"ask"`
+	// book is false when the quote is only a last trade price.
+	book bool
+	At   time.Time `json:"at"`
+}
+
+// Opportunity is a period in which buying on BuyVenue and selling on
+// SellVenue paid more than the threshold after costs.
+type Opportunity struct {
+	ID         string          `json:"id"`
+	EventType  string          `json:"eventType"`
+	Status     string          `json:"status"`
+	Symbol     string          `json:"symbol"`
+	BuyVenue   string          `json:"buyVenue"`
+	SellVenue  string          `json:"sellVenue"`
+	BuyPrice   decimal.Decimal `json:"buyPrice"`
+	SellPrice  decimal.Decimal `json:"sellPrice"`
+	GrossBps   decimal.Decimal `json:"grossBps"`
+	NetBps     decimal.Decimal `json:"netBps"`
+	PeakNetBps decimal.Decimal `json:"peakNetBps"`
+	Start      int64           `json:"start"`
+	End        int64           `json:"end,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// candidate is a direction currently above the threshold. It becomes an
+// open opportunity once it has lasted MinDuration.
+type candidate struct {
+	since time.Time
+	opp   *Opportunity
+}
+
+// Spread is both directions of one symbol between two venues right now.
+type Spread struct {
+	Symbol    string           `json:"symbol"`
+	Quotes    map[string]quote `json:"quotes"`
+	BuyVenue  string           `json:"buyVenue"`
+	SellVenue string           `json:"sellVenue"`
+	GrossBps  decimal.Decimal  `json:"grossBps"`
+	NetBps    decimal.Decimal  `json:"netBps"`
+}
+
+// Monitor compares Binance prices with another venue's and reports
+// opportunities through the notifier.
+type Monitor struct {
+	mutex      sync.Mutex
+	cfg        Config
+	quotes     map[string]map[string]*quote // symbol, venue
+	candidates map[string]*candidate        // symbol|buy|sell
+	history    []Opportunity
+	writer     *storage.Writer
+	notify     func(message []byte)
+	now        func() time.Time
+}
+
+func NewMonitor(cfg Config, store *storage.JSONFile) (*Monitor, error) {
+	m := &Monitor{
+		cfg:        cfg,
+		quotes:     make(map[string]map[string]*quote),
+		candidates: make(map[string]*candidate),
+		now:        time.Now,
+	}
+	if _, err := store.Load(&m.history); err != nil {
+		return nil, err
+	}
+	m.writer = storage.NewWriter(store, saveDelay, m.snapshot)
+	return m, nil
+}
+
+// Close writes pending history and stops the background writer.
+func (m *Monitor) Close() {
+	m.writer.Close()
+}
+
+// SetNotifier receives an event when an opportunity opens and again when it closes.
+func (m *Monitor) SetNotifier(fn func(message []byte)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.notify = fn
+}
+
+// OnMessage takes Binance trades and tickers from the main stream.
+func (m *Monitor) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Backfilled {
+		return
+	}
+
+	switch msg.EventType {
+	case "ticker":
+		if msg.Bid.IsPositive() && msg.Ask.IsPositive() {
+			m.update(msg.Symbol, VenueBinance, quote{Bid: msg.Bid, Ask: msg.Ask, book: true})
+		}
+	case "trade":
+		if msg.Price.IsPositive() {
+			m.update(msg.Symbol, VenueBinance, quote{Bid: msg.Price, Ask: msg.Price})
+		}
+	}
+}
+
+// OnVenueMessage takes normalized quote messages from a second venue feed.
+func (m *Monitor) OnVenueMessage(message []byte) {
+	var msg types.QuoteMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.EventType != "quote" {
+		return
+	}
+	q := quote{Bid: msg.Bid, Ask: msg.Ask, book: true}
+	if !q.Bid.IsPositive() || !q.Ask.IsPositive() {
+		if !msg.Price.IsPositive() {
+			return
+		}
+		q = quote{Bid: msg.Price, Ask: msg.Price}
+	}
+	m.update(msg.Symbol, strings.ToLower(msg.Venue), q)
+}
+
+func (m *Monitor) update(symbol, venue string, q quote) {
+	symbol = strings.ToUpper(symbol)
+
+	m.mutex.Lock()
+	venues, ok := m.quotes[symbol]
+	if !ok {
+		venues = make(map[string]*quote)
+		m.quotes[symbol] = venues
+	}
+	now := m.now()
+	// A trade only stands in for the book when no fresh book is known.
+	if last, ok := venues[venue]; ok && last.book && !q.book && now.Sub(last.At) < m.cfg.MaxQuoteAge {
+		m.mutex.Unlock()
+		return
+	}
+	q.At = now
+	venues[venue] = &q
+	events := m.evaluateLocked(symbol, now)
+	notify := m.notify
+	m.mutex.Unlock()
+
+	m.publish(notify, events)
+}
+
+// Run feeds the second venue into the monitor and re-evaluates every symbol
+// periodically, so opportunities open and expire even when quotes go quiet.
+func (m *Monitor) Run(ctx context.Context, feed <-chan []byte) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-feed:
+			if !ok {
+				feed = nil
+				continue
+			}
+			m.OnVenueMessage(message)
+		case <-ticker.C:
+			m.mutex.Lock()
+			now := m.now()
+			events := []Opportunity{}
+			for symbol := range m.quotes {
+				events = append(events, m.evaluateLocked(symbol, now)...)
+			}
+			notify := m.notify
+			m.mutex.Unlock()
+			m.publish(notify, events)
+		}
+	}
+}
+
+// netSpread prices buying at buy's ask and selling at sell's bid.
+func (m *Monitor) netSpread(base string, buyVenue, sellVenue string, buy, sell *quote) (gross, net decimal.Decimal) {
+	gross = sell.Bid.Sub(buy.Ask).Mul(tenThousand).Div(buy.Ask, bpsPlaces)
+	net = gross.Sub(m.cfg.TakerFeeBps[buyVenue]).Sub(m.cfg.TakerFeeBps[sellVenue])
+	if fee, ok := m.cfg.WithdrawalFee[base]; ok && m.cfg.Notional.IsPositive() {
+		net = net.Sub(fee.Mul(buy.Ask).Mul(tenThousand).Div(m.cfg.Notional, bpsPlaces))
+	}
+	return gross, net
+}
+
+// evaluateLocked opens, updates and closes the opportunities of symbol and
+// returns the events to publish.
+func (m *Monitor) evaluateLocked(symbol string, now time.Time) []Opportunity {
+	base, _, _ := exchange.SplitSymbol(symbol)
+	events := []Opportunity{}
+
+	venues := m.quotes[symbol]
+	for buyVenue, buy := range venues {
+		for sellVenue, sell := range venues {
+			if buyVenue == sellVenue {
+				continue
+			}
+			key := symbol + "|" + buyVenue + "|" + sellVenue
+			c := m.candidates[key]
+
+			fresh := now.Sub(buy.At) <= m.cfg.MaxQuoteAge && now.Sub(sell.At) <= m.cfg.MaxQuoteAge
+			var gross, net decimal.Decimal
+			if fresh {
+				gross, net = m.netSpread(base, buyVenue, sellVenue, buy, sell)
+			}
+
+			if !fresh || net.LessThan(m.cfg.ThresholdBps) {
+				if c != nil && c.opp.Status == StatusOpen {
+					c.opp.Status = StatusClosed
+					c.opp.End = now.UnixMilli()
+					c.opp.DurationMs = c.opp.End - c.opp.Start
+					m.history = append(m.history, *c.opp)
+					if len(m.history) > maxHistory {
+						m.history = m.history[len(m.history)-maxHistory:]
+					}
+					m.writer.Mark()
+					events = append(events, *c.opp)
+				}
+				delete(m.candidates, key)
+				continue
+			}
+
+			if c == nil {
+				c = &candidate{since: now, opp: &Opportunity{
+					ID:         ids.New(8),
+					EventType:  "arbitrage",
+					Symbol:     symbol,
+					BuyVenue:   buyVenue,
+					SellVenue:  sellVenue,
+					Start:      now.UnixMilli(),
+					PeakNetBps: net,
+				}}
+				m.candidates[key] = c
+			}
+			opp := c.opp
+			opp.BuyPrice, opp.SellPrice = buy.Ask, sell.Bid
+			opp.GrossBps, opp.NetBps = gross, net
+			if net.GreaterThan(opp.PeakNetBps) {
+				opp.PeakNetBps = net
+			}
+			opp.DurationMs = now.UnixMilli() - opp.Start
+
+			if opp.Status == "" && now.Sub(c.since) >= m.cfg.MinDuration {
+				opp.Status = StatusOpen
+				events = append(events, *opp)
+			}
+		}
+	}
+	return events
+}
+
+func (m *Monitor) publish(notify func(message []byte), events []Opportunity) {
+	if notify == nil {
+		return
+	}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		notify(data)
+	}
+}
+
+// Spreads returns the best direction of every symbol quoted on at least two venues.
+func (m *Monitor) Spreads() []Spread {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	spreads := []Spread{}
+	for symbol, venues := range m.quotes {
+		base, _, _ := exchange.SplitSymbol(symbol)
+		var best *Spread
+		for buyVenue, buy := range venues {
+			for sellVenue, sell := range venues {
+				if buyVenue == sellVenue || now.Sub(buy.At) > m.cfg.MaxQuoteAge || now.Sub(sell.At) > m.cfg.MaxQuoteAge {
+					continue
+				}
+				gross, net := m.netSpread(base, buyVenue, sellVenue, buy, sell)
+				if best == nil || net.GreaterThan(best.NetBps) {
+					best = &Spread{Symbol: symbol, BuyVenue: buyVenue, SellVenue: sellVenue, GrossBps: gross, NetBps: net}
+				}
+			}
+		}
+		if best == nil {
+			continue
+		}
+		best.Quotes = make(map[string]quote, len(venues))
+		for venue, q := range venues {
+			best.Quotes[venue] = *q
+		}
+		spreads = append(spreads, *best)
+	}
+	sort.Slice(spreads, func(i, j int) bool { return spreads[i].NetBps.GreaterThan(spreads[j].NetBps) })
+	return spreads
+}
+
+// Open lists the opportunities currently open.
+func (m *Monitor) Open() []Opportunity {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	open := []Opportunity{}
+	for _, c := range m.candidates {
+		if c.opp.Status == StatusOpen {
+			open = append(open, *c.opp)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].Start < open[j].Start })
+	return open
+}
+
+// History returns closed opportunities newest first, optionally for one symbol.
+func (m *Monitor) History(symbol string, limit int) []Opportunity {
+	symbol = strings.ToUpper(symbol)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	out := []Opportunity{}
+	for i := len(m.history) - 1; i >= 0 && len(out) < limit; i-- {
+		if symbol == "" || m.history[i].Symbol == symbol {
+			out = append(out, m.history[i])
+		}
+	}
+	return out
+}
+
+func (m *Monitor) Config() Config {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.cfg
+}
+
+// snapshot encodes the history for the background writer.
+func (m *Monitor) snapshot() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return storage.Encode(m.history)
+}
@@ -0,0 +1,145 @@
+package arbitrage
+
+import (
+	"context"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/storage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeFeed serves a WebSocket that writes every message sent on out.
+func fakeFeed(t *testing.T, out <-chan string) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			// Read the subscription and pings so control frames are handled.
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		for message := range out {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func binanceTicker(bid, ask string) string {
+	return fmt.Sprintf(`{"stream":"btcusdt@ticker","data":{"e":"24hrTicker","E":%d,"s":"BTCUSDT",`+
+		`"c":"%s","b":"%s","B":"1","a":"%s","A":"1","C":%d}}`, time.Now().UnixMilli(), bid, bid, ask, time.Now().UnixMilli())
+}
+
+func coinbaseTicker(bid, ask string) string {
+	return fmt.Sprintf(`{"type":"ticker","product_id":"BTC-USD","price":"%s","best_bid":"%s","best_ask":"%s"}`, bid, bid, ask)
+}
+
+func waitFor(t *testing.T, events <-chan Opportunity, status string) Opportunity {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Status == status {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s opportunity", status)
+		}
+	}
+}
+
+func TestMonitorWithFakeFeeds(t *testing.T) {
+	binanceOut := make(chan string, 4)
+	coinbaseOut := make(chan string, 4)
+	defer close(binanceOut)
+	defer close(coinbaseOut)
+
+	binanceURL, coinbaseURL := exchange.BinanceWSURL, exchange.CoinbaseWSURL
+	exchange.BinanceWSURL = fakeFeed(t, binanceOut) + "/stream?streams="
+	exchange.CoinbaseWSURL = fakeFeed(t, coinbaseOut)
+	defer func() { exchange.BinanceWSURL, exchange.CoinbaseWSURL = binanceURL, coinbaseURL }()
+
+	cfg := DefaultConfig()
+	cfg.MinDuration = 0
+	cfg.TakerFeeBps = map[string]decimal.Decimal{}
+	path := filepath.Join(t.TempDir(), "arbitrage.json")
+	m, err := NewMonitor(cfg, storage.NewJSONFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Opportunity, 16)
+	m.SetNotifier(func(message []byte) {
+		var ev Opportunity
+		if json.Unmarshal(message, &ev) == nil {
+			events <- ev
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	binance := exchange.NewBinanceClient([]string{"btcusdt"})
+	binance.Start()
+	defer binance.Close()
+	go func() {
+		for message := range binance.GetMessageChannel() {
+			m.OnMessage(message)
+		}
+	}()
+
+	coinbase := exchange.NewCoinbaseClient([]string{"BTCUSDT"})
+	coinbase.Start()
+	defer coinbase.Close()
+	go m.Run(ctx, coinbase.GetMessageChannel())
+
+	// Buying on Binance at 100.01 and selling on Coinbase at 101 clears 10 bps.
+	binanceOut <- binanceTicker("100.00", "100.01")
+	coinbaseOut <- coinbaseTicker("101.00", "101.02")
+	opened := waitFor(t, events, StatusOpen)
+	if opened.BuyVenue != VenueBinance || opened.SellVenue != "coinbase" || opened.Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected opportunity %+v", opened)
+	}
+	if opened.BuyPrice.String() != "100.01" || opened.SellPrice.String() != "101.00" {
+		t.Fatalf("opened at %s/%s, want 100.01/101.00", opened.BuyPrice, opened.SellPrice)
+	}
+
+	// Binance catches up and the spread falls below the threshold both ways.
+	binanceOut <- binanceTicker("101.05", "101.06")
+	closed := waitFor(t, events, StatusClosed)
+	if closed.ID != opened.ID || closed.End == 0 {
+		t.Fatalf("closed %+v, want the opportunity opened as %s", closed, opened.ID)
+	}
+	if history := m.History("", 10); len(history) != 1 || history[0].ID != opened.ID {
+		t.Fatalf("history = %+v", history)
+	}
+
+	m.Close()
+	var saved []Opportunity
+	if _, err := storage.NewJSONFile(path).Load(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].ID != opened.ID {
+		t.Fatalf("saved history = %+v", saved)
+	}
+}
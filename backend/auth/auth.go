@@ -0,0 +1,149 @@
+// Package auth identifies callers of the REST, WebSocket and gRPC APIs by
+// API key or session token, and holds the origin allow-list.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	MethodAPIKey  = "api_key"
+	MethodSession = "session"
+
+	PolicyOpen     = "open"
+	PolicyRequired = "required"
+)
+
+var ErrKeyInURL = errors.New("API keys are not accepted in URLs, use a session token")
+
+// Identity is the authenticated caller attached to requests and sockets.
+type Identity struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	KeyID  string `json:"keyId"`
+	Method string `json:"method"`
+}
+
+func (i *Identity) IsAdmin() bool {
+	return i != nil && i.Role == RoleAdmin
+}
+
+type contextKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the caller, nil for anonymous requests.
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
+
+type Authenticator struct {
+	users  *Store
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewAuthenticator(users *Store, secret []byte, ttl time.Duration) *Authenticator {
+	return &Authenticator{users: users, secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Authenticate accepts an API key or a session token.
+func (a *Authenticator) Authenticate(credential string) (*Identity, error) {
+	if strings.HasPrefix(credential, KeyPrefix) {
+		return a.users.VerifyKey(credential)
+	}
+	c, err := verifyJWT(credential, a.secret, a.now())
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := a.users.identity(c.Subject, c.KeyID)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	return identity, nil
+}
+
+// IssueSession signs a session token for an authenticated caller.
+func (a *Authenticator) IssueSession(identity *Identity) (string, time.Time) {
+	now := a.now()
+	expires := now.Add(a.ttl)
+	token := signJWT(claims{
+		Subject:   identity.UserID,
+		KeyID:     identity.KeyID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	}, a.secret)
+	return token, expires
+}
+
+// Credential reads "Authorization: Bearer", X-API-Key or, for browsers that
+// cannot set headers on WebSockets and EventSource, ?access_token=. Query
+// strings end up in logs, so only session tokens are accepted there.
+func Credential(r *http.Request) (string, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, _ := strings.Cut(h, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), nil
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, nil
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		if strings.HasPrefix(token, KeyPrefix) {
+			return "", ErrKeyInURL
+		}
+		return token, nil
+	}
+	return "", nil
+}
+
+// Origins is the browser origin allow-list. "*" allows every origin, and
+// a page is always allowed to call the host it was served from.
+type Origins struct {
+	any     bool
+	allowed map[string]bool
+}
+
+func ParseOrigins(s string) Origins {
+	o := Origins{allowed: make(map[string]bool)}
+	for _, origin := range strings.Split(s, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			o.any = true
+		} else if origin != "" {
+			o.allowed[strings.ToLower(origin)] = true
+		}
+	}
+	return o
+}
+
+func (o Origins) Any() bool {
+	return o.any
+}
+
+// Allowed reports whether a request from origin is accepted. Requests
+// without an Origin header come from non-browser clients.
+func (o Origins) Allowed(origin string) bool {
+	return origin == "" || o.any || o.allowed[strings.ToLower(origin)]
+}
+
+// CheckOrigin fits websocket.Upgrader.CheckOrigin.
+func (o Origins) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if o.Allowed(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"cropto-dashboard/storage"
+	"encoding/base64"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthenticator(t *testing.T) (*Authenticator, *Store, string, UserInfo) {
+	t.Helper()
+	users, err := NewStore(storage.NewJSONFile(filepath.Join(t.TempDir(), "users.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.CreateUser("alice", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := users.CreateKey(user.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthenticator(users, []byte("test secret"), time.Hour), users, key, user
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, _, key, user := newAuthenticator(t)
+
+	identity, err := a.Authenticate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != user.ID || identity.Method != MethodAPIKey {
+		t.Errorf("got %+v", identity)
+	}
+
+	id, _, _ := strings.Cut(strings.TrimPrefix(key, KeyPrefix), ".")
+	for _, bad := range []string{
+		KeyPrefix + id + ".wrong",
+		KeyPrefix + "unknown.secret",
+		KeyPrefix + id,
+		"",
+	} {
+		if _, err := a.Authenticate(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	a, users, key, user := newAuthenticator(t)
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+
+	identity, err := a.Authenticate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, expires := a.IssueSession(identity)
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires at %v", expires)
+	}
+
+	session, err := a.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserID != user.ID || session.Method != MethodSession || session.KeyID != identity.KeyID {
+		t.Errorf("got %+v", session)
+	}
+
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	for name, bad := range map[string]string{
+		"tampered payload": parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","kid":"x","exp":9999999999}`)) + "." + parts[2],
+		"alg none":         none + "." + parts[1] + ".",
+		"other secret":     signJWT(claims{Subject: user.ID, KeyID: identity.KeyID, ExpiresAt: now.Add(time.Hour).Unix()}, []byte("other")),
+		"truncated":        parts[0] + "." + parts[1],
+	} {
+		if _, err := a.Authenticate(bad); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := a.Authenticate(token); err == nil {
+		t.Error("accepted an expired session")
+	}
+
+	now = now.Add(-time.Minute)
+	if err := users.RevokeKey(user.ID, identity.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(token); err == nil {
+		t.Error("accepted a session of a revoked key")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	origins := ParseOrigins("https://dash.example.com")
+	for origin, want := range map[string]bool{
+		"":                         true,
+		"https://dash.example.com": true,
+		"http://api.example.com":   true, // the API's own host
+		"https://evil.example.com": false,
+	} {
+		r := httptest.NewRequest("GET", "http://api.example.com/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := origins.CheckOrigin(r); got != want {
+			t.Errorf("%q: got %v, want %v", origin, got, want)
+		}
+	}
+}
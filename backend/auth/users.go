@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"cropto-dashboard/ids"
+	"cropto-dashboard/storage"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	// KeyPrefix starts every API key: ck_<key id>.<secret>.
+	KeyPrefix = "ck_"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("invalid credentials")
+)
+
+// APIKey is stored by the SHA-256 of its secret. Secrets are 24 random
+// bytes, so a fast hash is enough and keeps every request cheap.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Keys      []APIKey  `json:"keys"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// KeyInfo and UserInfo are what the API shows, without hashes.
+type KeyInfo struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UserInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Keys      []KeyInfo `json:"keys"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (u *User) info() UserInfo {
+	info := UserInfo{ID: u.ID, Name: u.Name, Role: u.Role, Keys: []KeyInfo{}, CreatedAt: u.CreatedAt}
+	for _, k := range u.Keys {
+		info.Keys = append(info.Keys, KeyInfo{ID: k.ID, Label: k.Label, CreatedAt: k.CreatedAt})
+	}
+	return info
+}
+
+// Store keeps the user accounts in a JSON file.
+type Store struct {
+	mutex sync.RWMutex
+	users map[string]*User
+	keys  map[string]string // key id to user id
+	store *storage.JSONFile
+}
+
+func NewStore(store *storage.JSONFile) (*Store, error) {
+	s := &Store{
+		users: make(map[string]*User),
+		keys:  make(map[string]string),
+		store: store,
+	}
+	if _, err := store.Load(&s.users); err != nil {
+		return nil, err
+	}
+	if s.users == nil {
+		s.users = make(map[string]*User)
+	}
+	for _, u := range s.users {
+		for _, k := range u.Keys {
+			s.keys[k.ID] = u.ID
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) Count() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.users)
+}
+
+func (s *Store) Users() []UserInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	users := make([]UserInfo, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u.info())
+	}
+	return users
+}
+
+func (s *Store) CreateUser(name, role string) (UserInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return UserInfo{}, fmt.Errorf("name is required")
+	}
+	if role == "" {
+		role = RoleUser
+	}
+	if role != RoleAdmin && role != RoleUser {
+		return UserInfo{}, fmt.Errorf("role must be admin or user")
+	}
+
+	u := &User{ID: ids.New(8), Name: name, Role: role, Keys: []APIKey{}, CreatedAt: time.Now()}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.users[u.ID] = u
+	return u.info(), s.saveLocked()
+}
+
+func (s *Store) DeleteUser(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, k := range u.Keys {
+		delete(s.keys, k.ID)
+	}
+	delete(s.users, id)
+	return s.saveLocked()
+}
+
+// CreateKey returns the only copy of the new key's secret.
+func (s *Store) CreateKey(userID, label string) (string, KeyInfo, error) {
+	id, secret := ids.New(8), ids.New(24)
+	key := APIKey{ID: id, Label: label, Hash: hashSecret(secret), CreatedAt: time.Now()}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return "", KeyInfo{}, ErrNotFound
+	}
+	u.Keys = append(u.Keys, key)
+	s.keys[id] = userID
+	if err := s.saveLocked(); err != nil {
+		return "", KeyInfo{}, err
+	}
+	return KeyPrefix + id + "." + secret, KeyInfo{ID: id, Label: label, CreatedAt: key.CreatedAt}, nil
+}
+
+func (s *Store) RevokeKey(userID, keyID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, k := range u.Keys {
+		if k.ID == keyID {
+			u.Keys = append(u.Keys[:i], u.Keys[i+1:]...)
+			delete(s.keys, keyID)
+			return s.saveLocked()
+		}
+	}
+	return ErrNotFound
+}
+
+// VerifyKey resolves an API key to its owner.
+func (s *Store) VerifyKey(key string) (*Identity, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, KeyPrefix), ".")
+	if !ok || !strings.HasPrefix(key, KeyPrefix) {
+		return nil, ErrUnauthorized
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	u, ok := s.users[s.keys[id]]
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	for _, k := range u.Keys {
+		if k.ID == id && subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) == 1 {
+			return &Identity{UserID: u.ID, Name: u.Name, Role: u.Role, KeyID: id, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, ErrUnauthorized
+}
+
+// identity looks up the current state of a user that still holds keyID.
+func (s *Store) identity(userID, keyID string) (*Identity, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.keys[keyID] != userID {
+		return nil, false
+	}
+	u := s.users[userID]
+	return &Identity{UserID: u.ID, Name: u.Name, Role: u.Role, KeyID: keyID, Method: MethodSession}, true
+}
+
+func (s *Store) saveLocked() error {
+	return s.store.Save(s.users)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,353 @@
+package backtest
+
+import (
+	"cropto-dashboard/exchange"
+	"fmt"
+	"math"
+)
+
+type Candle struct {
+	OpenTime  int64   `json:"openTime"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    float64 `json:"volume"`
+	CloseTime int64   `json:"closeTime"`
+}
+
+func CandlesFromChart(data []exchange.CandleStick) []Candle {
+	candles := make([]Candle, 0, len(data))
+	for _, c := range data {
+		candles = append(candles, Candle{
+			OpenTime:  c.OpenTime,
+			Open:      c.Open.Float64(),
+			High:      c.High.Float64(),
+			Low:       c.Low.Float64(),
+			Close:     c.Close.Float64(),
+			Volume:    c.Volume.Float64(),
+			CloseTime: c.CloseTime,
+		})
+	}
+	return candles
+}
+
+const (
+	SizingPercent = "percent"
+	SizingFixed   = "fixed"
+)
+
+type Config struct {
+	InitialCapital float64 `json:"initialCapital"`
+	FeeBps         float64 `json:"feeBps"`
+	SlippageBps    float64 `json:"slippageBps"`
+	// Sizing is "percent" (PositionPct of equity per entry) or "fixed" (FixedQty units).
+	Sizing        string  `json:"sizing"`
+	PositionPct   float64 `json:"positionPct"`
+	FixedQty      float64 `json:"fixedQty"`
+	StopLossPct   float64 `json:"stopLossPct"`
+	TakeProfitPct float64 `json:"takeProfitPct"`
+	AllowShort    bool    `json:"allowShort"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		InitialCapital: 10000,
+		FeeBps:         10,
+		SlippageBps:    5,
+		Sizing:         SizingPercent,
+		PositionPct:    100,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.InitialCapital <= 0 {
+		return fmt.Errorf("initialCapital must be positive")
+	}
+	if c.FeeBps < 0 || c.SlippageBps < 0 || c.StopLossPct < 0 || c.TakeProfitPct < 0 {
+		return fmt.Errorf("fees, slippage, stop loss and take profit cannot be negative")
+	}
+	switch c.Sizing {
+	case SizingPercent:
+		if c.PositionPct <= 0 || c.PositionPct > 100 {
+			return fmt.Errorf("positionPct must be in (0, 100]")
+		}
+	case SizingFixed:
+		if c.FixedQty <= 0 {
+			return fmt.Errorf("fixedQty must be positive")
+		}
+	default:
+		return fmt.Errorf("sizing must be %q or %q", SizingPercent, SizingFixed)
+	}
+	return nil
+}
+
+type Trade struct {
+	Side       string  `json:"side"`
+	Quantity   float64 `json:"quantity"`
+	EntryTime  int64   `json:"entryTime"`
+	EntryPrice float64 `json:"entryPrice"`
+	ExitTime   int64   `json:"exitTime"`
+	ExitPrice  float64 `json:"exitPrice"`
+	Fees       float64 `json:"fees"`
+	PnL        float64 `json:"pnl"`
+	ReturnPct  float64 `json:"returnPct"`
+	ExitReason string  `json:"exitReason"`
+}
+
+type order struct {
+	side string // "long", "short" or "close"
+	qty  float64
+}
+
+type position struct {
+	side       string
+	qty        float64
+	entryTime  int64
+	entryPrice float64
+	entryFee   float64
+	stopLoss   float64
+	takeProfit float64
+}
+
+// Context is the order API handed to a strategy on every candle. Orders are
+// filled at the next candle's open, so a strategy never trades on a close it
+// has not seen yet.
+type Context struct {
+	cfg     Config
+	candles []Candle
+	closes  []float64
+	index   int
+
+	cash     float64
+	position *position
+	pending  *order
+	trades   []Trade
+}
+
+// Candles returns every candle up to and including the current one.
+func (c *Context) Candles() []Candle {
+	return c.candles[:c.index+1]
+}
+
+// Closes returns the close prices up to and including the current candle.
+func (c *Context) Closes() []float64 {
+	return c.closes[:c.index+1]
+}
+
+func (c *Context) Cash() float64 {
+	return c.cash
+}
+
+// Position returns the signed open quantity (negative when short).
+func (c *Context) Position() float64 {
+	if c.position == nil {
+		return 0
+	}
+	if c.position.side == "short" {
+		return -c.position.qty
+	}
+	return c.position.qty
+}
+
+func (c *Context) Equity() float64 {
+	return c.equityAt(c.candles[c.index].Close)
+}
+
+func (c *Context) equityAt(price float64) float64 {
+	if c.position == nil {
+		return c.cash
+	}
+	if c.position.side == "long" {
+		return c.cash + c.position.qty*price
+	}
+	// A short was opened by receiving its notional, the buy-back is still owed.
+	return c.cash - c.position.qty*price
+}
+
+// Buy opens a long sized by the config. qty > 0 overrides the sizing rule.
+func (c *Context) Buy(qty float64) {
+	c.pending = &order{side: "long", qty: qty}
+}
+
+// Sell opens a short when shorting is allowed, otherwise it closes a long.
+func (c *Context) Sell(qty float64) {
+	if !c.cfg.AllowShort {
+		c.ClosePosition()
+		return
+	}
+	c.pending = &order{side: "short", qty: qty}
+}
+
+func (c *Context) ClosePosition() {
+	c.pending = &order{side: "close"}
+}
+
+func (c *Context) fillPrice(price float64, buying bool) float64 {
+	slip := price * c.cfg.SlippageBps / 10000
+	if buying {
+		return price + slip
+	}
+	return price - slip
+}
+
+func (c *Context) fee(notional float64) float64 {
+	return notional * c.cfg.FeeBps / 10000
+}
+
+func (c *Context) execute(o *order, candle Candle) {
+	if o.side == "close" || (c.position != nil && c.position.side != o.side) {
+		if c.position != nil {
+			c.exit(candle.OpenTime, candle.Open, "signal")
+		}
+		if o.side == "close" {
+			return
+		}
+	}
+	if c.position != nil {
+		return
+	}
+
+	buying := o.side == "long"
+	price := c.fillPrice(candle.Open, buying)
+
+	qty := o.qty
+	if qty <= 0 {
+		if c.cfg.Sizing == SizingFixed {
+			qty = c.cfg.FixedQty
+		} else {
+			budget := c.cash * c.cfg.PositionPct / 100
+			qty = budget / (price * (1 + c.cfg.FeeBps/10000))
+		}
+	}
+	if qty <= 0 {
+		return
+	}
+
+	notional := qty * price
+	fee := c.fee(notional)
+	if buying && notional+fee > c.cash {
+		return
+	}
+
+	if buying {
+		c.cash -= notional + fee
+	} else {
+		c.cash += notional - fee
+	}
+
+	pos := &position{side: o.side, qty: qty, entryTime: candle.OpenTime, entryPrice: price, entryFee: fee}
+	if c.cfg.StopLossPct > 0 {
+		if buying {
+			pos.stopLoss = price * (1 - c.cfg.StopLossPct/100)
+		} else {
+			pos.stopLoss = price * (1 + c.cfg.StopLossPct/100)
+		}
+	}
+	if c.cfg.TakeProfitPct > 0 {
+		if buying {
+			pos.takeProfit = price * (1 + c.cfg.TakeProfitPct/100)
+		} else {
+			pos.takeProfit = price * (1 - c.cfg.TakeProfitPct/100)
+		}
+	}
+	c.position = pos
+}
+
+func (c *Context) exit(at int64, rawPrice float64, reason string) {
+	pos := c.position
+	buying := pos.side == "short"
+	price := c.fillPrice(rawPrice, buying)
+	notional := pos.qty * price
+	fee := c.fee(notional)
+
+	var pnl float64
+	if pos.side == "long" {
+		c.cash += notional - fee
+		pnl = (price-pos.entryPrice)*pos.qty - pos.entryFee - fee
+	} else {
+		c.cash -= notional + fee
+		pnl = (pos.entryPrice-price)*pos.qty - pos.entryFee - fee
+	}
+
+	c.trades = append(c.trades, Trade{
+		Side:       pos.side,
+		Quantity:   pos.qty,
+		EntryTime:  pos.entryTime,
+		EntryPrice: pos.entryPrice,
+		ExitTime:   at,
+		ExitPrice:  price,
+		Fees:       pos.entryFee + fee,
+		PnL:        pnl,
+		ReturnPct:  pnl / (pos.entryPrice * pos.qty) * 100,
+		ExitReason: reason,
+	})
+	c.position = nil
+}
+
+// checkStops fills stop loss and take profit inside a candle. When both levels
+// are touched in the same candle the stop is assumed to have hit first.
+func (c *Context) checkStops(candle Candle) {
+	pos := c.position
+	if pos == nil {
+		return
+	}
+
+	if pos.side == "long" {
+		if pos.stopLoss > 0 && candle.Low <= pos.stopLoss {
+			c.exit(candle.OpenTime, math.Min(pos.stopLoss, candle.Open), "stop_loss")
+			return
+		}
+		if pos.takeProfit > 0 && candle.High >= pos.takeProfit {
+			c.exit(candle.OpenTime, math.Max(pos.takeProfit, candle.Open), "take_profit")
+		}
+		return
+	}
+
+	if pos.stopLoss > 0 && candle.High >= pos.stopLoss {
+		c.exit(candle.OpenTime, math.Max(pos.stopLoss, candle.Open), "stop_loss")
+		return
+	}
+	if pos.takeProfit > 0 && candle.Low <= pos.takeProfit {
+		c.exit(candle.OpenTime, math.Min(pos.takeProfit, candle.Open), "take_profit")
+	}
+}
+
+// Run replays candles through a strategy and returns the performance report.
+func Run(strategy Strategy, candles []Candle, cfg Config, interval string) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if len(candles) < 2 {
+		return nil, fmt.Errorf("need at least 2 candles, got %d", len(candles))
+	}
+
+	ctx := &Context{cfg: cfg, candles: candles, cash: cfg.InitialCapital}
+	ctx.closes = make([]float64, len(candles))
+	for i, c := range candles {
+		ctx.closes[i] = c.Close
+	}
+	equity := make([]EquityPoint, 0, len(candles))
+
+	for i, candle := range candles {
+		ctx.index = i
+
+		if ctx.pending != nil {
+			ctx.execute(ctx.pending, candle)
+			ctx.pending = nil
+		}
+		ctx.checkStops(candle)
+
+		strategy.OnCandle(ctx, candle)
+
+		equity = append(equity, EquityPoint{Time: candle.CloseTime, Equity: ctx.equityAt(candle.Close)})
+	}
+
+	last := candles[len(candles)-1]
+	if ctx.position != nil {
+		ctx.exit(last.CloseTime, last.Close, "end_of_data")
+		equity[len(equity)-1].Equity = ctx.cash
+	}
+
+	return buildReport(strategy.Name(), cfg, interval, candles, equity, ctx.trades), nil
+}
@@ -0,0 +1,124 @@
+package backtest
+
+import (
+	"cropto-dashboard/exchange"
+	"math"
+)
+
+type EquityPoint struct {
+	Time   int64   `json:"time"`
+	Equity float64 `json:"equity"`
+}
+
+type Report struct {
+	Strategy       string        `json:"strategy"`
+	Interval       string        `json:"interval"`
+	Start          int64         `json:"start"`
+	End            int64         `json:"end"`
+	Candles        int           `json:"candles"`
+	Config         Config        `json:"config"`
+	InitialCapital float64       `json:"initialCapital"`
+	FinalEquity    float64       `json:"finalEquity"`
+	TotalReturnPct float64       `json:"totalReturnPct"`
+	BuyHoldPct     float64       `json:"buyHoldPct"`
+	MaxDrawdownPct float64       `json:"maxDrawdownPct"`
+	Sharpe         float64       `json:"sharpe"`
+	WinRatePct     float64       `json:"winRatePct"`
+	ProfitFactor   float64       `json:"profitFactor"`
+	TotalFees      float64       `json:"totalFees"`
+	TradeCount     int           `json:"tradeCount"`
+	Trades         []Trade       `json:"trades"`
+	EquityCurve    []EquityPoint `json:"equityCurve"`
+}
+
+func buildReport(name string, cfg Config, interval string, candles []Candle, equity []EquityPoint, trades []Trade) *Report {
+	first, last := candles[0], candles[len(candles)-1]
+	final := equity[len(equity)-1].Equity
+
+	report := &Report{
+		Strategy:       name,
+		Interval:       interval,
+		Start:          first.OpenTime,
+		End:            last.CloseTime,
+		Candles:        len(candles),
+		Config:         cfg,
+		InitialCapital: cfg.InitialCapital,
+		FinalEquity:    final,
+		TotalReturnPct: (final/cfg.InitialCapital - 1) * 100,
+		MaxDrawdownPct: MaxDrawdown(equity) * 100,
+		Sharpe:         Sharpe(equity, exchange.PeriodsPerYear(interval)),
+		TradeCount:     len(trades),
+		Trades:         trades,
+		EquityCurve:    equity,
+	}
+	if first.Open > 0 {
+		report.BuyHoldPct = (last.Close/first.Open - 1) * 100
+	}
+
+	wins := 0
+	grossProfit, grossLoss := 0.0, 0.0
+	for _, t := range trades {
+		report.TotalFees += t.Fees
+		if t.PnL > 0 {
+			wins++
+			grossProfit += t.PnL
+		} else {
+			grossLoss -= t.PnL
+		}
+	}
+	if len(trades) > 0 {
+		report.WinRatePct = float64(wins) / float64(len(trades)) * 100
+	}
+	if grossLoss > 0 {
+		report.ProfitFactor = grossProfit / grossLoss
+	}
+
+	return report
+}
+
+// MaxDrawdown returns the largest peak-to-trough fall as a fraction.
+func MaxDrawdown(equity []EquityPoint) float64 {
+	peak, maxDD := 0.0, 0.0
+	for _, p := range equity {
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak > 0 {
+			if dd := (peak - p.Equity) / peak; dd > maxDD {
+				maxDD = dd
+			}
+		}
+	}
+	return maxDD
+}
+
+// Sharpe is the annualized mean/stddev of per-candle returns, risk-free rate 0.
+func Sharpe(equity []EquityPoint, periodsPerYear float64) float64 {
+	if len(equity) < 3 {
+		return 0
+	}
+
+	returns := make([]float64, 0, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1].Equity > 0 {
+			returns = append(returns, equity[i].Equity/equity[i-1].Equity-1)
+		}
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	if variance == 0 {
+		return 0
+	}
+	return mean / math.Sqrt(variance) * math.Sqrt(periodsPerYear)
+}
@@ -0,0 +1,185 @@
+package backtest
+
+import (
+	"cropto-dashboard/exchange"
+	"fmt"
+	"sort"
+)
+
+// Strategy receives every candle in order and trades through the Context.
+type Strategy interface {
+	Name() string
+	OnCandle(ctx *Context, candle Candle)
+}
+
+type Params map[string]float64
+
+func (p Params) get(key string, fallback float64) float64 {
+	if v, ok := p[key]; ok {
+		return v
+	}
+	return fallback
+}
+
+type StrategyInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Defaults    Params `json:"defaults"`
+}
+
+type factory struct {
+	info StrategyInfo
+	new  func(Params) (Strategy, error)
+}
+
+var registry = map[string]factory{}
+
+// Register makes a strategy available to the REST API by name.
+func Register(info StrategyInfo, new func(Params) (Strategy, error)) {
+	registry[info.Name] = factory{info: info, new: new}
+}
+
+func NewStrategy(name string, params Params) (Strategy, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	merged := Params{}
+	for k, v := range f.info.Defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	return f.new(merged)
+}
+
+func Strategies() []StrategyInfo {
+	infos := make([]StrategyInfo, 0, len(registry))
+	for _, f := range registry {
+		infos = append(infos, f.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func init() {
+	Register(StrategyInfo{
+		Name:        "ma_cross",
+		Description: "Long when the fast moving average crosses above the slow one, exit on the cross below",
+		Defaults:    Params{"fast": 20, "slow": 50},
+	}, func(p Params) (Strategy, error) {
+		fast, slow := int(p.get("fast", 20)), int(p.get("slow", 50))
+		if fast <= 0 || slow <= fast {
+			return nil, fmt.Errorf("ma_cross needs 0 < fast < slow")
+		}
+		return &maCross{fast: fast, slow: slow}, nil
+	})
+
+	Register(StrategyInfo{
+		Name:        "rsi_reversion",
+		Description: "Long when RSI drops below oversold, exit when it rises above overbought",
+		Defaults:    Params{"period": 14, "oversold": 30, "overbought": 70},
+	}, func(p Params) (Strategy, error) {
+		period := int(p.get("period", 14))
+		if period <= 1 {
+			return nil, fmt.Errorf("rsi_reversion needs period > 1")
+		}
+		return &rsiReversion{period: period, oversold: p.get("oversold", 30), overbought: p.get("overbought", 70)}, nil
+	})
+
+	Register(StrategyInfo{
+		Name:        "macd",
+		Description: "Long when MACD crosses above its signal line, short or exit on the cross below",
+		Defaults:    Params{"fast": 12, "slow": 26, "signal": 9},
+	}, func(p Params) (Strategy, error) {
+		fast, slow, signal := int(p.get("fast", 12)), int(p.get("slow", 26)), int(p.get("signal", 9))
+		if fast <= 0 || slow <= fast || signal <= 0 {
+			return nil, fmt.Errorf("macd needs 0 < fast < slow and signal > 0")
+		}
+		return &macdCross{fast: fast, slow: slow, signal: signal}, nil
+	})
+}
+
+// Smoothed indicators are computed over warmup times their period instead of the
+// whole history, which keeps a run linear in the number of candles.
+const warmup = 10
+
+func tail(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	return values[len(values)-n:]
+}
+
+type maCross struct {
+	fast, slow int
+}
+
+func (s *maCross) Name() string { return "ma_cross" }
+
+func (s *maCross) OnCandle(ctx *Context, candle Candle) {
+	closes := ctx.Closes()
+	if len(closes) < s.slow+1 {
+		return
+	}
+
+	closes = tail(closes, s.slow+1)
+	fast := exchange.MovingAverage(closes, s.fast)
+	slow := exchange.MovingAverage(closes, s.slow)
+	n := len(closes) - 1
+
+	if fast[n-1] <= slow[n-1] && fast[n] > slow[n] {
+		ctx.Buy(0)
+	} else if fast[n-1] >= slow[n-1] && fast[n] < slow[n] {
+		ctx.Sell(0)
+	}
+}
+
+type rsiReversion struct {
+	period               int
+	oversold, overbought float64
+}
+
+func (s *rsiReversion) Name() string { return "rsi_reversion" }
+
+func (s *rsiReversion) OnCandle(ctx *Context, candle Candle) {
+	closes := ctx.Closes()
+	if len(closes) <= s.period+1 {
+		return
+	}
+
+	rsi := exchange.RSI(tail(closes, s.period*warmup), s.period)
+	value := rsi[len(rsi)-1]
+
+	if ctx.Position() == 0 && value < s.oversold {
+		ctx.Buy(0)
+	} else if ctx.Position() > 0 && value > s.overbought {
+		ctx.ClosePosition()
+	}
+}
+
+type macdCross struct {
+	fast, slow, signal int
+}
+
+func (s *macdCross) Name() string { return "macd" }
+
+func (s *macdCross) OnCandle(ctx *Context, candle Candle) {
+	closes := ctx.Closes()
+	if len(closes) < s.slow+s.signal+1 {
+		return
+	}
+
+	closes = tail(closes, (s.slow+s.signal)*warmup)
+	result := exchange.MCAD(closes, s.fast, s.slow, s.signal)
+	n := len(closes) - 1
+	prev := result.MACD[n-1] - result.Signal[n-1]
+	cur := result.MACD[n] - result.Signal[n]
+
+	if prev <= 0 && cur > 0 {
+		ctx.Buy(0)
+	} else if prev >= 0 && cur < 0 {
+		ctx.Sell(0)
+	}
+}
@@ -0,0 +1,83 @@
+// Package broker moves the normalized stream between processes so one ingest
+// node can hold the exchange connections while any number of edge nodes
+// serve /ws behind a load balancer.
+package broker
+
+import (
+	"errors"
+	"sync"
+)
+
+// DefaultSubject carries the normalized market stream.
+const DefaultSubject = "cropto.stream"
+
+var ErrClosed = errors.New("broker is closed")
+
+// Broker publishes messages to every subscriber of a subject, in order.
+type Broker interface {
+	Publish(subject string, data []byte) error
+	// Subscribe calls handler for each message on subject until unsubscribe
+	// is called. The handler must not keep data after returning.
+	Subscribe(subject string, handler func(data []byte)) (unsubscribe func(), err error)
+	// Connected reports whether published messages currently get through.
+	Connected() bool
+	Close()
+}
+
+// Local is the in-process broker of a single node deployment. Handlers run
+// on the publisher's goroutine.
+type Local struct {
+	mutex    sync.RWMutex
+	nextID   int
+	handlers map[string]map[int]func(data []byte)
+	closed   bool
+}
+
+func NewLocal() *Local {
+	return &Local{handlers: make(map[string]map[int]func(data []byte))}
+}
+
+func (l *Local) Publish(subject string, data []byte) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if l.closed {
+		return ErrClosed
+	}
+	for _, handler := range l.handlers[subject] {
+		handler(data)
+	}
+	return nil
+}
+
+func (l *Local) Subscribe(subject string, handler func(data []byte)) (func(), error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if l.handlers[subject] == nil {
+		l.handlers[subject] = make(map[int]func(data []byte))
+	}
+	l.nextID++
+	id := l.nextID
+	l.handlers[subject][id] = handler
+
+	return func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		delete(l.handlers[subject], id)
+	}, nil
+}
+
+func (l *Local) Connected() bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return !l.closed
+}
+
+func (l *Local) Close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.closed = true
+	l.handlers = make(map[string]map[int]func(data []byte))
+}
@@ -0,0 +1,125 @@
+package broker
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes through a NATS server. Publishing is fire and forget: an
+// edge that is disconnected misses messages and resyncs from the next
+// tickers, the same way a dropped /ws client does.
+type NATS struct {
+	conn *nats.Conn
+}
+
+// ConnectNATS keeps retrying in the background, so nodes can start before
+// the broker is reachable. token authenticates against a broker started
+// with one; a user and password can instead be given in url.
+func ConnectNATS(url, name, token string) (*NATS, error) {
+	options := []nats.Option{
+		nats.Name(name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.ConnectHandler(func(c *nats.Conn) {
+			log.Printf("Connected to broker %s", c.ConnectedUrl())
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Broker connection lost: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Reconnected to broker %s", c.ConnectedUrl())
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			log.Printf("Broker error: %v", err)
+		}),
+	}
+	if token != "" {
+		options = append(options, nats.Token(token))
+	}
+	conn, err := nats.Connect(url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	return &NATS{conn: conn}, nil
+}
+
+func (n *NATS) Publish(subject string, data []byte) error {
+	return n.conn.Publish(subject, data)
+}
+
+func (n *NATS) Subscribe(subject string, handler func(data []byte)) (func(), error) {
+	sub, err := n.conn.Subscribe(subject, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() { sub.Unsubscribe() }, nil
+}
+
+func (n *NATS) Connected() bool {
+	return n.conn.IsConnected()
+}
+
+// Close sends what is still buffered before disconnecting.
+func (n *NATS) Close() {
+	if err := n.conn.FlushTimeout(2 * time.Second); err != nil && n.conn.IsConnected() {
+		log.Printf("Failed to flush broker connection: %v", err)
+	}
+	n.conn.Close()
+}
+
+// Embedded runs a NATS server inside this process, so an ingest node can be
+// the broker itself and a multi-node setup can be tried without installing
+// NATS.
+type Embedded struct {
+	server *server.Server
+}
+
+// StartEmbedded listens on addr, on 127.0.0.1 when addr has no host and on
+// a free port when the port is 0. With a token set clients must present it.
+func StartEmbedded(addr, token string) (*Embedded, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker port %q", portStr)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == 0 {
+		port = server.RANDOM_PORT
+	}
+
+	s, err := server.NewServer(&server.Options{Host: host, Port: port, Authorization: token, NoSigs: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create broker: %w", err)
+	}
+	s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		s.Shutdown()
+		return nil, fmt.Errorf("broker did not start listening on %s", addr)
+	}
+	return &Embedded{server: s}, nil
+}
+
+func (e *Embedded) ClientURL() string {
+	return e.server.ClientURL()
+}
+
+func (e *Embedded) Shutdown() {
+	e.server.Shutdown()
+	e.server.WaitForShutdown()
+}
@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestEmbeddedBrokerCarriesStream(t *testing.T) {
+	embedded, err := StartEmbedded(":0", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer embedded.Shutdown()
+	url := embedded.ClientURL()
+	if !strings.Contains(url, "127.0.0.1") {
+		t.Errorf("embedded broker listens on %s, want loopback by default", url)
+	}
+
+	if conn, err := nats.Connect(url); err == nil {
+		conn.Close()
+		t.Fatal("connected without the token")
+	}
+
+	ingest, err := ConnectNATS(url, "ingest", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingest.Close()
+	edge, err := ConnectNATS(url, "edge", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+
+	source := NewSource(edge, DefaultSubject)
+	source.Start()
+	defer source.Close()
+	// The subscription is registered once the server has seen it.
+	if err := edge.conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"eventType":"trade","symbol":"BTCUSDT","price":"37080.00"}`
+	if err := ingest.Publish(DefaultSubject, []byte(want)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-source.GetMessageChannel():
+		if string(got) != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the edge source received nothing")
+	}
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"cropto-dashboard/metrics"
+	"log"
+	"sync"
+)
+
+// Source reads a subject as an exchange.Source, so a node without exchange
+// connections runs the same bridge into its hub.
+type Source struct {
+	MessageChan chan []byte
+
+	broker  Broker
+	subject string
+
+	mutex       sync.Mutex
+	unsubscribe func()
+	closed      bool
+}
+
+// SourceBuffer is how many broker messages may queue before they are dropped.
+var SourceBuffer = 256
+
+func NewSource(b Broker, subject string) *Source {
+	return &Source{
+		MessageChan: make(chan []byte, SourceBuffer),
+		broker:      b,
+		subject:     subject,
+	}
+}
+
+func (s *Source) Start() {
+	unsubscribe, err := s.broker.Subscribe(s.subject, s.deliver)
+	if err != nil {
+		log.Printf("Failed to subscribe to %s: %v", s.subject, err)
+		return
+	}
+	s.mutex.Lock()
+	s.unsubscribe = unsubscribe
+	s.mutex.Unlock()
+}
+
+func (s *Source) GetMessageChannel() <-chan []byte {
+	return s.MessageChan
+}
+
+func (s *Source) deliver(data []byte) {
+	message := make([]byte, len(data))
+	copy(message, data)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.MessageChan <- message:
+	default:
+		log.Println("Broker message channel full, dropping message")
+		metrics.Dropped.WithLabelValues(metrics.DropBrokerFull).Inc()
+	}
+}
+
+func (s *Source) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.MessageChan)
+	unsubscribe := s.unsubscribe
+	s.mutex.Unlock()
+
+	// Outside the lock: the local broker holds its own lock while delivering.
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
@@ -0,0 +1,458 @@
+// Package config holds every setting of a node. Settings come from built-in
+// defaults, an optional YAML or TOML file, environment variables and command
+// line flags, each overriding the one before.
+package config
+
+import (
+	"cropto-dashboard/anomaly"
+	"cropto-dashboard/arbitrage"
+	"cropto-dashboard/auth"
+	"cropto-dashboard/broker"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/ratelimit"
+	"cropto-dashboard/rates"
+	"cropto-dashboard/replay"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+)
+
+// Fields are named by their `key` in files and their `env` variable. Nested
+// sections prefix the keys of their fields, and their env tag, when set,
+// prefixes the variables. A `secret` field is redacted in Redacted; "url"
+// only hides the password of a URL.
+type Config struct {
+	Server    Server    `key:"server"`
+	Exchange  Exchange  `key:"exchange"`
+	WebSocket WebSocket `key:"websocket"`
+	Stream    Stream    `key:"stream"`
+	Auth      Auth      `key:"auth"`
+	RateLimit RateLimit `key:"rate_limit"`
+	Broker    Broker    `key:"broker"`
+	Health    Health    `key:"health"`
+	Replay    Replay    `key:"replay"`
+	Recorder  Recorder  `key:"recorder"`
+	Analytics Analytics `key:"analytics"`
+	Anomaly   Anomaly   `key:"anomaly"`
+	Arbitrage Arbitrage `key:"arbitrage"`
+	Rates     Rates     `key:"rates"`
+
+	// file is the configuration file that was loaded, if any.
+	file string
+}
+
+type Server struct {
+	HTTPAddr string `key:"http_addr" env:"HTTP_ADDR"`
+	GRPCAddr string `key:"grpc_addr" env:"GRPC_ADDR"`
+	// Role is "ingest" to read the exchange or "edge" to read the broker.
+	Role    string `key:"role" env:"ROLE"`
+	DataDir string `key:"data_dir" env:"DATA_DIR"`
+	// TrustedProxies may set X-Forwarded-For.
+	TrustedProxies  []string `key:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	ShutdownSeconds int      `key:"shutdown_seconds" env:"SHUTDOWN_SECONDS"`
+}
+
+type Exchange struct {
+	// Symbols are tracked until the symbol list is first changed at runtime.
+	Symbols              []string `key:"symbols" env:"SYMBOLS"`
+	SymbolsPerConnection int      `key:"symbols_per_connection" env:"SYMBOLS_PER_CONNECTION"`
+	BinanceWSURL         string   `key:"binance_ws_url" env:"BINANCE_WS_URL"`
+	BinanceRESTURL       string   `key:"binance_rest_url" env:"BINANCE_REST_URL"`
+	CoinbaseWSURL        string   `key:"coinbase_ws_url" env:"COINBASE_WS_URL"`
+	MaxReconnectSeconds  int      `key:"max_reconnect_seconds" env:"MAX_RECONNECT_SECONDS"`
+	ConnectionBuffer     int      `key:"connection_buffer" env:"EXCHANGE_CONNECTION_BUFFER"`
+	StreamBuffer         int      `key:"stream_buffer" env:"EXCHANGE_STREAM_BUFFER"`
+	WeightLimit          int      `key:"weight_limit" env:"BINANCE_WEIGHT_LIMIT"`
+	MaxParallel          int      `key:"max_parallel" env:"BINANCE_MAX_PARALLEL"`
+}
+
+type WebSocket struct {
+	PongWaitSeconds int `key:"pong_wait_seconds" env:"WS_PONG_WAIT_SECONDS"`
+	MaxMessageSize  int `key:"max_message_size" env:"WS_MAX_MESSAGE_SIZE"`
+	SendBuffer      int `key:"send_buffer" env:"WS_SEND_BUFFER"`
+	BroadcastBuffer int `key:"broadcast_buffer" env:"WS_BROADCAST_BUFFER"`
+	ListenerBuffer  int `key:"listener_buffer" env:"WS_LISTENER_BUFFER"`
+	BrokerBuffer    int `key:"broker_buffer" env:"BROKER_BUFFER"`
+}
+
+type Stream struct {
+	ReplaySize           int `key:"replay_size" env:"STREAM_REPLAY_SIZE"`
+	HeartbeatSeconds     int `key:"heartbeat_seconds" env:"STREAM_HEARTBEAT_SECONDS"`
+	OverviewPushSeconds  int `key:"overview_push_seconds" env:"OVERVIEW_PUSH_SECONDS"`
+	OverviewSize         int `key:"overview_size" env:"OVERVIEW_SIZE"`
+	PortfolioPushSeconds int `key:"portfolio_push_seconds" env:"PORTFOLIO_PUSH_SECONDS"`
+	RiskPushSeconds      int `key:"risk_push_seconds" env:"RISK_PUSH_SECONDS"`
+}
+
+type Auth struct {
+	Policy         string   `key:"policy" env:"AUTH_POLICY"`
+	AllowedOrigins []string `key:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	JWTSecret      string   `key:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true"`
+	SessionMinutes int      `key:"session_minutes" env:"AUTH_SESSION_MINUTES"`
+}
+
+type RateLimit struct {
+	Anonymous  Quota `key:"anonymous" env:"RATE_ANONYMOUS_"`
+	User       Quota `key:"user" env:"RATE_USER_"`
+	Admin      Quota `key:"admin" env:"RATE_ADMIN_"`
+	MaxSockets int   `key:"max_sockets" env:"MAX_SOCKETS"`
+}
+
+// Quota mirrors ratelimit.Quota; a zero rate or socket count is unlimited.
+type Quota struct {
+	PerMinute         float64 `key:"per_minute" env:"PER_MINUTE"`
+	Burst             int     `key:"burst" env:"BURST"`
+	UpstreamPerMinute float64 `key:"upstream_per_minute" env:"UPSTREAM_PER_MINUTE"`
+	UpstreamBurst     int     `key:"upstream_burst" env:"UPSTREAM_BURST"`
+	Sockets           int     `key:"sockets" env:"SOCKETS"`
+}
+
+type Broker struct {
+	URL     string `key:"url" env:"BROKER_URL" secret:"url"`
+	Listen  string `key:"listen" env:"BROKER_LISTEN"`
+	Subject string `key:"subject" env:"BROKER_SUBJECT"`
+	Token   string `key:"token" env:"BROKER_TOKEN" secret:"true"`
+}
+
+type Health struct {
+	StreamStaleSeconds int  `key:"stream_stale_seconds" env:"HEALTH_STREAM_STALE_SECONDS"`
+	SymbolStaleSeconds int  `key:"symbol_stale_seconds" env:"HEALTH_SYMBOL_STALE_SECONDS"`
+	RESTSeconds        int  `key:"rest_seconds" env:"HEALTH_REST_SECONDS"`
+	RequireREST        bool `key:"require_rest" env:"HEALTH_REQUIRE_REST"`
+}
+
+// Replay plays recorded data instead of the exchange when Dir is set.
+type Replay struct {
+	Dir     string   `key:"dir" env:"REPLAY_DIR"`
+	Speed   string   `key:"speed" env:"REPLAY_SPEED"`
+	Start   string   `key:"start" env:"REPLAY_START"`
+	End     string   `key:"end" env:"REPLAY_END"`
+	Symbols []string `key:"symbols" env:"REPLAY_SYMBOLS"`
+	Loop    bool     `key:"loop" env:"REPLAY_LOOP"`
+}
+
+// Recorder records the stream when Dir is set.
+type Recorder struct {
+	Dir            string `key:"dir" env:"RECORDER_DIR"`
+	SegmentMB      int    `key:"segment_mb" env:"RECORDER_SEGMENT_MB"`
+	SegmentMinutes int    `key:"segment_minutes" env:"RECORDER_SEGMENT_MINUTES"`
+	RetentionHours int    `key:"retention_hours" env:"RECORDER_RETENTION_HOURS"`
+	MaxTotalMB     int    `key:"max_total_mb" env:"RECORDER_MAX_TOTAL_MB"`
+	Raw            bool   `key:"raw" env:"RECORDER_RAW"`
+}
+
+type Analytics struct {
+	CacheSeconds int `key:"cache_seconds" env:"ANALYTICS_CACHE_SECONDS"`
+}
+
+type Anomaly struct {
+	PriceK          float64 `key:"price_k" env:"ANOMALY_PRICE_K"`
+	VolumeK         float64 `key:"volume_k" env:"ANOMALY_VOLUME_K"`
+	VolumeRatio     float64 `key:"volume_ratio" env:"ANOMALY_VOLUME_RATIO"`
+	BucketSeconds   int     `key:"bucket_seconds" env:"ANOMALY_BUCKET_SECONDS"`
+	StallSeconds    int     `key:"stall_seconds" env:"ANOMALY_STALL_SECONDS"`
+	CooldownSeconds int     `key:"cooldown_seconds" env:"ANOMALY_COOLDOWN_SECONDS"`
+}
+
+// Arbitrage keeps the decimal settings as text, parsed by the arbitrage
+// package. FeesBps is merged over the default venue fees.
+type Arbitrage struct {
+	Enabled        bool   `key:"enabled" env:"ARBITRAGE"`
+	MinSeconds     int    `key:"min_seconds" env:"ARBITRAGE_MIN_SECONDS"`
+	ThresholdBps   string `key:"threshold_bps" env:"ARBITRAGE_THRESHOLD_BPS"`
+	Notional       string `key:"notional" env:"ARBITRAGE_NOTIONAL"`
+	FeesBps        string `key:"fees_bps" env:"ARBITRAGE_FEES_BPS"`
+	WithdrawalFees string `key:"withdrawal_fees" env:"ARBITRAGE_WITHDRAWAL_FEES"`
+}
+
+// Rates overrides the default FX table, as "EUR=0.92,GBP=0.79".
+type Rates struct {
+	FX string `key:"fx" env:"FX_RATES"`
+}
+
+func Default() *Config {
+	limits := ratelimit.DefaultConfig()
+	quota := func(tier string) Quota {
+		q := limits.Tiers[tier]
+		return Quota{
+			PerMinute:         q.PerMinute,
+			Burst:             q.Burst,
+			UpstreamPerMinute: q.UpstreamPerMinute,
+			UpstreamBurst:     q.UpstreamBurst,
+			Sockets:           q.Sockets,
+		}
+	}
+	detector := anomaly.DefaultConfig()
+	arb := arbitrage.DefaultConfig()
+
+	return &Config{
+		Server: Server{
+			HTTPAddr:        ":8000",
+			GRPCAddr:        ":9000",
+			Role:            "ingest",
+			DataDir:         "data",
+			ShutdownSeconds: 5,
+		},
+		Exchange: Exchange{
+			Symbols:             append([]string(nil), exchange.DefaultSymbols...),
+			BinanceWSURL:        exchange.BinanceWSURL,
+			BinanceRESTURL:      exchange.BinanceRESTURL,
+			CoinbaseWSURL:       exchange.CoinbaseWSURL,
+			MaxReconnectSeconds: int(exchange.MaxReconnectDelay.Seconds()),
+			ConnectionBuffer:    exchange.ConnectionBuffer,
+			StreamBuffer:        exchange.StreamBuffer,
+			WeightLimit:         exchange.BinanceWeightLimit,
+			MaxParallel:         exchange.MaxParallelRequests,
+		},
+		WebSocket: WebSocket{
+			PongWaitSeconds: 60,
+			MaxMessageSize:  512,
+			SendBuffer:      256,
+			BroadcastBuffer: 256,
+			ListenerBuffer:  256,
+			BrokerBuffer:    broker.SourceBuffer,
+		},
+		Stream: Stream{
+			ReplaySize:           1024,
+			HeartbeatSeconds:     15,
+			OverviewPushSeconds:  5,
+			OverviewSize:         5,
+			PortfolioPushSeconds: 2,
+			RiskPushSeconds:      300,
+		},
+		Auth: Auth{
+			Policy:         auth.PolicyRequired,
+			AllowedOrigins: []string{"http://localhost:3000", "http://localhost:5173"},
+			SessionMinutes: 60,
+		},
+		RateLimit: RateLimit{
+			Anonymous:  quota(ratelimit.TierAnonymous),
+			User:       quota(auth.RoleUser),
+			Admin:      quota(auth.RoleAdmin),
+			MaxSockets: limits.MaxSockets,
+		},
+		Broker: Broker{Subject: broker.DefaultSubject},
+		Health: Health{
+			StreamStaleSeconds: 30,
+			SymbolStaleSeconds: 120,
+			RESTSeconds:        30,
+		},
+		Replay: Replay{Speed: "1"},
+		Recorder: Recorder{
+			SegmentMB:      64,
+			SegmentMinutes: 60,
+			RetentionHours: 168,
+			MaxTotalMB:     10240,
+			Raw:            true,
+		},
+		Analytics: Analytics{CacheSeconds: 300},
+		Anomaly: Anomaly{
+			PriceK:          detector.PriceK,
+			VolumeK:         detector.VolumeK,
+			VolumeRatio:     detector.VolumeMinRatio,
+			BucketSeconds:   int(detector.VolumeBucket.Seconds()),
+			StallSeconds:    int(detector.StallAfter.Seconds()),
+			CooldownSeconds: int(detector.Cooldown.Seconds()),
+		},
+		Arbitrage: Arbitrage{
+			MinSeconds:   int(arb.MinDuration.Seconds()),
+			ThresholdBps: arb.ThresholdBps.String(),
+			Notional:     arb.Notional.String(),
+		},
+	}
+}
+
+// File returns the configuration file that was loaded, empty when there was
+// none.
+func (c *Config) File() string {
+	return c.file
+}
+
+// RateLimits converts the rate limit section for ratelimit.New.
+func (c *Config) RateLimits() ratelimit.Config {
+	cfg := ratelimit.DefaultConfig()
+	for tier, q := range map[string]Quota{
+		ratelimit.TierAnonymous: c.RateLimit.Anonymous,
+		auth.RoleUser:           c.RateLimit.User,
+		auth.RoleAdmin:          c.RateLimit.Admin,
+	} {
+		cfg.Tiers[tier] = ratelimit.Quota(q)
+	}
+	cfg.MaxSockets = c.RateLimit.MaxSockets
+	return cfg
+}
+
+var symbolPattern = regexp.MustCompile(`^[A-Za-z0-9]{5,20}$`)
+
+// Validate reports every invalid setting at once, named by its key.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	positive := func(key string, v int) {
+		if v <= 0 {
+			fail(key, "must be positive, got %d", v)
+		}
+	}
+	nonNegative := func(key string, v float64) {
+		if v < 0 {
+			fail(key, "must not be negative, got %v", v)
+		}
+	}
+	address := func(key, addr string) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			fail(key, "invalid address %q, expected host:port or :port", addr)
+		}
+	}
+	endpoint := func(key, raw string, schemes ...string) {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			fail(key, "invalid URL %q", raw)
+			return
+		}
+		for _, s := range schemes {
+			if u.Scheme == s {
+				return
+			}
+		}
+		fail(key, "unsupported scheme %q, expected one of %v", u.Scheme, schemes)
+	}
+
+	address("server.http_addr", c.Server.HTTPAddr)
+	address("server.grpc_addr", c.Server.GRPCAddr)
+	if c.Server.Role != "ingest" && c.Server.Role != "edge" {
+		fail("server.role", "invalid role %q, expected ingest or edge", c.Server.Role)
+	}
+	if c.Server.DataDir == "" {
+		fail("server.data_dir", "must not be empty")
+	}
+	positive("server.shutdown_seconds", c.Server.ShutdownSeconds)
+	for _, p := range c.Server.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				fail("server.trusted_proxies", "invalid IP or CIDR %q", p)
+			}
+		}
+	}
+
+	if len(c.Exchange.Symbols) == 0 {
+		fail("exchange.symbols", "must not be empty")
+	}
+	for _, s := range c.Exchange.Symbols {
+		if !symbolPattern.MatchString(s) {
+			fail("exchange.symbols", "invalid symbol %q", s)
+		}
+	}
+	nonNegative("exchange.symbols_per_connection", float64(c.Exchange.SymbolsPerConnection))
+	endpoint("exchange.binance_ws_url", c.Exchange.BinanceWSURL, "ws", "wss")
+	endpoint("exchange.binance_rest_url", c.Exchange.BinanceRESTURL, "http", "https")
+	endpoint("exchange.coinbase_ws_url", c.Exchange.CoinbaseWSURL, "ws", "wss")
+	positive("exchange.max_reconnect_seconds", c.Exchange.MaxReconnectSeconds)
+	positive("exchange.connection_buffer", c.Exchange.ConnectionBuffer)
+	positive("exchange.stream_buffer", c.Exchange.StreamBuffer)
+	positive("exchange.weight_limit", c.Exchange.WeightLimit)
+	positive("exchange.max_parallel", c.Exchange.MaxParallel)
+
+	positive("websocket.pong_wait_seconds", c.WebSocket.PongWaitSeconds)
+	positive("websocket.max_message_size", c.WebSocket.MaxMessageSize)
+	positive("websocket.send_buffer", c.WebSocket.SendBuffer)
+	positive("websocket.broadcast_buffer", c.WebSocket.BroadcastBuffer)
+	positive("websocket.listener_buffer", c.WebSocket.ListenerBuffer)
+	positive("websocket.broker_buffer", c.WebSocket.BrokerBuffer)
+
+	nonNegative("stream.replay_size", float64(c.Stream.ReplaySize))
+	positive("stream.heartbeat_seconds", c.Stream.HeartbeatSeconds)
+	nonNegative("stream.overview_push_seconds", float64(c.Stream.OverviewPushSeconds))
+	positive("stream.overview_size", c.Stream.OverviewSize)
+	positive("stream.portfolio_push_seconds", c.Stream.PortfolioPushSeconds)
+	nonNegative("stream.risk_push_seconds", float64(c.Stream.RiskPushSeconds))
+
+	if c.Auth.Policy != auth.PolicyOpen && c.Auth.Policy != auth.PolicyRequired {
+		fail("auth.policy", "invalid policy %q, expected open or required", c.Auth.Policy)
+	}
+	positive("auth.session_minutes", c.Auth.SessionMinutes)
+
+	for tier, q := range map[string]Quota{"anonymous": c.RateLimit.Anonymous, "user": c.RateLimit.User, "admin": c.RateLimit.Admin} {
+		prefix := "rate_limit." + tier + "."
+		nonNegative(prefix+"per_minute", q.PerMinute)
+		nonNegative(prefix+"burst", float64(q.Burst))
+		nonNegative(prefix+"upstream_per_minute", q.UpstreamPerMinute)
+		nonNegative(prefix+"upstream_burst", float64(q.UpstreamBurst))
+		nonNegative(prefix+"sockets", float64(q.Sockets))
+	}
+	nonNegative("rate_limit.max_sockets", float64(c.RateLimit.MaxSockets))
+
+	if c.Broker.URL != "" {
+		endpoint("broker.url", c.Broker.URL, "nats", "tls")
+	}
+	if c.Broker.Listen != "" {
+		address("broker.listen", c.Broker.Listen)
+		host, _, _ := net.SplitHostPort(c.Broker.Listen)
+		if ip := net.ParseIP(host); host != "" && host != "localhost" && (ip == nil || !ip.IsLoopback()) && c.Broker.Token == "" {
+			fail("broker.token", "required when the embedded broker listens on %s", host)
+		}
+	}
+	if c.Server.Role == "edge" && c.Broker.URL == "" && c.Broker.Listen == "" {
+		fail("broker.url", "edge nodes need a broker URL")
+	}
+	if c.Broker.Subject == "" {
+		fail("broker.subject", "must not be empty")
+	}
+
+	nonNegative("health.stream_stale_seconds", float64(c.Health.StreamStaleSeconds))
+	nonNegative("health.symbol_stale_seconds", float64(c.Health.SymbolStaleSeconds))
+	nonNegative("health.rest_seconds", float64(c.Health.RESTSeconds))
+
+	if _, err := replay.ParseSpeed(c.Replay.Speed); err != nil {
+		fail("replay.speed", "%v", err)
+	}
+	if _, err := replay.ParseTime(c.Replay.Start); err != nil {
+		fail("replay.start", "%v", err)
+	}
+	if _, err := replay.ParseTime(c.Replay.End); err != nil {
+		fail("replay.end", "%v", err)
+	}
+
+	positive("recorder.segment_mb", c.Recorder.SegmentMB)
+	positive("recorder.segment_minutes", c.Recorder.SegmentMinutes)
+	nonNegative("recorder.retention_hours", float64(c.Recorder.RetentionHours))
+	nonNegative("recorder.max_total_mb", float64(c.Recorder.MaxTotalMB))
+
+	positive("analytics.cache_seconds", c.Analytics.CacheSeconds)
+
+	if c.Anomaly.PriceK <= 0 {
+		fail("anomaly.price_k", "must be positive, got %v", c.Anomaly.PriceK)
+	}
+	if c.Anomaly.VolumeK <= 0 {
+		fail("anomaly.volume_k", "must be positive, got %v", c.Anomaly.VolumeK)
+	}
+	nonNegative("anomaly.volume_ratio", c.Anomaly.VolumeRatio)
+	positive("anomaly.bucket_seconds", c.Anomaly.BucketSeconds)
+	positive("anomaly.stall_seconds", c.Anomaly.StallSeconds)
+	nonNegative("anomaly.cooldown_seconds", float64(c.Anomaly.CooldownSeconds))
+
+	nonNegative("arbitrage.min_seconds", float64(c.Arbitrage.MinSeconds))
+	if _, err := decimal.Parse(c.Arbitrage.ThresholdBps); err != nil {
+		fail("arbitrage.threshold_bps", "%v", err)
+	}
+	if v, err := decimal.Parse(c.Arbitrage.Notional); err != nil || !v.IsPositive() {
+		fail("arbitrage.notional", "must be a positive number, got %q", c.Arbitrage.Notional)
+	}
+	if _, err := arbitrage.ParseTable(c.Arbitrage.FeesBps, false); err != nil {
+		fail("arbitrage.fees_bps", "%v", err)
+	}
+	if _, err := arbitrage.ParseTable(c.Arbitrage.WithdrawalFees, true); err != nil {
+		fail("arbitrage.withdrawal_fees", "%v", err)
+	}
+
+	if c.Rates.FX != "" {
+		if _, err := rates.ParseFX(c.Rates.FX); err != nil {
+			fail("rates.fx", "%v", err)
+		}
+	}
+	return errors.Join(errs...)
+}
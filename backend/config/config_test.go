@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedBrokerNeedsTokenOffLoopback(t *testing.T) {
+	for listen, ok := range map[string]bool{
+		":4222":          true,
+		"127.0.0.1:4222": true,
+		"localhost:4222": true,
+		"[::1]:4222":     true,
+		"0.0.0.0:4222":   false,
+		"10.0.0.5:4222":  false,
+	} {
+		cfg := Default()
+		cfg.Broker.Listen = listen
+		if err := cfg.Validate(); (err == nil) != ok {
+			t.Errorf("%s: got %v", listen, err)
+		}
+		cfg.Broker.Token = "secret"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s with a token: %v", listen, err)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// field is one setting, found by walking the tags of Config.
+type field struct {
+	key    string
+	env    string
+	secret string
+	value  reflect.Value
+}
+
+func (c *Config) fields() []field {
+	var out []field
+	var walk func(v reflect.Value, key, env string)
+	walk = func(v reflect.Value, key, env string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, ok := sf.Tag.Lookup("key")
+			if !ok {
+				continue
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), name, env+sf.Tag.Get("env"))
+				continue
+			}
+			out = append(out, field{
+				key:    name,
+				env:    env + sf.Tag.Get("env"),
+				secret: sf.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "", "")
+	return out
+}
+
+// flagName turns HTTP_ADDR into http-addr.
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// Load reads the configuration from the defaults, the file named by -config
+// or CONFIG_FILE, the environment and args, in that order, and validates it.
+// Every setting has a flag named after its variable: HTTP_ADDR is -http-addr.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	fs := flag.NewFlagSet("cropto-dashboard", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file (env CONFIG_FILE)")
+	type flagValue struct {
+		field field
+		value string
+	}
+	var flagged []flagValue
+	for _, f := range fields {
+		f := f
+		usage := f.key + " (env " + f.env + ")"
+		record := func(v string) error {
+			flagged = append(flagged, flagValue{f, v})
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(flagName(f.env), usage, record)
+		} else {
+			fs.Func(flagName(f.env), usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := cfg.loadFile(*file, fields); err != nil {
+			return nil, err
+		}
+		cfg.file = *file
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if v := os.Getenv(f.env); v != "" {
+			if err := set(f.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+	for _, fv := range flagged {
+		if err := set(fv.field.value, fv.value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", flagName(fv.field.env), err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies a YAML or TOML file, chosen by its extension. Unknown
+// keys are an error so that typos do not go unnoticed.
+func (c *Config) loadFile(path string, fields []field) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	doc := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+	values := make(map[string]interface{})
+	flatten("", doc, values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		if err := set(f.value, values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flatten turns nested sections into dotted keys.
+func flatten(prefix string, doc map[string]interface{}, out map[string]interface{}) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if section, ok := v.(map[string]interface{}); ok {
+			flatten(key, section, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// set assigns raw, a string from the environment or flags or a decoded file
+// value, to a field. Lists may be given as comma separated text.
+func set(v reflect.Value, raw interface{}) error {
+	if v.Kind() == reflect.Slice {
+		var items []string
+		if list, ok := raw.([]interface{}); ok {
+			for _, item := range list {
+				if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+					items = append(items, s)
+				}
+			}
+		} else {
+			for _, item := range strings.Split(fmt.Sprint(raw), ",") {
+				if s := strings.TrimSpace(item); s != "" {
+					items = append(items, s)
+				}
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	s := fmt.Sprint(raw)
+	if f, ok := raw.(float64); ok {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s = strings.TrimSpace(s)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns the settings as nested sections for display, with
+// secrets hidden.
+func (c *Config) Redacted() map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, f := range c.fields() {
+		value := f.value.Interface()
+		switch f.secret {
+		case "true":
+			if f.value.String() != "" {
+				value = "[redacted]"
+			}
+		case "url":
+			if u, err := url.Parse(f.value.String()); err == nil {
+				value = u.Redacted()
+			} else if f.value.String() != "" {
+				value = "[redacted]"
+			}
+		}
+		if f.value.Kind() == reflect.Slice && f.value.IsNil() {
+			value = []string{}
+		}
+
+		parts := strings.Split(f.key, ".")
+		section := out
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[part] = next
+			}
+			section = next
+		}
+		section[parts[len(parts)-1]] = value
+	}
+	return out
+}
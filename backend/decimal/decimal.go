@@ -0,0 +1,316 @@
+// Package decimal is an exact fixed-point number for prices, quantities and
+// money. A Decimal is an arbitrary precision integer coefficient and a scale
+// (digits after the point), so "0.10000000" parses and prints back unchanged.
+package decimal
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type Decimal struct {
+	coef  *big.Int // nil means zero
+	scale int32
+}
+
+var (
+	Zero = Decimal{}
+	One  = NewFromInt(1)
+
+	bigZero = new(big.Int)
+	bigTen  = big.NewInt(10)
+)
+
+// New returns coef × 10^-scale, e.g. New(150, 2) is 1.50.
+func New(coef int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(coef), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(coef), scale: scale}
+}
+
+func NewFromInt(i int64) Decimal {
+	return Decimal{coef: big.NewInt(i)}
+}
+
+// NewFromFloat converts through the shortest decimal representation of f,
+// so 0.1 becomes exactly 0.1.
+func NewFromFloat(f float64) Decimal {
+	d, err := Parse(strconv.FormatFloat(f, 'e', -1, 64))
+	if err != nil {
+		return Zero
+	}
+	return d
+}
+
+// Parse limits what it accepts, as its input comes from clients: a huge
+// exponent or digit count makes every later operation on the value slow.
+const (
+	MaxExponent = 1000
+	MaxDigits   = 100
+)
+
+// Parse reads plain or exponent notation ("65000.01", "-1.5e-3").
+func Parse(s string) (Decimal, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("decimal: empty string")
+	}
+
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Zero, fmt.Errorf("decimal: invalid exponent in %q", orig)
+		}
+		if e > MaxExponent || e < -MaxExponent {
+			return Zero, fmt.Errorf("decimal: exponent out of range in %q", orig)
+		}
+		exp = e
+		s = s[:i]
+	}
+
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" {
+		return Zero, fmt.Errorf("decimal: invalid number %q", orig)
+	}
+	if len(digits) > MaxDigits {
+		return Zero, fmt.Errorf("decimal: too many digits in %.20q...", orig)
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Zero, fmt.Errorf("decimal: invalid number %q", orig)
+		}
+	}
+
+	coef, _ := new(big.Int).SetString(digits, 10)
+	if neg {
+		coef.Neg(coef)
+	}
+
+	scale := int64(len(fracPart)) - exp
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// MustParse is Parse for constants, it panics on invalid input.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return bigZero
+	}
+	return d.coef
+}
+
+// rescale returns the coefficient of d at a larger scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.int()
+	}
+	return new(big.Int).Mul(d.int(), pow10(scale-d.scale))
+}
+
+func align(a, b Decimal) (*big.Int, *big.Int, int32) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{coef: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{coef: new(big.Int).Sub(a, b), scale: scale}
+}
+
+// Mul is exact, the result scale is the sum of both scales.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), o.int()), scale: d.scale + o.scale}
+}
+
+// Div returns d / o rounded half away from zero to places digits. It panics
+// when o is zero, like integer division.
+func (d Decimal) Div(o Decimal, places int32) Decimal {
+	if o.IsZero() {
+		panic("decimal: division by zero")
+	}
+
+	// d/o = dc·10^os / (oc·10^ds), computed with one guard digit for rounding.
+	num := new(big.Int).Mul(d.int(), pow10(o.scale+places+1))
+	den := new(big.Int).Mul(o.int(), pow10(d.scale))
+	q := new(big.Int).Quo(num, den)
+	return roundGuard(q, places)
+}
+
+// roundGuard drops the last digit of q, rounding half away from zero.
+func roundGuard(q *big.Int, scale int32) Decimal {
+	r := new(big.Int)
+	q, r = new(big.Int).QuoRem(q, bigTen, r)
+	if r.CmpAbs(big.NewInt(5)) >= 0 {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Decimal{coef: q, scale: scale}
+}
+
+// Round returns d with exactly places digits after the point, rounding half
+// away from zero. Rounding to a larger scale pads with zeros.
+func (d Decimal) Round(places int32) Decimal {
+	if places >= d.scale {
+		return Decimal{coef: d.rescale(places), scale: places}
+	}
+	q := new(big.Int).Quo(d.int(), pow10(d.scale-places-1))
+	return roundGuard(q, places)
+}
+
+// Truncate drops digits beyond places without rounding.
+func (d Decimal) Truncate(places int32) Decimal {
+	if places >= d.scale {
+		return d
+	}
+	return Decimal{coef: new(big.Int).Quo(d.int(), pow10(d.scale-places)), scale: places}
+}
+
+// Normalize removes trailing zeros after the point.
+func (d Decimal) Normalize() Decimal {
+	if d.IsZero() {
+		return Zero
+	}
+	coef, scale := new(big.Int).Set(d.coef), d.scale
+	r := new(big.Int)
+	for scale > 0 {
+		q, rem := new(big.Int).QuoRem(coef, bigTen, r)
+		if rem.Sign() != 0 {
+			break
+		}
+		coef, scale = q, scale-1
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+func (d Decimal) Equal(o Decimal) bool       { return d.Cmp(o) == 0 }
+func (d Decimal) LessThan(o Decimal) bool    { return d.Cmp(o) < 0 }
+func (d Decimal) GreaterThan(o Decimal) bool { return d.Cmp(o) > 0 }
+func (d Decimal) Sign() int                  { return d.int().Sign() }
+func (d Decimal) IsZero() bool               { return d.Sign() == 0 }
+func (d Decimal) IsNegative() bool           { return d.Sign() < 0 }
+func (d Decimal) IsPositive() bool           { return d.Sign() > 0 }
+func (d Decimal) Scale() int32               { return d.scale }
+
+func Min(a, b Decimal) Decimal {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func Max(a, b Decimal) Decimal {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// Float64 is for statistics and indicators, never for amounts that are
+// stored or summed.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String prints plain notation with the full scale, e.g. "0.10000000".
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) - len(digits) + 1; pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		cut := len(digits) - int(d.scale)
+		digits = digits[:cut] + "." + digits[cut:]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON writes a string so no JSON reader rounds the value through a float.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts strings and bare numbers. null and "" read as zero.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*d = Zero
+		return nil
+	}
+	s := string(data)
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		s = string(data[1 : len(data)-1])
+		if strings.TrimSpace(s) == "" {
+			*d = Zero
+			return nil
+		}
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
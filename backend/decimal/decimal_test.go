@@ -0,0 +1,60 @@
+package decimal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"65000.01", "65000.01"},
+		{"0.10000000", "0.10000000"},
+		{"-1.5e-3", "-0.0015"},
+		{"1.5E2", "150"},
+		{"+7", "7"},
+		{" 42 ", "42"},
+		{"1e1000", "1" + strings.Repeat("0", 1000)},
+	}
+	for _, c := range cases {
+		d, err := Parse(c.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.in, err)
+			continue
+		}
+		if d.String() != c.want {
+			t.Errorf("Parse(%q) = %s, want %s", c.in, d, c.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, in := range []string{
+		"", "abc", "1.2.3", "1e", "--1", ".",
+		"1e1001", "1e-1001", "1e50000000", "1e-50000000",
+		strings.Repeat("9", MaxDigits+1),
+		"0." + strings.Repeat("1", MaxDigits),
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%.30q) should fail", in)
+		}
+	}
+}
+
+func BenchmarkParseOversized(b *testing.B) {
+	digits := strings.Repeat("9", 100000)
+	for i := 0; i < b.N; i++ {
+		Parse("1e50000000")
+		Parse(digits)
+	}
+}
+
+func TestNewFromFloat(t *testing.T) {
+	if got := NewFromFloat(0.1).String(); got != "0.1" {
+		t.Errorf("NewFromFloat(0.1) = %s", got)
+	}
+	if got := NewFromFloat(1e300); got.IsZero() {
+		t.Error("NewFromFloat(1e300) should not be zero")
+	}
+}
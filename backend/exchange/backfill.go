@@ -0,0 +1,239 @@
+package exchange
+
+import (
+	"cropto-dashboard/types"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Binance pushes @ticker every second, so a longer silence on a symbol means
+// the connection dropped or messages were lost.
+const DefaultGapThreshold = 10 * time.Second
+
+// aggTrades only accepts a one hour window, older gaps are backfilled partially.
+const maxTradeBackfill = time.Hour
+
+type streamEvent struct {
+	Symbol     string `json:"symbol"`
+	EventType  string `json:"eventType"`
+	Timestamp  int64  `json:"timestamp"`
+	TradeID    int64  `json:"tradeId"`
+	OpenTime   int64  `json:"openTime"`
+	Backfilled bool   `json:"backfilled"`
+}
+
+func parseStreamEvent(message []byte) (streamEvent, bool) {
+	var ev streamEvent
+	if err := json.Unmarshal(message, &ev); err != nil || ev.Symbol == "" {
+		return ev, false
+	}
+	return ev, true
+}
+
+type gap struct {
+	Symbol      string
+	FromTime    int64
+	ToTime      int64
+	FromTradeID int64
+	ToTradeID   int64
+	Trades      bool
+	Candles     bool
+}
+
+type symbolState struct {
+	lastTradeID   int64
+	lastTradeTime int64
+	lastEventTime int64
+	// lastSeen is when the symbol last arrived here, in local time.
+	lastSeen time.Time
+}
+
+// gapDetector tracks the last trade ID and event time per symbol. Binance trade
+// IDs are sequential per symbol, so any jump means trades were missed.
+type gapDetector struct {
+	threshold time.Duration
+	mutex     sync.Mutex
+	symbols   map[string]*symbolState
+	// lastDrop is when a full channel here last dropped a message.
+	lastDrop time.Time
+}
+
+func newGapDetector(threshold time.Duration) *gapDetector {
+	return &gapDetector{
+		threshold: threshold,
+		symbols:   make(map[string]*symbolState),
+	}
+}
+
+func (g *gapDetector) observe(ev streamEvent) (gap, bool) {
+	if ev.Backfilled || (ev.EventType != "trade" && ev.EventType != "ticker") {
+		return gap{}, false
+	}
+
+	now := time.Now()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	st, ok := g.symbols[ev.Symbol]
+	if !ok {
+		st = &symbolState{}
+		g.symbols[ev.Symbol] = st
+	}
+	// Messages we dropped ourselves are not backfilled: the drop means the
+	// node is already behind, and REST calls would only add load. Any drop
+	// since the symbol was last seen counts, as drops are not per symbol.
+	dropped := !st.lastSeen.IsZero() && g.lastDrop.After(st.lastSeen)
+	st.lastSeen = now
+
+	found := gap{Symbol: ev.Symbol}
+
+	if ev.EventType == "trade" {
+		if st.lastTradeID > 0 && ev.TradeID > st.lastTradeID+1 {
+			found.Trades = true
+			found.FromTradeID = st.lastTradeID
+			found.ToTradeID = ev.TradeID
+			found.FromTime = st.lastTradeTime
+			found.ToTime = ev.Timestamp
+		}
+		if ev.TradeID > st.lastTradeID {
+			st.lastTradeID = ev.TradeID
+			st.lastTradeTime = ev.Timestamp
+		}
+	}
+
+	if st.lastEventTime > 0 && ev.Timestamp-st.lastEventTime > g.threshold.Milliseconds() {
+		found.Candles = true
+		if found.FromTime == 0 || st.lastEventTime < found.FromTime {
+			found.FromTime = st.lastEventTime
+		}
+		found.ToTime = max(found.ToTime, ev.Timestamp)
+	}
+	if ev.Timestamp > st.lastEventTime {
+		st.lastEventTime = ev.Timestamp
+	}
+
+	return found, (found.Trades || found.Candles) && !dropped
+}
+
+// markDrop records that a message was dropped before reaching the detector.
+func (g *gapDetector) markDrop() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.lastDrop = time.Now()
+}
+
+func (g *gapDetector) forget(symbol string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.symbols, symbol)
+}
+
+// backfill recovers a gap from REST and feeds the result through the normal
+// message channel, flagged as backfilled.
+func (m *ConnectionManager) backfill(g gap) {
+	defer m.wg.Done()
+
+	select {
+	case m.backfillSem <- struct{}{}:
+		defer func() { <-m.backfillSem }()
+	case <-m.quit:
+		return
+	}
+
+	log.Printf("Backfilling %s from %s to %s (trades: %v, candles: %v)", g.Symbol,
+		time.UnixMilli(g.FromTime).Format(time.RFC3339), time.UnixMilli(g.ToTime).Format(time.RFC3339),
+		g.Trades, g.Candles)
+
+	recovered := 0
+
+	if g.Trades {
+		start := max(g.FromTime, g.ToTime-maxTradeBackfill.Milliseconds())
+		trades, err := GetAggTrades(g.Symbol, start, g.ToTime)
+		if err != nil {
+			log.Printf("Trade backfill for %s failed: %v", g.Symbol, err)
+		}
+
+		for _, trade := range gapTrades(g, trades) {
+			msg, _ := json.Marshal(trade)
+			if !m.emit(msg) {
+				return
+			}
+			recovered++
+		}
+	}
+
+	if g.Candles {
+		start := g.FromTime - g.FromTime%time.Minute.Milliseconds()
+		candles, err := GetCandlesRange(g.Symbol, "1m", start, g.ToTime)
+		if err != nil {
+			log.Printf("Candle backfill for %s failed: %v", g.Symbol, err)
+		}
+
+		for _, c := range candles {
+			msg, _ := json.Marshal(types.CandleMessage{
+				Symbol:     g.Symbol,
+				Interval:   "1m",
+				OpenTime:   c.OpenTime,
+				Open:       c.Open,
+				High:       c.High,
+				Low:        c.Low,
+				Close:      c.Close,
+				Volume:     c.Volume,
+				CloseTime:  c.CloseTime,
+				EventType:  "candle",
+				Backfilled: true,
+			})
+			if !m.emit(msg) {
+				return
+			}
+			recovered++
+		}
+	}
+
+	log.Printf("Backfill for %s recovered %d messages", g.Symbol, recovered)
+}
+
+// gapTrades turns the aggregate trades overlapping the gap into backfilled
+// trade messages. An aggregate trade running past the gap end is tagged with
+// the last ID inside the gap, its own last ID is the live trade that closed
+// the gap and would be dropped as a duplicate.
+func gapTrades(g gap, trades []AggTrade) []types.TickerMessage {
+	out := []types.TickerMessage{}
+	for _, t := range trades {
+		if t.LastTradeID <= g.FromTradeID || t.FirstTradeID >= g.ToTradeID {
+			continue
+		}
+		id := t.LastTradeID
+		if id >= g.ToTradeID {
+			id = g.ToTradeID - 1
+		}
+		out = append(out, types.TickerMessage{
+			Symbol:     g.Symbol,
+			Price:      t.Price,
+			Volume:     t.Quantity,
+			Timestamp:  t.Time,
+			EventType:  "trade",
+			TradeID:    id,
+			Backfilled: true,
+		})
+	}
+	return out
+}
+
+// emit pushes a backfilled message, waiting for room instead of dropping it.
+func (m *ConnectionManager) emit(message []byte) bool {
+	if ev, ok := parseStreamEvent(message); ok {
+		if key := dedupeKey(ev); key != "" && m.dedupe.seen(key) {
+			return true
+		}
+	}
+
+	select {
+	case m.MessageChan <- message:
+		return true
+	case <-m.quit:
+		return false
+	}
+}
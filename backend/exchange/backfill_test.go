@@ -0,0 +1,63 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func trade(id, ts int64) streamEvent {
+	return streamEvent{Symbol: "BTCUSDT", EventType: "trade", TradeID: id, Timestamp: ts}
+}
+
+func TestGapDetectorFindsTradeGap(t *testing.T) {
+	g := newGapDetector(DefaultGapThreshold)
+	g.observe(trade(100, 1000))
+
+	found, ok := g.observe(trade(105, 2000))
+	if !ok || !found.Trades {
+		t.Fatalf("expected a trade gap, got %+v %v", found, ok)
+	}
+	if found.FromTradeID != 100 || found.ToTradeID != 105 {
+		t.Errorf("gap IDs = %d..%d, want 100..105", found.FromTradeID, found.ToTradeID)
+	}
+}
+
+func TestGapDetectorSkipsLocalDrops(t *testing.T) {
+	g := newGapDetector(DefaultGapThreshold)
+	g.observe(trade(100, 1000))
+	time.Sleep(time.Millisecond)
+	g.markDrop()
+
+	if found, ok := g.observe(trade(105, 2000)); ok {
+		t.Fatalf("gap after a local drop should not be backfilled, got %+v", found)
+	}
+	// Later gaps are backfilled again.
+	if _, ok := g.observe(trade(110, 3000)); !ok {
+		t.Fatal("expected a gap once no drop happened in between")
+	}
+}
+
+func TestGapTradesDoNotCollideWithTheLiveTrade(t *testing.T) {
+	g := gap{Symbol: "BTCUSDT", FromTradeID: 100, ToTradeID: 105, Trades: true}
+	trades := []AggTrade{
+		{FirstTradeID: 99, LastTradeID: 100}, // seen live before the gap
+		{FirstTradeID: 101, LastTradeID: 102},
+		{FirstTradeID: 103, LastTradeID: 105}, // spans the gap end
+		{FirstTradeID: 106, LastTradeID: 106},
+	}
+	got := gapTrades(g, trades)
+	if len(got) != 2 || got[0].TradeID != 102 || got[1].TradeID != 104 {
+		t.Fatalf("trades = %+v, want IDs 102 and 104", got)
+	}
+
+	m := &ConnectionManager{dedupe: newDedupeWindow(16), MessageChan: make(chan []byte, 4), quit: make(chan struct{})}
+	m.dedupe.seen(dedupeKey(trade(105, 2000)))
+	for _, tr := range got {
+		msg, _ := json.Marshal(tr)
+		m.emit(msg)
+	}
+	if len(m.MessageChan) != 2 {
+		t.Errorf("emitted %d backfilled trades, want 2 next to the live trade 105", len(m.MessageChan))
+	}
+}
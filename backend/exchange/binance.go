@@ -2,12 +2,14 @@ package exchange
 
 import (
 	"bytes"
+	"cropto-dashboard/metrics"
 	"cropto-dashboard/types"
 
 	"encoding/json"
 	"fmt"
 	"log"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,53 +23,98 @@ func min(a, b int) int {
 	return b
 }
 
-func cleanPrice(price types.FlexString) string {
-	priceStr := string(price)
-
-	if val, err := strconv.ParseFloat(priceStr, 64); err == nil {
-		return fmt.Sprintf("%.8f", val)
-	}
-	return priceStr
-}
-
 type BinanceClient struct {
+	ID int
+	// OnRaw, when set, receives every frame exactly as Binance sent it.
+	OnRaw func(frame []byte)
+	// OnDrop, when set, is called for every message dropped on a full channel.
+	OnDrop          func()
 	Symbols         []string
 	Conn            *websocket.Conn
 	MessageChan     chan []byte
 	ReconnectDelay  time.Duration
 	ShouldReconnect bool
+
+	mutex      sync.RWMutex
+	writeMutex sync.Mutex
+	requestID  int64
+	lastSendAt time.Time
+
+	connected     bool
+	connectedAt   time.Time
+	lastMessageAt time.Time
+	reconnects    int
+	messages      int64
+
+	quit      chan struct{}
+	loopDone  chan struct{}
+	started   bool // the read loop runs and will close loopDone
+	closeOnce sync.Once
 }
 
+// ConnectionHealth is a point-in-time snapshot of one Binance socket.
+type ConnectionHealth struct {
+	ID            int           `json:"id"`
+	Connected     bool          `json:"connected"`
+	Symbols       int           `json:"symbols"`
+	Streams       int           `json:"streams"`
+	ConnectedAt   time.Time     `json:"connectedAt"`
+	LastMessageAt time.Time     `json:"lastMessageAt"`
+	Reconnects    int           `json:"reconnects"`
+	Messages      int64         `json:"messages"`
+	Backoff       time.Duration `json:"backoff"`
+}
+
+// Binance allows 5 incoming messages per second on a single connection.
+const minSendInterval = 250 * time.Millisecond
+
 func NewBinanceClient(symbols []string) *BinanceClient {
 	return &BinanceClient{
 		Symbols:         symbols,
-		MessageChan:     make(chan []byte, 256),
+		MessageChan:     make(chan []byte, ConnectionBuffer),
 		ReconnectDelay:  1 * time.Second,
 		ShouldReconnect: true,
+		quit:            make(chan struct{}),
+		loopDone:        make(chan struct{}),
 	}
 }
 
-const binanceWSURL = "wss://stream.binance.com:9443/stream?streams="
+// BinanceWSURL is the combined stream endpoint, replaceable to point at a local feed.
+var BinanceWSURL = "wss://stream.binance.com:9443/stream?streams="
+
+var (
+	// MaxReconnectDelay caps the doubling wait between reconnect attempts.
+	MaxReconnectDelay = 120 * time.Second
+	// ConnectionBuffer is the message queue of a single exchange socket.
+	ConnectionBuffer = 256
+)
 
 func (c *BinanceClient) Connect() error {
 	streamName := c.BuildStreamName()
-	url := fmt.Sprintf("%s%s", binanceWSURL, streamName)
+	url := fmt.Sprintf("%s%s", BinanceWSURL, streamName)
 
-	log.Println("🔗 Connecting to Binance:", url)
+	log.Printf("🔗 [conn %d] Connecting to Binance: %s", c.ID, url)
 
 	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
+	c.mutex.Lock()
 	c.Conn = conn
+	c.connected = true
+	c.connectedAt = time.Now()
 	c.ReconnectDelay = 1 * time.Second
-	log.Println("Connected to Binance WebSocket")
+	c.mutex.Unlock()
+	log.Printf("[conn %d] Connected to Binance WebSocket", c.ID)
 
 	return nil
 }
 
 func (b *BinanceClient) BuildStreamName() string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
 	if len(b.Symbols) == 0 {
 
 		return "btcusdt@trade/btcusdt@ticker"
@@ -84,41 +131,206 @@ func (b *BinanceClient) BuildStreamName() string {
 	return streams
 }
 
+func streamsFor(symbols []string) []string {
+	streams := make([]string, 0, len(symbols)*2)
+	for _, symbol := range symbols {
+		streams = append(streams, symbol+"@trade", symbol+"@ticker")
+	}
+	return streams
+}
+
+func (b *BinanceClient) GetSymbols() []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	symbols := make([]string, len(b.Symbols))
+	copy(symbols, b.Symbols)
+	return symbols
+}
+
+// AddSymbols subscribes to new symbols on the live connection. Symbols that are
+// already tracked are ignored, and the returned slice holds only the ones added.
+func (b *BinanceClient) AddSymbols(symbols ...string) ([]string, error) {
+	b.mutex.Lock()
+	existing := make(map[string]bool, len(b.Symbols))
+	for _, s := range b.Symbols {
+		existing[s] = true
+	}
+
+	added := []string{}
+	for _, s := range symbols {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || existing[s] {
+			continue
+		}
+		existing[s] = true
+		added = append(added, s)
+	}
+	b.Symbols = append(b.Symbols, added...)
+	b.mutex.Unlock()
+
+	if len(added) == 0 {
+		return added, nil
+	}
+	return added, b.sendMethod("SUBSCRIBE", streamsFor(added))
+}
+
+// RemoveSymbols unsubscribes from symbols on the live connection and returns
+// the ones that were actually tracked.
+func (b *BinanceClient) RemoveSymbols(symbols ...string) ([]string, error) {
+	remove := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		remove[strings.ToLower(strings.TrimSpace(s))] = true
+	}
+
+	b.mutex.Lock()
+	kept := make([]string, 0, len(b.Symbols))
+	removed := []string{}
+	for _, s := range b.Symbols {
+		if remove[s] {
+			removed = append(removed, s)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	b.Symbols = kept
+	b.mutex.Unlock()
+
+	if len(removed) == 0 {
+		return removed, nil
+	}
+	return removed, b.sendMethod("UNSUBSCRIBE", streamsFor(removed))
+}
+
+// sendMethod issues a live-stream request such as SUBSCRIBE. When there is no
+// connection the symbol list is still updated and picked up by the next Connect.
+func (b *BinanceClient) sendMethod(method string, params []string) error {
+	b.mutex.Lock()
+	conn := b.Conn
+	b.requestID++
+	id := b.requestID
+	b.mutex.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+
+	if wait := minSendInterval - time.Since(b.lastSendAt); wait > 0 {
+		time.Sleep(wait)
+	}
+	b.lastSendAt = time.Now()
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	err := conn.WriteJSON(map[string]interface{}{
+		"method": method,
+		"params": params,
+		"id":     id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	log.Printf("[conn %d] %s sent for %d streams (id %d)", b.ID, method, len(params), id)
+	return nil
+}
+
+// Start runs the reconnect loop. A client closed before it started stays closed.
 func (b *BinanceClient) Start() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	select {
+	case <-b.quit:
+		return
+	default:
+	}
+	b.started = true
 	go b.ReconnectLoop()
 }
 
 func (b *BinanceClient) ReconnectLoop() {
+	defer close(b.loopDone)
+
 	for b.ShouldReconnect {
 		err := b.Connect()
 		if err != nil {
-			log.Printf(" Connection failed: %v. Retrying in %v", err, b.ReconnectDelay)
-			time.Sleep(b.ReconnectDelay)
+			log.Printf("[conn %d] Connection failed: %v. Retrying in %v", b.ID, err, b.ReconnectDelay)
+			metrics.Reconnects.WithLabelValues("binance").Inc()
+			if !b.sleep(b.ReconnectDelay) {
+				return
+			}
 
+			b.mutex.Lock()
+			b.reconnects++
 			b.ReconnectDelay *= 2
-			if b.ReconnectDelay > 120*time.Second {
-				b.ReconnectDelay = 120 * time.Second
+			if b.ReconnectDelay > MaxReconnectDelay {
+				b.ReconnectDelay = MaxReconnectDelay
 			}
+			b.mutex.Unlock()
 			continue
 		}
 
 		b.readLoop()
 
-		log.Println("🔌 Connection lost, reconnecting...")
-		time.Sleep(b.ReconnectDelay)
+		b.mutex.Lock()
+		b.connected = false
+		b.reconnects++
+		b.mutex.Unlock()
+
+		log.Printf("🔌 [conn %d] Connection lost, reconnecting...", b.ID)
+		metrics.Reconnects.WithLabelValues("binance").Inc()
+		if !b.sleep(b.ReconnectDelay) {
+			return
+		}
+	}
+}
+
+// sleep waits for d and reports false when the client was closed meanwhile.
+func (b *BinanceClient) sleep(d time.Duration) bool {
+	select {
+	case <-b.quit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (b *BinanceClient) Health() ConnectionHealth {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return ConnectionHealth{
+		ID:            b.ID,
+		Connected:     b.connected,
+		Symbols:       len(b.Symbols),
+		Streams:       len(b.Symbols) * 2,
+		ConnectedAt:   b.connectedAt,
+		LastMessageAt: b.lastMessageAt,
+		Reconnects:    b.reconnects,
+		Messages:      b.messages,
+		Backoff:       b.ReconnectDelay,
 	}
 }
 
 func (b *BinanceClient) readLoop() {
+	b.mutex.RLock()
+	conn := b.Conn
+	b.mutex.RUnlock()
+
 	defer func() {
+		b.mutex.Lock()
 		if b.Conn != nil {
 			b.Conn.Close()
+			b.Conn = nil
 		}
+		b.mutex.Unlock()
 	}()
 
-	b.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	b.Conn.SetPongHandler(func(string) error {
-		b.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 
@@ -135,7 +347,7 @@ func (b *BinanceClient) readLoop() {
 		tickerCount := 0
 
 		for {
-			_, message, err := b.Conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					log.Printf("WebSocket error: %v", err)
@@ -145,9 +357,19 @@ func (b *BinanceClient) readLoop() {
 
 			msgCount++
 
+			if b.OnRaw != nil {
+				b.OnRaw(message)
+			}
+
+			b.mutex.Lock()
+			b.messages++
+			b.lastMessageAt = time.Now()
+			b.mutex.Unlock()
+
 			normalized, err := b.normalizeMessage(message)
 			if err != nil {
 				log.Printf("Failed to parse message: %v", err)
+				metrics.ParseErrors.WithLabelValues("binance").Inc()
 				continue
 			}
 
@@ -174,6 +396,10 @@ func (b *BinanceClient) readLoop() {
 			case b.MessageChan <- normalized:
 			default:
 				log.Println(" Message channel full, dropping message")
+				metrics.Dropped.WithLabelValues(metrics.DropIngestFull).Inc()
+				if b.OnDrop != nil {
+					b.OnDrop()
+				}
 			}
 		}
 	}()
@@ -183,7 +409,7 @@ func (b *BinanceClient) readLoop() {
 		case <-done:
 			return
 		case <-ticker.C:
-			if err := b.Conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
+			if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
 				log.Printf("Ping failed: %v", err)
 				return
 			}
@@ -235,15 +461,12 @@ func (b *BinanceClient) normalizeMessage(data []byte) ([]byte, error) {
 		}
 
 		ticker := types.TickerMessage{
-			Symbol:        tradeData.Symbol,
-			Price:         cleanPrice(tradeData.Price),
-			Change:        "0",
-			ChangePercent: "0",
-			Volume:        cleanPrice(tradeData.Quantity),
-			High:          "0",
-			Low:           "0",
-			Timestamp:     tradeData.TradeTime,
-			EventType:     "trade",
+			Symbol:    tradeData.Symbol,
+			Price:     tradeData.Price,
+			Volume:    tradeData.Quantity,
+			Timestamp: tradeData.TradeTime,
+			EventType: "trade",
+			TradeID:   tradeData.TradeID,
 		}
 
 		jsonBytes, _ := json.Marshal(ticker)
@@ -262,12 +485,15 @@ func (b *BinanceClient) normalizeMessage(data []byte) ([]byte, error) {
 
 		ticker := types.TickerMessage{
 			Symbol:        binanceData.Symbol,
-			Price:         cleanPrice(binanceData.LastPrice),
-			Change:        cleanPrice(binanceData.PriceChange),
-			ChangePercent: cleanPrice(binanceData.PriceChangePercent),
-			Volume:        cleanPrice(binanceData.Volume),
-			High:          cleanPrice(binanceData.HighPrice),
-			Low:           cleanPrice(binanceData.LowPrice),
+			Price:         binanceData.LastPrice,
+			Change:        binanceData.PriceChange,
+			ChangePercent: binanceData.PriceChangePercent,
+			Volume:        binanceData.Volume,
+			QuoteVolume:   binanceData.QuoteVolume,
+			High:          binanceData.HighPrice,
+			Low:           binanceData.LowPrice,
+			Bid:           binanceData.BidPrice,
+			Ask:           binanceData.AskPrice,
 			Timestamp:     binanceData.EventTime,
 			EventType:     "ticker",
 		}
@@ -286,10 +512,31 @@ func (b *BinanceClient) GetMessageChannel() <-chan []byte {
 }
 
 func (b *BinanceClient) Close() {
+	b.closeOnce.Do(b.close)
+}
+
+func (b *BinanceClient) close() {
+	b.mutex.Lock()
 	b.ShouldReconnect = false
-	if b.Conn != nil {
-		b.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		b.Conn.Close()
+	close(b.quit)
+	started := b.started
+	conn := b.Conn
+	b.mutex.Unlock()
+
+	if conn != nil {
+		b.writeMutex.Lock()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		b.writeMutex.Unlock()
+		conn.Close()
+	}
+
+	// Wait for the read loop to stop so nothing sends on the closed channel.
+	if started {
+		select {
+		case <-b.loopDone:
+		case <-time.After(5 * time.Second):
+			log.Printf("[conn %d] Read loop did not stop in time", b.ID)
+		}
 	}
 	close(b.MessageChan)
 }
@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"cropto-dashboard/types"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// A 24hrTicker as Binance sends it on a combined stream.
+const tickerPayload = `{"stream":"btcusdt@ticker","data":{"e":"24hrTicker","E":1700000000123,"s":"BTCUSDT",` +
+	`"p":"-120.50","P":"-0.32","w":"37050.1","x":"37200.00","c":"37079.50","Q":"0.015",` +
+	`"b":"37079.40","B":"1.234","a":"37079.60","A":"0.567","o":"37200.00","h":"37500.00",` +
+	`"l":"36800.00","v":"25000.5","q":"926000000.25","O":1699913600123,"C":1700000000122,` +
+	`"F":3300000000,"L":3300999999,"n":999999}}`
+
+const tradePayload = `{"stream":"btcusdt@trade","data":{"e":"trade","E":1700000000456,"s":"BTCUSDT",` +
+	`"t":3301000000,"p":"37080.00","q":"0.002","T":1700000000455,"m":false,"M":true}}`
+
+func TestNormalizeTickerKeepsCaseDistinctFields(t *testing.T) {
+	b := &BinanceClient{}
+	data, err := b.normalizeMessage([]byte(tickerPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg types.TickerMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := map[string][2]string{
+		"price": {msg.Price.String(), "37079.50"},
+		"bid":   {msg.Bid.String(), "37079.40"},
+		"ask":   {msg.Ask.String(), "37079.60"},
+		"low":   {msg.Low.String(), "36800.00"},
+		"quote": {msg.QuoteVolume.String(), "926000000.25"},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %s, want %s", name, c[0], c[1])
+		}
+	}
+	if msg.EventType != "ticker" || msg.Timestamp != 1700000000123 {
+		t.Errorf("got %s at %d", msg.EventType, msg.Timestamp)
+	}
+}
+
+func TestDecodeTradeIgnoresUpperM(t *testing.T) {
+	var trade types.BinanceTradeData
+	var wrapper struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(tradePayload), &wrapper); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(wrapper.Data, &trade); err != nil {
+		t.Fatal(err)
+	}
+	if trade.IsBuyerMaker {
+		t.Error(`"M" overwrote the buyer maker flag`)
+	}
+	if trade.Price.String() != "37080.00" || trade.TradeTime != 1700000000455 {
+		t.Errorf("got %s at %d", trade.Price, trade.TradeTime)
+	}
+}
+
+func TestCloseBeforeStartDoesNotWait(t *testing.T) {
+	c := NewBinanceClient([]string{"btcusdt"})
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	// Waiting on a read loop that never ran took the full 5 seconds.
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close waited for a read loop that never started")
+	}
+	if _, ok := <-c.GetMessageChannel(); ok {
+		t.Error("message channel still open")
+	}
+
+	c.Start()
+	if c.Health().Connected {
+		t.Error("a closed client started")
+	}
+}
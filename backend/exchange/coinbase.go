@@ -0,0 +1,282 @@
+package exchange
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/metrics"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// CoinbaseWSURL is the public feed endpoint, replaceable to point at a local feed.
+var CoinbaseWSURL = "wss://ws-feed.exchange.coinbase.com"
+
+// CoinbaseProduct maps a Binance symbol to a Coinbase product. USDT pairs use
+// Coinbase's USD books, which is where its liquidity is.
+func CoinbaseProduct(symbol string) (string, bool) {
+	base, quote, ok := SplitSymbol(symbol)
+	if !ok {
+		return "", false
+	}
+	if quote == "USDT" {
+		quote = "USD"
+	}
+	return base + "-" + quote, true
+}
+
+// CoinbaseClient follows the ticker channel of the tracked symbols and emits
+// normalized "quote" messages under their Binance symbol.
+type CoinbaseClient struct {
+	MessageChan    chan []byte
+	ReconnectDelay time.Duration
+
+	mutex    sync.RWMutex
+	conn     *websocket.Conn
+	products map[string]string // product id to Binance symbol
+
+	connectedAt   time.Time
+	lastMessageAt time.Time
+	reconnects    int
+	messages      int64
+
+	quit      chan struct{}
+	loopDone  chan struct{}
+	closeOnce sync.Once
+}
+
+func NewCoinbaseClient(symbols []string) *CoinbaseClient {
+	c := &CoinbaseClient{
+		MessageChan:    make(chan []byte, ConnectionBuffer),
+		ReconnectDelay: 1 * time.Second,
+		products:       make(map[string]string),
+		quit:           make(chan struct{}),
+		loopDone:       make(chan struct{}),
+	}
+	for _, s := range symbols {
+		s = strings.ToUpper(s)
+		if product, ok := CoinbaseProduct(s); ok {
+			c.products[product] = s
+		}
+	}
+	return c
+}
+
+func (c *CoinbaseClient) Start() {
+	go c.reconnectLoop()
+}
+
+func (c *CoinbaseClient) GetMessageChannel() <-chan []byte {
+	return c.MessageChan
+}
+
+func (c *CoinbaseClient) connect() error {
+	conn, _, err := websocket.DefaultDialer.Dial(CoinbaseWSURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	products := make([]string, 0, len(c.products))
+	for product := range c.products {
+		products = append(products, product)
+	}
+	sub := map[string]interface{}{
+		"type":        "subscribe",
+		"product_ids": products,
+		"channels":    []string{"ticker"},
+	}
+	if err := conn.WriteJSON(sub); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.conn = conn
+	c.connectedAt = time.Now()
+	c.ReconnectDelay = 1 * time.Second
+	c.mutex.Unlock()
+	log.Printf("Connected to Coinbase feed with %d products", len(products))
+	return nil
+}
+
+func (c *CoinbaseClient) reconnectLoop() {
+	defer close(c.loopDone)
+
+	for {
+		if err := c.connect(); err != nil {
+			log.Printf("Coinbase connection failed: %v. Retrying in %v", err, c.ReconnectDelay)
+			metrics.Reconnects.WithLabelValues("coinbase").Inc()
+			c.mutex.Lock()
+			c.reconnects++
+			c.mutex.Unlock()
+		} else if c.closed() {
+			c.mutex.Lock()
+			c.conn.Close()
+			c.mutex.Unlock()
+			return
+		} else {
+			c.readLoop()
+			if c.closed() {
+				return
+			}
+			log.Println("Coinbase connection lost, reconnecting...")
+			metrics.Reconnects.WithLabelValues("coinbase").Inc()
+			c.mutex.Lock()
+			c.reconnects++
+			c.mutex.Unlock()
+		}
+
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(c.ReconnectDelay):
+		}
+
+		c.mutex.Lock()
+		c.ReconnectDelay *= 2
+		if c.ReconnectDelay > MaxReconnectDelay {
+			c.ReconnectDelay = MaxReconnectDelay
+		}
+		c.mutex.Unlock()
+	}
+}
+
+func (c *CoinbaseClient) closed() bool {
+	select {
+	case <-c.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// Health reports the feed in the same shape as a Binance connection.
+func (c *CoinbaseClient) Health() ConnectionHealth {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return ConnectionHealth{
+		Connected:     c.conn != nil,
+		Symbols:       len(c.products),
+		Streams:       1,
+		ConnectedAt:   c.connectedAt,
+		LastMessageAt: c.lastMessageAt,
+		Reconnects:    c.reconnects,
+		Messages:      c.messages,
+		Backoff:       c.ReconnectDelay,
+	}
+}
+
+func (c *CoinbaseClient) readLoop() {
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+
+	defer func() {
+		c.mutex.Lock()
+		conn.Close()
+		c.conn = nil
+		c.mutex.Unlock()
+	}()
+
+	for {
+		// The ticker channel is busy, a minute of silence means a dead socket.
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Coinbase WebSocket error: %v", err)
+			}
+			return
+		}
+
+		c.mutex.Lock()
+		c.messages++
+		c.lastMessageAt = time.Now()
+		c.mutex.Unlock()
+
+		normalized, err := c.normalizeMessage(message)
+		if err != nil {
+			log.Printf("Failed to parse Coinbase message: %v", err)
+			metrics.ParseErrors.WithLabelValues("coinbase").Inc()
+			continue
+		}
+		if normalized == nil {
+			continue
+		}
+
+		select {
+		case c.MessageChan <- normalized:
+		default:
+			log.Println("Coinbase message channel full, dropping message")
+			metrics.Dropped.WithLabelValues(metrics.DropIngestFull).Inc()
+		}
+	}
+}
+
+func (c *CoinbaseClient) normalizeMessage(data []byte) ([]byte, error) {
+	var msg struct {
+		Type      string          `json:"type"`
+		ProductID string          `json:"product_id"`
+		Price     decimal.Decimal `json:"price"`
+		BestBid   decimal.Decimal `json:"best_bid"`
+		BestAsk   decimal.Decimal `json:"best_ask"`
+		Time      time.Time       `json:"time"`
+		Message   string          `json:"message"`
+		Reason    string          `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	switch msg.Type {
+	case "ticker":
+	case "error":
+		return nil, fmt.Errorf("coinbase error: %s %s", msg.Message, msg.Reason)
+	default:
+		return nil, nil
+	}
+
+	symbol, ok := c.products[msg.ProductID]
+	if !ok {
+		return nil, nil
+	}
+	ts := msg.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return json.Marshal(types.QuoteMessage{
+		Venue:     "coinbase",
+		Symbol:    symbol,
+		Price:     msg.Price,
+		Bid:       msg.BestBid,
+		Ask:       msg.BestAsk,
+		Timestamp: ts.UnixMilli(),
+		EventType: "quote",
+	})
+}
+
+func (c *CoinbaseClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+
+		c.mutex.RLock()
+		conn := c.conn
+		c.mutex.RUnlock()
+		if conn != nil {
+			conn.Close()
+		}
+
+		select {
+		case <-c.loopDone:
+		case <-time.After(5 * time.Second):
+			log.Println("Coinbase read loop did not stop in time")
+		}
+		close(c.MessageChan)
+	})
+}
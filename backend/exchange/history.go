@@ -1,27 +1,32 @@
 package exchange
 
 import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/metrics"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-const baseUrl = "https://api.binance.com/api/v3"
+// BinanceRESTURL is the REST API base, replaceable like BinanceWSURL.
+var BinanceRESTURL = "https://api.binance.com/api/v3"
 
 var httpClient = &http.Client{Timeout: 10 * time.Second}
 
 type CandleStick struct {
-	OpenTime  int64  `json:"openTime"`
-	Open      string `json:"open"`
-	High      string `json:"high"`
-	Low       string `json:"low"`
-	Close     string `json:"close"`
-	Volume    string `json:"volume"`
-	CloseTime int64  `json:"closeTime"`
+	OpenTime  int64           `json:"openTime"`
+	Open      decimal.Decimal `json:"open"`
+	High      decimal.Decimal `json:"high"`
+	Low       decimal.Decimal `json:"low"`
+	Close     decimal.Decimal `json:"close"`
+	Volume    decimal.Decimal `json:"volume"`
+	CloseTime int64           `json:"closeTime"`
 }
 
 type TechnicalIndicators struct {
@@ -37,16 +42,40 @@ type TechnicalIndicators struct {
 type ChartData struct {
 	Symbol       string              `json:"symbol"`
 	Interval     string              `json:"interval"`
+	Quote        string              `json:"quote,omitempty"`
 	Candlesticks []CandleStick       `json:"candlesticks"`
 	Indicators   TechnicalIndicators `json:"indicators"`
 }
 
-func fetch(url string) ([]byte, error) {
+// fetch GETs url once its weight fits the budget. Callers asking for the
+// same url at the same time share one request and its body, which they must
+// not modify.
+func fetch(url string, weight int) ([]byte, error) {
+	body, err, _ := inflight.Do(url, func() (interface{}, error) {
+		if err := gate.reserve(weight); err != nil {
+			return nil, err
+		}
+		return get(url)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body.([]byte), nil
+}
+
+func get(url string) ([]byte, error) {
+	endpoint, _, _ := strings.Cut(strings.TrimPrefix(url, BinanceRESTURL+"/"), "?")
+	start := time.Now()
 	res, err := httpClient.Get(url)
 	if err != nil {
+		metrics.UpstreamDuration.WithLabelValues(endpoint, "error").Observe(time.Since(start).Seconds())
 		return nil, err
 	}
 	defer res.Body.Close()
+	metrics.UpstreamDuration.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Observe(time.Since(start).Seconds())
+	if err := gate.observe(res); err != nil {
+		return nil, err
+	}
 
 	if res.StatusCode != 200 {
 		body, _ := io.ReadAll(res.Body)
@@ -56,28 +85,49 @@ func fetch(url string) ([]byte, error) {
 	return io.ReadAll(res.Body)
 }
 
+// OHLC holds candle fields as float series for indicator and statistics math.
+type OHLC struct {
+	Open   []float64
+	High   []float64
+	Low    []float64
+	Close  []float64
+	Volume []float64
+}
+
+func ExtractOHLC(candles []CandleStick) OHLC {
+	s := OHLC{
+		Open:   make([]float64, len(candles)),
+		High:   make([]float64, len(candles)),
+		Low:    make([]float64, len(candles)),
+		Close:  make([]float64, len(candles)),
+		Volume: make([]float64, len(candles)),
+	}
+	for i, candle := range candles {
+		s.Open[i] = candle.Open.Float64()
+		s.High[i] = candle.High.Float64()
+		s.Low[i] = candle.Low.Float64()
+		s.Close[i] = candle.Close.Float64()
+		s.Volume[i] = candle.Volume.Float64()
+	}
+	return s
+}
+
 func GetHistoricalDataWithIndicators(symbol, interval string, limit int, includeIndicators bool) (*ChartData, error) {
 	data, err := GetHistoricalData(symbol, interval, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	if !includeIndicators || len(data.Candlesticks) == 0 {
-		return data, nil
+	if includeIndicators {
+		AddIndicators(data)
 	}
+	return data, nil
+}
 
-	closePrice := make([]float64, len(data.Candlesticks))
-	highPrice := make([]float64, len(data.Candlesticks))
-	lowPrice := make([]float64, len(data.Candlesticks))
-
-	for i, candle := range data.Candlesticks {
-		close, _ := strconv.ParseFloat(candle.Close, 64)
-		high, _ := strconv.ParseFloat(candle.High, 64)
-		low, _ := strconv.ParseFloat(candle.Low, 64)
-		closePrice[i] = close
-		highPrice[i] = high
-		lowPrice[i] = low
-	}
+// AddIndicators computes the indicators from the candles' closes. Call it
+// after the candles are converted to another quote so both agree.
+func AddIndicators(data *ChartData) {
+	closePrice := ExtractOHLC(data.Candlesticks).Close
 
 	indicators := TechnicalIndicators{}
 
@@ -93,8 +143,9 @@ func GetHistoricalDataWithIndicators(symbol, interval string, limit int, include
 		indicators.MA200 = calculateMA(closePrice, 200)
 	}
 
-	if len(closePrice) >= 14 {
-		indicators.RSI = calculateMA(closePrice, 14)
+	// The first change needs a previous close, so RSI(14) needs 15 candles.
+	if len(closePrice) > 14 {
+		indicators.RSI = calculateRSI(closePrice, 14)
 	}
 
 	if len(closePrice) >= 26 {
@@ -104,7 +155,6 @@ func GetHistoricalDataWithIndicators(symbol, interval string, limit int, include
 		indicators.Histogram = histogram
 	}
 	data.Indicators = indicators
-	return data, nil
 }
 
 func calculateMA(prices []float64, period int) []float64 {
@@ -144,14 +194,16 @@ func calculateRSI(prices []float64, period int) []float64 {
 	avgLoss /= float64(period)
 
 	for i := period; i < len(prices); i++ {
+		if i > period {
+			avgGain = (avgGain*float64(period-1) + gains[i]) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + losses[i]) / float64(period)
+		}
 		if avgLoss == 0 {
 			rsi[i] = 100
 		} else {
 			rs := avgGain / avgLoss
 			rsi[i] = 100 - (100 / (1 + rs))
 		}
-		avgGain = (avgGain*float64(period-1) + gains[i]) / float64(period)
-		avgLoss = (avgLoss*float64(period-1) + losses[i]) / float64(period)
 	}
 	return rsi
 }
@@ -199,14 +251,29 @@ func GetHistoricalData(symbol, interval string, limit int) (*ChartData, error) {
 	}
 
 	url := fmt.Sprintf("%s/klines?symbol=%s&interval=%s&limit=%d",
-		baseUrl, symbol, interval, limit)
+		BinanceRESTURL, symbol, interval, limit)
 
-	body, err := fetch(url)
+	body, err := fetch(url, klinesWeight(limit))
 	if err != nil {
 		return nil, err
 	}
 
-	var raw [][]interface{}
+	candles, err := parseKlines(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChartData{
+		Symbol:       symbol,
+		Interval:     interval,
+		Candlesticks: candles,
+	}, nil
+}
+
+// parseKlines reads Binance kline rows, [openTime, "open", "high", "low",
+// "close", "volume", closeTime, ...], keeping prices exact.
+func parseKlines(body []byte) ([]CandleStick, error) {
+	var raw [][]json.RawMessage
 	if err := json.Unmarshal(body, &raw); err != nil {
 		return nil, err
 	}
@@ -214,54 +281,197 @@ func GetHistoricalData(symbol, interval string, limit int) (*ChartData, error) {
 	candles := make([]CandleStick, 0, len(raw))
 
 	for _, r := range raw {
-		candles = append(candles, CandleStick{
-			OpenTime:  int64(r[0].(float64)),
-			Open:      fmt.Sprint(r[1]),
-			High:      fmt.Sprint(r[2]),
-			Low:       fmt.Sprint(r[3]),
-			Close:     fmt.Sprint(r[4]),
-			Volume:    fmt.Sprint(r[5]),
-			CloseTime: int64(r[6].(float64)),
-		})
+		if len(r) < 7 {
+			return nil, fmt.Errorf("kline row has %d fields, expected at least 7", len(r))
+		}
+
+		var c CandleStick
+		fields := []interface{}{&c.OpenTime, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.CloseTime}
+		for i, field := range fields {
+			if err := json.Unmarshal(r[i], field); err != nil {
+				return nil, fmt.Errorf("invalid kline field %d: %w", i, err)
+			}
+		}
+		candles = append(candles, c)
 	}
+	return candles, nil
+}
 
-	return &ChartData{
-		Symbol:       symbol,
-		Interval:     interval,
-		Candlesticks: candles,
-	}, nil
+// GetCandlesRange returns the candles opened between startTime and endTime (ms).
+func GetCandlesRange(symbol, interval string, startTime, endTime int64) ([]CandleStick, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	url := fmt.Sprintf("%s/klines?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=1000",
+		BinanceRESTURL, symbol, interval, startTime, endTime)
+
+	body, err := fetch(url, klinesWeight(1000))
+	if err != nil {
+		return nil, err
+	}
+	return parseKlines(body)
+}
+
+type AggTrade struct {
+	AggTradeID   int64           `json:"a"`
+	Price        decimal.Decimal `json:"p"`
+	Quantity     decimal.Decimal `json:"q"`
+	FirstTradeID int64           `json:"f"`
+	LastTradeID  int64           `json:"l"`
+	Time         int64           `json:"T"`
+	IsBuyerMaker bool            `json:"m"`
+	BestMatch    bool            `json:"M"` // keeps "M" from landing in IsBuyerMaker
 }
 
+// GetAggTrades returns aggregate trades between startTime and endTime (ms).
+// Binance rejects windows longer than one hour.
+func GetAggTrades(symbol string, startTime, endTime int64) ([]AggTrade, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	url := fmt.Sprintf("%s/aggTrades?symbol=%s&startTime=%d&endTime=%d&limit=1000",
+		BinanceRESTURL, symbol, startTime, endTime)
+
+	body, err := fetch(url, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []AggTrade
+	if err := json.Unmarshal(body, &trades); err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+// GetMultipleHistoricalData loads symbols with at most MaxParallelRequests
+// in flight and leaves out the ones that fail. It only fails when none load.
 func GetMultipleHistoricalData(symbols []string, interval string, limit int) (map[string]*ChartData, error) {
-	result := make(map[string]*ChartData)
+	result := make(map[string]*ChartData, len(symbols))
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, max(1, MaxParallelRequests))
 
 	for _, s := range symbols {
-		data, err := GetHistoricalData(s, interval, limit)
-		if err == nil {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			data, err := GetHistoricalData(s, interval, limit)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
 			result[s] = data
-		}
-		time.Sleep(50 * time.Millisecond)
+		}()
 	}
+	wg.Wait()
 
+	if len(result) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
 	return result, nil
 }
 
-func GetLatestPrice(symbol string) (string, error) {
+// Ping checks that the REST API is reachable.
+func Ping() error {
+	_, err := fetch(BinanceRESTURL+"/ping", 1)
+	return err
+}
+
+func GetLatestPrice(symbol string) (decimal.Decimal, error) {
 	symbol = strings.ToUpper(strings.TrimSpace(symbol))
 
-	url := fmt.Sprintf("%s/ticker/price?symbol=%s", baseUrl, symbol)
-	body, err := fetch(url)
+	url := fmt.Sprintf("%s/ticker/price?symbol=%s", BinanceRESTURL, symbol)
+	body, err := fetch(url, 2)
 	if err != nil {
-		return "", err
+		return decimal.Zero, err
 	}
 
 	var res struct {
-		Price string `json:"price"`
+		Price decimal.Decimal `json:"price"`
 	}
 
 	if err := json.Unmarshal(body, &res); err != nil {
-		return "", err
+		return decimal.Zero, err
 	}
 
 	return res.Price, nil
 }
+
+// GetTopSymbols returns the n most traded pairs quoted in quote, by 24h quote volume.
+func GetTopSymbols(quote string, n int) ([]string, error) {
+	quote = strings.ToUpper(strings.TrimSpace(quote))
+
+	// Without a symbol this is one of the heaviest calls.
+	body, err := fetch(fmt.Sprintf("%s/ticker/24hr", BinanceRESTURL), 80)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers []struct {
+		Symbol      string          `json:"symbol"`
+		QuoteVolume decimal.Decimal `json:"quoteVolume"`
+	}
+	if err := json.Unmarshal(body, &tickers); err != nil {
+		return nil, err
+	}
+
+	type ranked struct {
+		symbol string
+		volume decimal.Decimal
+	}
+	candidates := []ranked{}
+	for _, t := range tickers {
+		if !strings.HasSuffix(t.Symbol, quote) {
+			continue
+		}
+		candidates = append(candidates, ranked{symbol: strings.ToLower(t.Symbol), volume: t.QuoteVolume})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].volume.GreaterThan(candidates[j].volume)
+	})
+
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	symbols := make([]string, 0, n)
+	for _, c := range candidates[:n] {
+		symbols = append(symbols, c.symbol)
+	}
+	return symbols, nil
+}
+
+// GetCandleSeries pages through /klines to cover [startTime, endTime] (ms), which
+// may be longer than the 1000 candles a single request returns.
+func GetCandleSeries(symbol, interval string, startTime, endTime int64, maxCandles int) ([]CandleStick, error) {
+	candles := []CandleStick{}
+
+	for startTime < endTime && len(candles) < maxCandles {
+		page, err := GetCandlesRange(symbol, interval, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		candles = append(candles, page...)
+		startTime = page[len(page)-1].CloseTime + 1
+	}
+
+	if len(candles) > maxCandles {
+		candles = candles[:maxCandles]
+	}
+	return candles, nil
+}
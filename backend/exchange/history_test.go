@@ -0,0 +1,37 @@
+package exchange
+
+import (
+	"cropto-dashboard/decimal"
+	"math"
+	"testing"
+)
+
+func TestCalculateRSI(t *testing.T) {
+	// Wilder's RSI(2): 50 from the first two changes, then smoothed gains.
+	got := calculateRSI([]float64{1, 2, 1, 2, 3}, 2)
+	want := []float64{0, 0, 50, 75, 87.5}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("rsi = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAddIndicatorsUsesRSI(t *testing.T) {
+	data := &ChartData{}
+	for i := 0; i < 15; i++ {
+		price := decimal.NewFromInt(int64(100 + i))
+		data.Candlesticks = append(data.Candlesticks, CandleStick{Open: price, High: price, Low: price, Close: price})
+	}
+	AddIndicators(data)
+	// Closes that only rise have an RSI of 100, their 14 period mean is 107.5.
+	if len(data.Indicators.RSI) != 15 || data.Indicators.RSI[14] != 100 {
+		t.Fatalf("rsi = %v, want 100 on the last candle", data.Indicators.RSI)
+	}
+
+	data.Candlesticks = data.Candlesticks[:14]
+	AddIndicators(data)
+	if data.Indicators.RSI != nil {
+		t.Errorf("rsi = %v from 14 candles, want none", data.Indicators.RSI)
+	}
+}
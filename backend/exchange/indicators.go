@@ -15,7 +15,7 @@ func MovingAverage(prices []float64, period int) []float64 {
 
 	for i := period - 1; i < len(prices); i++ {
 		sum := 0.0
-		for j := 0; i < period; j++ {
+		for j := 0; j < period; j++ {
 			sum += prices[i-j]
 		}
 		ma[i] = sum / float64(period)
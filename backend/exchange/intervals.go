@@ -0,0 +1,37 @@
+package exchange
+
+import (
+	"strconv"
+	"time"
+)
+
+// IntervalDuration converts a Binance kline interval such as "15m" or "1d".
+func IntervalDuration(interval string) time.Duration {
+	if len(interval) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0
+	}
+
+	unit := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'M': 30 * 24 * time.Hour,
+	}[interval[len(interval)-1]]
+
+	return time.Duration(n) * unit
+}
+
+// PeriodsPerYear counts candles in a year; crypto trades around the clock.
+func PeriodsPerYear(interval string) float64 {
+	d := IntervalDuration(interval)
+	if d <= 0 {
+		return 365
+	}
+	return float64(365*24*time.Hour) / float64(d)
+}
@@ -0,0 +1,483 @@
+package exchange
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Binance caps a single connection at 1024 streams, and long combined-stream URLs are
+// rejected well before that, so symbols are spread across several sockets.
+const (
+	DefaultSymbolsPerConn = 50
+	maxStreamsPerConn     = 1024
+	// Binance drops every connection after 24h, so shards are rotated before that.
+	DefaultMaxConnAge = 23 * time.Hour
+)
+
+// rotateTimeout is how long a replacement has to deliver its first message.
+var rotateTimeout = 30 * time.Second
+
+// StreamBuffer is the merged queue of all shards.
+var StreamBuffer = 1024
+
+// ConnectionManager shards symbols over several BinanceClients and merges their
+// messages into one channel with the same contract as BinanceClient.GetMessageChannel.
+type ConnectionManager struct {
+	SymbolsPerConn int
+	MaxConnAge     time.Duration
+	MessageChan    chan []byte
+
+	mutex  sync.RWMutex
+	shards []*BinanceClient
+	owner  map[string]*BinanceClient
+	nextID int
+	closed bool
+	// rotating holds shards being replaced and their replacements, which
+	// AddSymbols leaves alone until the swap is decided.
+	rotating map[*BinanceClient]bool
+
+	rawHandler func(frame []byte)
+
+	dedupe      *dedupeWindow
+	gaps        *gapDetector
+	backfillSem chan struct{}
+	wg          sync.WaitGroup
+	quit        chan struct{}
+}
+
+func NewConnectionManager(symbols []string, symbolsPerConn int) *ConnectionManager {
+	if symbolsPerConn <= 0 {
+		symbolsPerConn = DefaultSymbolsPerConn
+	}
+	if symbolsPerConn*2 > maxStreamsPerConn {
+		symbolsPerConn = maxStreamsPerConn / 2
+	}
+
+	m := &ConnectionManager{
+		SymbolsPerConn: symbolsPerConn,
+		MaxConnAge:     DefaultMaxConnAge,
+		MessageChan:    make(chan []byte, StreamBuffer),
+		owner:          make(map[string]*BinanceClient),
+		rotating:       make(map[*BinanceClient]bool),
+		dedupe:         newDedupeWindow(8192),
+		gaps:           newGapDetector(DefaultGapThreshold),
+		backfillSem:    make(chan struct{}, 2),
+		quit:           make(chan struct{}),
+	}
+
+	m.mutex.Lock()
+	for start := 0; start < len(symbols); start += symbolsPerConn {
+		end := min(start+symbolsPerConn, len(symbols))
+		m.newShardLocked(normalizeSymbols(symbols[start:end]))
+	}
+	m.mutex.Unlock()
+
+	return m
+}
+
+func normalizeSymbols(symbols []string) []string {
+	out := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		out = append(out, NormalizeSymbol(s))
+	}
+	return out
+}
+
+func (m *ConnectionManager) newShardLocked(symbols []string) *BinanceClient {
+	m.nextID++
+	client := NewBinanceClient(symbols)
+	client.ID = m.nextID
+	client.OnRaw = m.handleRaw
+	client.OnDrop = m.gaps.markDrop
+
+	m.shards = append(m.shards, client)
+	for _, s := range symbols {
+		m.owner[s] = client
+	}
+
+	m.wg.Add(1)
+	go m.forward(client)
+	return client
+}
+
+// Start opens the shards one after another so a large symbol list does not trip
+// Binance's connection-attempt limit.
+func (m *ConnectionManager) Start() {
+	m.mutex.RLock()
+	shards := append([]*BinanceClient(nil), m.shards...)
+	m.mutex.RUnlock()
+
+	go func() {
+		for i, shard := range shards {
+			if i > 0 {
+				select {
+				case <-m.quit:
+					return
+				case <-time.After(500 * time.Millisecond):
+				}
+			}
+			shard.Start()
+		}
+	}()
+
+	go m.rotateLoop()
+}
+
+func (m *ConnectionManager) forward(client *BinanceClient) {
+	defer m.wg.Done()
+
+	for message := range client.GetMessageChannel() {
+		ev, ok := parseStreamEvent(message)
+		if ok {
+			if key := dedupeKey(ev); key != "" && m.dedupe.seen(key) {
+				continue
+			}
+			if g, found := m.gaps.observe(ev); found {
+				m.wg.Add(1)
+				go m.backfill(g)
+			}
+		}
+
+		select {
+		case m.MessageChan <- message:
+		default:
+			log.Printf("[conn %d] Manager channel full, dropping message", client.ID)
+			m.gaps.markDrop()
+		}
+	}
+}
+
+// SetRawHandler registers a callback for every raw Binance frame on every shard.
+func (m *ConnectionManager) SetRawHandler(fn func(frame []byte)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rawHandler = fn
+}
+
+func (m *ConnectionManager) handleRaw(frame []byte) {
+	m.mutex.RLock()
+	fn := m.rawHandler
+	m.mutex.RUnlock()
+
+	if fn != nil {
+		fn(frame)
+	}
+}
+
+func (m *ConnectionManager) GetMessageChannel() <-chan []byte {
+	return m.MessageChan
+}
+
+func (m *ConnectionManager) GetSymbols() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	// A shard being rotated shares its symbols with its replacement.
+	seen := make(map[string]bool)
+	symbols := []string{}
+	for _, shard := range m.shards {
+		for _, s := range shard.GetSymbols() {
+			if !seen[s] {
+				seen[s] = true
+				symbols = append(symbols, s)
+			}
+		}
+	}
+	return symbols
+}
+
+// AddSymbols fills the least loaded shards first and opens new sockets for
+// whatever does not fit.
+func (m *ConnectionManager) AddSymbols(symbols ...string) ([]string, error) {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("connection manager is closed")
+	}
+
+	added := []string{}
+	for _, s := range normalizeSymbols(symbols) {
+		if _, ok := m.owner[s]; ok || s == "" {
+			continue
+		}
+		m.owner[s] = nil
+		added = append(added, s)
+	}
+
+	shards := append([]*BinanceClient(nil), m.shards...)
+	sort.Slice(shards, func(i, j int) bool {
+		return len(shards[i].GetSymbols()) < len(shards[j].GetSymbols())
+	})
+
+	plan := make(map[*BinanceClient][]string)
+	pending := added
+	for _, shard := range shards {
+		free := m.SymbolsPerConn - len(shard.GetSymbols())
+		if free <= 0 || len(pending) == 0 || m.rotating[shard] {
+			continue
+		}
+		n := min(free, len(pending))
+		plan[shard] = pending[:n]
+		for _, s := range pending[:n] {
+			m.owner[s] = shard
+		}
+		pending = pending[n:]
+	}
+
+	newShards := []*BinanceClient{}
+	for len(pending) > 0 {
+		n := min(m.SymbolsPerConn, len(pending))
+		newShards = append(newShards, m.newShardLocked(pending[:n]))
+		pending = pending[n:]
+	}
+	m.mutex.Unlock()
+
+	var firstErr error
+	for shard, group := range plan {
+		if _, err := shard.AddSymbols(group...); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, shard := range newShards {
+		shard.Start()
+	}
+
+	return added, firstErr
+}
+
+// RemoveSymbols unsubscribes symbols from their shard and closes shards left empty.
+func (m *ConnectionManager) RemoveSymbols(symbols ...string) ([]string, error) {
+	m.mutex.Lock()
+	plan := make(map[*BinanceClient][]string)
+	for _, s := range normalizeSymbols(symbols) {
+		if shard, ok := m.owner[s]; ok && shard != nil {
+			plan[shard] = append(plan[shard], s)
+			delete(m.owner, s)
+			m.gaps.forget(strings.ToUpper(s))
+		}
+	}
+	m.mutex.Unlock()
+
+	removed := []string{}
+	var firstErr error
+	for shard, group := range plan {
+		if len(group) == len(shard.GetSymbols()) {
+			m.dropShard(shard)
+			removed = append(removed, group...)
+			continue
+		}
+
+		done, err := shard.RemoveSymbols(group...)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		removed = append(removed, done...)
+	}
+
+	return removed, firstErr
+}
+
+func (m *ConnectionManager) dropShard(shard *BinanceClient) {
+	m.mutex.Lock()
+	for i, s := range m.shards {
+		if s == shard {
+			m.shards = append(m.shards[:i], m.shards[i+1:]...)
+			break
+		}
+	}
+	m.mutex.Unlock()
+
+	shard.Close()
+}
+
+func (m *ConnectionManager) rotateLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-ticker.C:
+			m.mutex.RLock()
+			shards := append([]*BinanceClient(nil), m.shards...)
+			m.mutex.RUnlock()
+
+			for _, shard := range shards {
+				health := shard.Health()
+				if health.Connected && time.Since(health.ConnectedAt) > m.MaxConnAge {
+					m.rotate(shard)
+				}
+			}
+		}
+	}
+}
+
+// rotate replaces a shard make-before-break: the new socket runs alongside the old
+// one until it delivers data, and the dedupe window hides the overlap.
+func (m *ConnectionManager) rotate(old *BinanceClient) {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return
+	}
+	replacement := m.newShardLocked(old.GetSymbols())
+	m.rotating[old] = true
+	m.rotating[replacement] = true
+	m.mutex.Unlock()
+
+	defer func() {
+		m.mutex.Lock()
+		delete(m.rotating, old)
+		delete(m.rotating, replacement)
+		m.mutex.Unlock()
+	}()
+
+	log.Printf("[conn %d] Rotating connection, replacement is conn %d", old.ID, replacement.ID)
+	replacement.Start()
+
+	deadline := time.After(rotateTimeout)
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-deadline:
+			log.Printf("[conn %d] Replacement never became healthy, keeping old connection", old.ID)
+			m.dropShard(replacement)
+			m.keep(old, replacement)
+			return
+		case <-time.After(time.Second):
+			if replacement.Health().Messages > 0 {
+				m.swap(old, replacement)
+				return
+			}
+		}
+	}
+}
+
+// swap hands the old shard's symbols to its replacement and closes it.
+// AddSymbols may have given the old shard symbols just before the rotation
+// began, the replacement subscribes to those before the old shard goes.
+func (m *ConnectionManager) swap(old, replacement *BinanceClient) {
+	m.mutex.Lock()
+	moved := []string{}
+	for s, shard := range m.owner {
+		if shard == old {
+			m.owner[s] = replacement
+			moved = append(moved, s)
+		}
+	}
+	m.mutex.Unlock()
+
+	if _, err := replacement.AddSymbols(moved...); err != nil {
+		log.Printf("[conn %d] Failed to move symbols to the replacement: %v", replacement.ID, err)
+	}
+	m.dropShard(old)
+}
+
+// keep returns the symbols of a failed replacement to the old shard. Symbols
+// removed while the rotation ran stay removed.
+func (m *ConnectionManager) keep(old, replacement *BinanceClient) {
+	m.mutex.Lock()
+	for s, shard := range m.owner {
+		if shard == replacement {
+			m.owner[s] = old
+		}
+	}
+	symbols := old.GetSymbols()
+	stale := []string{}
+	for _, s := range symbols {
+		if m.owner[s] != old {
+			stale = append(stale, s)
+		}
+	}
+	m.mutex.Unlock()
+
+	if len(stale) == 0 {
+		return
+	}
+	if len(stale) == len(symbols) {
+		m.dropShard(old)
+		return
+	}
+	if _, err := old.RemoveSymbols(stale...); err != nil {
+		log.Printf("[conn %d] Failed to unsubscribe removed symbols: %v", old.ID, err)
+	}
+}
+
+func (m *ConnectionManager) Health() []ConnectionHealth {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	health := make([]ConnectionHealth, 0, len(m.shards))
+	for _, shard := range m.shards {
+		health = append(health, shard.Health())
+	}
+	return health
+}
+
+func (m *ConnectionManager) Close() {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return
+	}
+	m.closed = true
+	close(m.quit)
+	shards := append([]*BinanceClient(nil), m.shards...)
+	m.mutex.Unlock()
+
+	for _, shard := range shards {
+		shard.Close()
+	}
+	m.wg.Wait()
+	close(m.MessageChan)
+}
+
+func dedupeKey(ev streamEvent) string {
+	switch ev.EventType {
+	case "trade":
+		return fmt.Sprintf("t|%s|%d", ev.Symbol, ev.TradeID)
+	case "ticker":
+		return fmt.Sprintf("k|%s|%d", ev.Symbol, ev.Timestamp)
+	case "candle":
+		return fmt.Sprintf("c|%s|%d", ev.Symbol, ev.OpenTime)
+	}
+	return ""
+}
+
+// dedupeWindow remembers the last N keys in a ring buffer.
+type dedupeWindow struct {
+	mutex sync.Mutex
+	keys  []string
+	index map[string]struct{}
+	next  int
+}
+
+func newDedupeWindow(size int) *dedupeWindow {
+	return &dedupeWindow{
+		keys:  make([]string, size),
+		index: make(map[string]struct{}, size),
+	}
+}
+
+func (d *dedupeWindow) seen(key string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.index[key]; ok {
+		return true
+	}
+
+	if old := d.keys[d.next]; old != "" {
+		delete(d.index, old)
+	}
+	d.keys[d.next] = key
+	d.index[key] = struct{}{}
+	d.next = (d.next + 1) % len(d.keys)
+	return false
+}
@@ -0,0 +1,146 @@
+package exchange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeBinance accepts combined-stream sockets and, while feed is set, sends
+// each one a trade so it counts as healthy.
+func fakeBinance(t *testing.T, feed *atomic.Bool) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if feed.Load() {
+			conn.WriteMessage(websocket.TextMessage, []byte(tradePayload))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	wsURL := BinanceWSURL
+	BinanceWSURL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/stream?streams="
+	t.Cleanup(func() { BinanceWSURL = wsURL })
+}
+
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startManager(t *testing.T, symbols []string, perConn int) (*ConnectionManager, *BinanceClient) {
+	t.Helper()
+	m := NewConnectionManager(symbols, perConn)
+	t.Cleanup(m.Close)
+	m.Start()
+	old := m.shards[0]
+	eventually(t, "the shard connects", func() bool { return old.Health().Connected })
+	return m, old
+}
+
+func (m *ConnectionManager) isRotating(shard *BinanceClient) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.rotating[shard]
+}
+
+func (m *ConnectionManager) ownerOf(symbol string) (*BinanceClient, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	shard, ok := m.owner[symbol]
+	return shard, ok
+}
+
+func TestRotateMovesLateSymbols(t *testing.T) {
+	var feed atomic.Bool
+	feed.Store(true)
+	fakeBinance(t, &feed)
+	m, old := startManager(t, []string{"btcusdt"}, 2)
+
+	// AddSymbols planned ethusdt for the old shard but has not subscribed it yet.
+	m.mutex.Lock()
+	m.owner["ethusdt"] = old
+	m.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.rotate(old)
+		close(done)
+	}()
+	eventually(t, "the rotation starts", func() bool { return m.isRotating(old) })
+
+	if _, err := m.AddSymbols("solusdt"); err != nil {
+		t.Fatal(err)
+	}
+	if shard, _ := m.ownerOf("solusdt"); shard == old || m.isRotating(shard) {
+		t.Error("a symbol added during the rotation went to a rotating shard")
+	}
+	<-done
+
+	replacement, _ := m.ownerOf("btcusdt")
+	if replacement == old {
+		t.Fatal("the shard was not replaced")
+	}
+	if shard, _ := m.ownerOf("ethusdt"); shard != replacement || !slices.Contains(replacement.GetSymbols(), "ethusdt") {
+		t.Errorf("ethusdt is not on the replacement: %v", replacement.GetSymbols())
+	}
+	if got := m.GetSymbols(); len(got) != 3 {
+		t.Errorf("symbols = %v, want btcusdt, ethusdt and solusdt", got)
+	}
+}
+
+func TestFailedRotateKeepsRemovals(t *testing.T) {
+	var feed atomic.Bool
+	fakeBinance(t, &feed)
+	timeout := rotateTimeout
+	rotateTimeout = 1500 * time.Millisecond
+	defer func() { rotateTimeout = timeout }()
+
+	m, old := startManager(t, []string{"btcusdt", "ethusdt"}, 2)
+
+	done := make(chan struct{})
+	go func() {
+		m.rotate(old)
+		close(done)
+	}()
+	eventually(t, "the rotation starts", func() bool { return m.isRotating(old) })
+	if _, err := m.RemoveSymbols("ethusdt"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if shard, _ := m.ownerOf("btcusdt"); shard != old {
+		t.Error("btcusdt did not go back to the old shard")
+	}
+	if _, ok := m.ownerOf("ethusdt"); ok {
+		t.Error("a symbol removed during the rotation is owned again")
+	}
+	if got := old.GetSymbols(); len(got) != 1 || got[0] != "btcusdt" {
+		t.Errorf("old shard symbols = %v, want btcusdt", got)
+	}
+	if health := m.Health(); len(health) != 1 || health[0].ID != old.ID {
+		t.Errorf("shards = %+v, want the old one only", health)
+	}
+}
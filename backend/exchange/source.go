@@ -0,0 +1,9 @@
+package exchange
+
+// Source is anything that produces normalized market messages for the hub.
+// ConnectionManager reads them live from Binance, replay.Player from recordings.
+type Source interface {
+	Start()
+	GetMessageChannel() <-chan []byte
+	Close()
+}
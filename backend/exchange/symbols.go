@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"cropto-dashboard/storage"
+	"regexp"
+	"strings"
+)
+
+var DefaultSymbols = []string{"btcusdt", "ethusdt", "bnbusdt", "solusdt",
+	"dogeusdt", "adausdt", "xrpusdt", "maticusdt",
+	"linkusdt", "dotusdt", "avaxusdt", "uniusdt",
+	"ltcusdt", "atomusdt", "etcusdt", "xlmusdt",
+	"vetusdt", "filusdt", "trxusdt", "algousdt"}
+
+var symbolPattern = regexp.MustCompile(`^[a-z0-9]{5,20}$`)
+
+func NormalizeSymbol(symbol string) string {
+	return strings.ToLower(strings.TrimSpace(symbol))
+}
+
+func IsValidSymbol(symbol string) bool {
+	return symbolPattern.MatchString(NormalizeSymbol(symbol))
+}
+
+// SymbolStore persists the active symbol list so runtime changes survive restarts.
+type SymbolStore struct {
+	file *storage.JSONFile
+}
+
+func NewSymbolStore(path string) *SymbolStore {
+	return &SymbolStore{file: storage.NewJSONFile(path)}
+}
+
+func (s *SymbolStore) Load(defaults []string) ([]string, error) {
+	var symbols []string
+	found, err := s.file.Load(&symbols)
+	if err != nil {
+		return defaults, err
+	}
+	if !found {
+		return defaults, nil
+	}
+	return symbols, nil
+}
+
+func (s *SymbolStore) Save(symbols []string) error {
+	return s.file.Save(symbols)
+}
+
+// QuoteAssets lists the quote currencies SplitSymbol recognises, in match order.
+var QuoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "EUR", "TRY", "BTC", "ETH", "BNB"}
+
+// SplitSymbol splits a pair such as "ETHBTC" into its base and quote assets.
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	for _, q := range QuoteAssets {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return strings.TrimSuffix(symbol, q), q, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,164 @@
+package exchange
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Binance limits REST calls by request weight per IP and minute. Past the
+// limit it answers 429, and IPs that keep going get 418 and a ban.
+var (
+	// BinanceWeightLimit is the weight per minute this process allows itself,
+	// below Binance's 6000 to leave room for other clients on the same IP.
+	BinanceWeightLimit = 4800
+	// MaxWeightWait is how long a request may queue for weight before it
+	// fails with a RateLimitError.
+	MaxWeightWait = 5 * time.Second
+	// MaxParallelRequests bounds GetMultipleHistoricalData.
+	MaxParallelRequests = 4
+)
+
+// RateLimitError is returned instead of calling Binance while the weight is
+// spent or Binance asked us to back off.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Banned     bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Banned {
+		return fmt.Sprintf("binance has banned this IP, retry in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("binance request weight exhausted, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+type WeightStatus struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+	// BlockedUntil is set while backing off after a 429 or 418 (ms).
+	BlockedUntil int64 `json:"blockedUntil,omitempty"`
+	Banned       bool  `json:"banned,omitempty"`
+}
+
+type weightGate struct {
+	mutex        sync.Mutex
+	window       time.Time
+	used         int
+	blockedUntil time.Time
+	banned       bool
+	now          func() time.Time
+}
+
+var (
+	gate = &weightGate{now: time.Now}
+
+	// inflight collapses concurrent requests for the same URL into one call.
+	inflight singleflight.Group
+)
+
+// roll starts a new count when the minute changes, like Binance's window.
+func (g *weightGate) roll(now time.Time) {
+	if window := now.Truncate(time.Minute); window.After(g.window) {
+		g.window = window
+		g.used = 0
+	}
+}
+
+// reserve counts weight against the current minute. When the minute's
+// budget is spent or Binance asked to back off, it waits up to
+// MaxWeightWait and fails after that.
+func (g *weightGate) reserve(weight int) error {
+	for {
+		g.mutex.Lock()
+		now := g.now()
+		g.roll(now)
+
+		var wait time.Duration
+		switch {
+		case now.Before(g.blockedUntil):
+			wait = g.blockedUntil.Sub(now)
+			if wait > MaxWeightWait {
+				err := &RateLimitError{RetryAfter: wait, Banned: g.banned}
+				g.mutex.Unlock()
+				return err
+			}
+		// A request heavier than the whole budget still goes out on a
+		// fresh minute.
+		case g.used > 0 && g.used+weight > BinanceWeightLimit:
+			wait = g.window.Add(time.Minute).Sub(now)
+			if wait > MaxWeightWait {
+				g.mutex.Unlock()
+				return &RateLimitError{RetryAfter: wait}
+			}
+		default:
+			g.used += weight
+			g.mutex.Unlock()
+			return nil
+		}
+		g.mutex.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// observe takes Binance's count of the used weight, which includes other
+// clients on our IP, and backs off on 429 and 418.
+func (g *weightGate) observe(res *http.Response) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.now()
+	g.roll(now)
+
+	if used, err := strconv.Atoi(res.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil && used > g.used {
+		g.used = used
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusTeapot {
+		return nil
+	}
+	wait := time.Minute
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+	g.blockedUntil = now.Add(wait)
+	g.banned = res.StatusCode == http.StatusTeapot
+	log.Printf("Binance answered %d, pausing REST requests for %s", res.StatusCode, wait)
+	return &RateLimitError{RetryAfter: wait, Banned: g.banned}
+}
+
+func (g *weightGate) status() WeightStatus {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.now()
+	g.roll(now)
+	s := WeightStatus{Used: g.used, Limit: BinanceWeightLimit}
+	if now.Before(g.blockedUntil) {
+		s.BlockedUntil = g.blockedUntil.UnixMilli()
+		s.Banned = g.banned
+	}
+	return s
+}
+
+// RESTWeight reports the request weight used in the current minute.
+func RESTWeight() WeightStatus {
+	return gate.status()
+}
+
+// klinesWeight is the weight Binance charges for a /klines call.
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit < 1000:
+		return 5
+	default:
+		return 10
+	}
+}
@@ -0,0 +1,233 @@
+// Package health decides whether a node should receive traffic: the stream
+// must be fresh and every upstream check must pass.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+type Config struct {
+	// StreamStale is how long the node may go without any stream message
+	// before it is not ready. 0 disables the check, as for replays.
+	StreamStale time.Duration
+	// SymbolStale marks a single symbol stale, which degrades the node but
+	// keeps it ready: quiet pairs can go minutes without a trade.
+	SymbolStale time.Duration
+	// RESTInterval is how often the Binance REST API is pinged, 0 disables.
+	RESTInterval time.Duration
+	// RequireREST makes the node unready while Binance REST is unreachable.
+	RequireREST bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		StreamStale:  30 * time.Second,
+		SymbolStale:  2 * time.Minute,
+		RESTInterval: 30 * time.Second,
+	}
+}
+
+// Check is the state of one upstream.
+type Check struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type SymbolState struct {
+	LastMessageAt time.Time `json:"lastMessageAt"`
+	// Age is in seconds, -1 when nothing was received yet.
+	Age   float64 `json:"age"`
+	Stale bool    `json:"stale"`
+}
+
+type Report struct {
+	Status  string                 `json:"status"`
+	Reasons []string               `json:"reasons,omitempty"`
+	Uptime  float64                `json:"uptime"`
+	Stream  Check                  `json:"stream"`
+	Checks  map[string]Check       `json:"checks"`
+	Symbols map[string]SymbolState `json:"symbols"`
+}
+
+type namedCheck struct {
+	name string
+	fn   func() Check
+}
+
+type Monitor struct {
+	cfg     Config
+	symbols func() []string
+	ping    func() error
+	started time.Time
+
+	mutex       sync.RWMutex
+	checks      []namedCheck
+	lastMessage time.Time
+	lastSymbol  map[string]time.Time
+	rest        *Check
+}
+
+// NewMonitor reports on the symbols listed by symbols and pings Binance REST
+// with ping.
+func NewMonitor(cfg Config, symbols func() []string, ping func() error) *Monitor {
+	return &Monitor{
+		cfg:        cfg,
+		symbols:    symbols,
+		ping:       ping,
+		started:    time.Now(),
+		lastSymbol: make(map[string]time.Time),
+	}
+}
+
+// AddCheck registers an upstream, evaluated on every report.
+func (m *Monitor) AddCheck(name string, fn func() Check) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.checks = append(m.checks, namedCheck{name: name, fn: fn})
+}
+
+// OnMessage records when the node last received the stream and each symbol.
+func (m *Monitor) OnMessage(message []byte) {
+	var head struct {
+		Symbol     string `json:"symbol"`
+		Backfilled bool   `json:"backfilled"`
+	}
+	if err := json.Unmarshal(message, &head); err != nil || head.Backfilled {
+		return
+	}
+	now := time.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastMessage = now
+	if head.Symbol != "" {
+		m.lastSymbol[head.Symbol] = now
+	}
+}
+
+// Run pings Binance REST every RESTInterval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	if m.cfg.RESTInterval <= 0 || m.ping == nil {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.RESTInterval)
+	defer ticker.Stop()
+	for {
+		m.checkREST()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) checkREST() {
+	start := time.Now()
+	err := m.ping()
+	check := Check{Status: StatusOK, Details: map[string]interface{}{
+		"checkedAt": start,
+		"latencyMs": time.Since(start).Milliseconds(),
+	}}
+	if err != nil {
+		check.Status = StatusDegraded
+		if m.cfg.RequireREST {
+			check.Status = StatusDown
+		}
+		check.Message = err.Error()
+	}
+	m.mutex.Lock()
+	m.rest = &check
+	m.mutex.Unlock()
+}
+
+// Live only says the process is serving; restarting it does not bring a
+// dead upstream back.
+func (m *Monitor) Live() map[string]interface{} {
+	return map[string]interface{}{
+		"status": StatusOK,
+		"uptime": time.Since(m.started).Seconds(),
+	}
+}
+
+func (m *Monitor) Report() Report {
+	m.mutex.RLock()
+	checks := append([]namedCheck(nil), m.checks...)
+	lastMessage := m.lastMessage
+	lastSymbol := make(map[string]time.Time, len(m.lastSymbol))
+	for s, t := range m.lastSymbol {
+		lastSymbol[s] = t
+	}
+	rest := m.rest
+	m.mutex.RUnlock()
+
+	now := time.Now()
+	r := Report{
+		Status:  StatusOK,
+		Uptime:  now.Sub(m.started).Seconds(),
+		Checks:  make(map[string]Check),
+		Symbols: make(map[string]SymbolState),
+	}
+	worsen := func(status, reason string) {
+		if status == StatusDown || (status == StatusDegraded && r.Status == StatusOK) {
+			r.Status = status
+		}
+		if status != StatusOK {
+			r.Reasons = append(r.Reasons, reason)
+		}
+	}
+
+	r.Stream = Check{Status: StatusOK}
+	switch {
+	case m.cfg.StreamStale <= 0:
+		r.Stream.Message = "staleness check disabled"
+	case lastMessage.IsZero():
+		r.Stream = Check{Status: StatusDown, Message: "no stream message received yet"}
+	case now.Sub(lastMessage) > m.cfg.StreamStale:
+		r.Stream = Check{Status: StatusDown, Message: "no stream message for " + now.Sub(lastMessage).Round(time.Second).String()}
+	}
+	if r.Stream.Status == StatusOK && !lastMessage.IsZero() {
+		r.Stream.Details = map[string]interface{}{"lastMessageAt": lastMessage, "age": now.Sub(lastMessage).Seconds()}
+	}
+	worsen(r.Stream.Status, "stream: "+r.Stream.Message)
+
+	for _, c := range checks {
+		check := c.fn()
+		r.Checks[c.name] = check
+		worsen(check.Status, c.name+": "+check.Message)
+	}
+	if rest != nil {
+		r.Checks["binance_rest"] = *rest
+		worsen(rest.Status, "binance_rest: "+rest.Message)
+	}
+
+	var stale []string
+	for _, symbol := range m.symbols() {
+		state := SymbolState{Age: -1}
+		if t, ok := lastSymbol[symbol]; ok {
+			state.LastMessageAt = t
+			state.Age = now.Sub(t).Seconds()
+		}
+		state.Stale = m.cfg.SymbolStale > 0 && (state.Age < 0 || state.Age > m.cfg.SymbolStale.Seconds())
+		if state.Stale {
+			stale = append(stale, symbol)
+		}
+		r.Symbols[symbol] = state
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		worsen(StatusDegraded, "stale symbols: "+strings.Join(stale, ", "))
+	}
+	return r
+}
@@ -0,0 +1,15 @@
+// Package ids makes the random identifiers and secrets of stored records.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// New returns n random bytes as hex. Record IDs use 8 bytes and secrets
+// such as account tokens and API keys 24.
+func New(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,14 +2,40 @@ package main
 
 import (
 	"context"
+	"cropto-dashboard/analytics"
+	"cropto-dashboard/anomaly"
+	"cropto-dashboard/arbitrage"
+	"cropto-dashboard/auth"
+	"cropto-dashboard/broker"
+	"cropto-dashboard/config"
+	"cropto-dashboard/decimal"
 	"cropto-dashboard/exchange"
+	"cropto-dashboard/health"
+	"cropto-dashboard/market"
+	"cropto-dashboard/metrics"
+	"cropto-dashboard/paper"
+	"cropto-dashboard/portfolio"
+	"cropto-dashboard/ratelimit"
+	"cropto-dashboard/rates"
+	"cropto-dashboard/recorder"
+	"cropto-dashboard/replay"
+	"cropto-dashboard/server/gql"
+	"cropto-dashboard/server/rpc"
 	"cropto-dashboard/server/websocket"
+	"cropto-dashboard/storage"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -17,33 +43,194 @@ import (
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.File() != "" {
+		log.Printf("Loaded configuration from %s", cfg.File())
+	}
+	applyLimits(cfg)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	fx := make(map[string]decimal.Decimal)
+	for currency, rate := range rates.DefaultFX {
+		fx[currency] = rate
+	}
+	if cfg.Rates.FX != "" {
+		table, err := rates.ParseFX(cfg.Rates.FX)
+		if err != nil {
+			log.Fatalf("Invalid FX_RATES: %v", err)
+		}
+		for currency, rate := range table {
+			fx[currency] = rate
+		}
+	}
+	rateEngine := rates.NewEngine(fx)
+
 	hub := websocket.NewHub()
+	hub.SetConverter(rateEngine.ConvertMessage)
+	if err := hub.SetReplaySize(cfg.Stream.ReplaySize); err != nil {
+		log.Fatalf("Failed to set up the stream: %v", err)
+	}
+	registerStreamCommands(hub, rateEngine)
 	log.Println("Starting websocket Hub...")
 	go hub.Run()
 
-	symbols := []string{"btcusdt", "ethusdt", "bnbusdt", "solusdt",
-		"dogeusdt", "adausdt", "xrpusdt", "maticusdt",
-		"linkusdt", "dotusdt", "avaxusdt", "uniusdt",
-		"ltcusdt", "atomusdt", "etcusdt", "xlmusdt",
-		"vetusdt", "filusdt", "trxusdt", "algousdt"}
-	binanceClient := *exchange.NewBinanceClient(symbols)
-	binanceClient.Start()
+	dataDir := cfg.Server.DataDir
+	symbolStore := exchange.NewSymbolStore(filepath.Join(dataDir, "symbols.json"))
+
+	paperEngine, err := paper.NewEngine(storage.NewJSONFile(filepath.Join(dataDir, "paper.json")), paper.DefaultFeeRate)
+	if err != nil {
+		log.Fatalf("Failed to load paper trading state: %v", err)
+	}
+	registerPaperCommands(hub, paperEngine)
+
+	tracker, err := portfolio.NewTracker(storage.NewJSONFile(filepath.Join(dataDir, "portfolios.json")))
+	if err != nil {
+		log.Fatalf("Failed to load portfolios: %v", err)
+	}
+	registerPortfolioCommands(hub, tracker, rateEngine)
+	go tracker.Run(ctx, time.Duration(cfg.Stream.PortfolioPushSeconds)*time.Second)
+
+	anomalies, err := anomaly.NewDetector(anomalyConfig(cfg.Anomaly))
+	if err != nil {
+		log.Fatalf("Failed to start anomaly detector: %v", err)
+	}
+
+	services := &app{
+		cfg:         cfg,
+		hub:         hub,
+		symbolStore: symbolStore,
+		paper:       paperEngine,
+		portfolio:   tracker,
+		rates:       rateEngine,
+		snapshot:    market.NewSnapshot(),
+		analytics:   analytics.NewCorrelations(time.Duration(cfg.Analytics.CacheSeconds) * time.Second),
+		risk:        analytics.NewRisk(time.Duration(cfg.Analytics.CacheSeconds) * time.Second),
+		anomalies:   anomalies,
+	}
+
+	if err := services.setupAuth(); err != nil {
+		log.Fatalf("Failed to set up authentication: %v", err)
+	}
+	websocket.SetCheckOrigin(services.origins.CheckOrigin)
+	gql.SetCheckOrigin(services.origins.CheckOrigin)
 
-	go bridgeExchangeToHub(ctx, binanceClient, hub)
+	services.limits = ratelimit.New(cfg.RateLimits())
+	go services.limits.Run(ctx)
 
-	router := setupRouter(hub)
+	role := cfg.Server.Role
+	services.role = role
+	if err := services.startBroker(); err != nil {
+		log.Fatalf("Failed to start broker: %v", err)
+	}
 
+	// Edge nodes take the stream from the broker instead of the exchange.
+	if role == "ingest" {
+		if dir := cfg.Replay.Dir; dir != "" {
+			player, err := newReplayPlayer(cfg.Replay)
+			if err != nil {
+				log.Fatalf("Failed to start replay: %v", err)
+			}
+			services.player = player
+			services.source = player
+			log.Printf("Replaying recorded market data from %s", dir)
+		} else {
+			symbols, err := symbolStore.Load(exchange.DefaultSymbols)
+			if err != nil {
+				log.Printf("Failed to load symbols, using defaults: %v", err)
+			}
+			log.Printf("Tracking %d symbols", len(symbols))
+
+			binanceClient := exchange.NewConnectionManager(symbols, cfg.Exchange.SymbolsPerConnection)
+			services.binanceClient = binanceClient
+			services.source = binanceClient
+		}
+		services.source.Start()
+	}
+
+	if cfg.Arbitrage.Enabled {
+		monitor, err := newArbitrageMonitor(cfg.Arbitrage, dataDir)
+		if err != nil {
+			log.Fatalf("Failed to start arbitrage monitor: %v", err)
+		}
+		monitor.SetNotifier(hub.Broadcast)
+		services.arbitrage = monitor
+		services.venue = exchange.NewCoinbaseClient(services.trackedSymbols())
+		services.venue.Start()
+		go monitor.Run(ctx, services.venue.GetMessageChannel())
+		log.Println("Monitoring Binance/Coinbase spreads")
+	}
+
+	services.snapshot.SetValuer(func(amount decimal.Decimal, asset string) (decimal.Decimal, error) {
+		return rateEngine.Convert(amount, asset, market.OverviewCurrency)
+	})
+	services.snapshot.SetNotifier(hub.Broadcast)
+	if seconds := cfg.Stream.OverviewPushSeconds; seconds > 0 {
+		go services.snapshot.Run(ctx, time.Duration(seconds)*time.Second, cfg.Stream.OverviewSize)
+	}
+
+	services.anomalies.SetNotifier(hub.Broadcast)
+	go services.anomalies.Run(ctx)
+
+	services.risk.SetNotifier(hub.Broadcast)
+	if seconds := cfg.Stream.RiskPushSeconds; seconds > 0 {
+		go services.risk.Run(ctx, time.Duration(seconds)*time.Second, services.trackedSymbols)
+	}
+
+	if dir := cfg.Recorder.Dir; dir != "" {
+		recCfg := recorder.DefaultConfig(dir)
+		recCfg.SegmentBytes = int64(cfg.Recorder.SegmentMB) << 20
+		recCfg.SegmentAge = time.Duration(cfg.Recorder.SegmentMinutes) * time.Minute
+		recCfg.Retention = time.Duration(cfg.Recorder.RetentionHours) * time.Hour
+		recCfg.MaxTotalBytes = int64(cfg.Recorder.MaxTotalMB) << 20
+		recCfg.RecordRaw = cfg.Recorder.Raw
+
+		rec, err := recorder.New(recCfg)
+		if err != nil {
+			log.Fatalf("Failed to start recorder: %v", err)
+		}
+		go rec.Run()
+		if services.binanceClient != nil {
+			services.binanceClient.SetRawHandler(rec.RecordRaw)
+		}
+		services.recorder = rec
+		log.Printf("Recording market data to %s", dir)
+	}
+
+	subject := cfg.Broker.Subject
+	services.stream = broker.NewSource(services.broker, subject)
+	services.stream.Start()
+	if services.source != nil {
+		go publishToBroker(ctx, services.source, services.broker, subject)
+	}
+	services.health = health.NewMonitor(healthConfig(cfg.Health, services.player != nil), services.trackedSymbols, exchange.Ping)
+	services.addHealthChecks()
+	go services.health.Run(ctx)
+
+	go bridgeExchangeToHub(ctx, services.stream, hub, services.consumers()...)
+
+	registerGauges(services)
+	router := setupRouter(services)
+
+	// Requests derive from streamCtx so SSE streams end when shutdown starts
+	// instead of holding it until the deadline.
+	streamCtx, stopStreams := context.WithCancel(ctx)
 	srv := &http.Server{
-		Addr:           ":8000",
+		Addr:           cfg.Server.HTTPAddr,
 		Handler:        router,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		BaseContext:    func(net.Listener) context.Context { return streamCtx },
 	}
+	srv.RegisterOnShutdown(stopStreams)
 
 	go func() {
 		log.Printf("server has been started on http://localhost%s", srv.Addr)
@@ -52,27 +239,288 @@ func main() {
 		}
 	}()
 
+	var grpcAuth func(credential string) (*auth.Identity, error)
+	if services.authPolicy == auth.PolicyRequired {
+		grpcAuth = services.authn.Authenticate
+	}
+	grpcServer := rpc.NewServer(hub, grpcAuth, services.limits)
+	grpcAddr := cfg.Server.GRPCAddr
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC on %s: %v", grpcAddr, err)
+	}
+	go func() {
+		log.Printf("gRPC server has been started on %s", grpcAddr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Printf("Failed to start gRPC server: %v", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("Shutting down server...")
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownSeconds)*time.Second)
 	defer shutdownCancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
-	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server forced to shutdown: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("gRPC server forced to shutdown: %v", err)
+		}
+	}()
+	wg.Wait()
 
-	binanceClient.Close()
+	if services.source != nil {
+		services.source.Close()
+	}
+	if services.venue != nil {
+		services.venue.Close()
+	}
 
 	cancel()
 
+	services.stream.Close()
+	services.broker.Close()
+	if services.embedded != nil {
+		services.embedded.Shutdown()
+	}
+
+	if services.recorder != nil {
+		services.recorder.Close()
+	}
+	services.paper.Close()
+	services.portfolio.Close()
+	if services.arbitrage != nil {
+		services.arbitrage.Close()
+	}
+
 	log.Println("Server exited")
 }
 
-func bridgeExchangeToHub(ctx context.Context, client exchange.BinanceClient, hub *websocket.Hub) {
+// app holds the long-lived services shared by the bridge and the HTTP handlers.
+type app struct {
+	cfg           *config.Config
+	hub           *websocket.Hub
+	source        exchange.Source
+	binanceClient *exchange.ConnectionManager
+	player        *replay.Player
+	symbolStore   *exchange.SymbolStore
+	recorder      *recorder.Recorder
+	paper         *paper.Engine
+	portfolio     *portfolio.Tracker
+	rates         *rates.Engine
+	snapshot      *market.Snapshot
+	analytics     *analytics.Correlations
+	risk          *analytics.Risk
+	arbitrage     *arbitrage.Monitor
+	anomalies     *anomaly.Detector
+	// venue is the second exchange feed the arbitrage monitor compares against.
+	venue exchange.Source
+
+	// role is "ingest" for nodes holding the exchange connections and "edge"
+	// for nodes that only serve what the broker carries. stream is the
+	// broker subscription feeding the hub on both.
+	role     string
+	broker   broker.Broker
+	embedded *broker.Embedded
+	stream   *broker.Source
+
+	users      *auth.Store
+	authn      *auth.Authenticator
+	authPolicy string
+	origins    auth.Origins
+
+	limits *ratelimit.Limiter
+	health *health.Monitor
+}
+
+// setupAuth loads the user accounts and session secret. The first start
+// creates an admin account and writes its key to a file only the owner can
+// read, so it never reaches the logs.
+func (a *app) setupAuth() error {
+	a.authPolicy = a.cfg.Auth.Policy
+	a.origins = auth.ParseOrigins(strings.Join(a.cfg.Auth.AllowedOrigins, ","))
+
+	users, err := auth.NewStore(storage.NewJSONFile(filepath.Join(a.cfg.Server.DataDir, "users.json")))
+	if err != nil {
+		return err
+	}
+	a.users = users
+
+	if users.Count() == 0 {
+		admin, err := users.CreateUser("admin", auth.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		key, _, err := users.CreateKey(admin.ID, "bootstrap")
+		if err != nil {
+			return err
+		}
+		// A leftover file would keep its old permissions.
+		path := filepath.Join(a.cfg.Server.DataDir, "bootstrap-admin-key")
+		os.Remove(path)
+		if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write the admin key: %w", err)
+		}
+		log.Printf("Created admin user %s, its API key is in %s", admin.ID, path)
+	}
+
+	secret := []byte(a.cfg.Auth.JWTSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		log.Println("AUTH_JWT_SECRET is not set, sessions end on restart and are not shared between nodes")
+	}
+	a.authn = auth.NewAuthenticator(users, secret, time.Duration(a.cfg.Auth.SessionMinutes)*time.Minute)
+
+	if a.authPolicy == auth.PolicyOpen {
+		log.Println("AUTH_POLICY is open, the API accepts anonymous requests")
+	}
+	return nil
+}
+
+// startBroker picks the in-process broker for a single node and NATS when
+// BROKER_URL or BROKER_LISTEN is set. BROKER_LISTEN runs the NATS server
+// inside this process.
+func (a *app) startBroker() error {
+	url := a.cfg.Broker.URL
+	if addr := a.cfg.Broker.Listen; addr != "" {
+		embedded, err := broker.StartEmbedded(addr, a.cfg.Broker.Token)
+		if err != nil {
+			return err
+		}
+		a.embedded = embedded
+		log.Printf("Broker listening on %s", embedded.ClientURL())
+		if url == "" {
+			url = embedded.ClientURL()
+		}
+	}
+
+	if url == "" {
+		if a.role == "edge" {
+			return fmt.Errorf("edge nodes need BROKER_URL")
+		}
+		a.broker = broker.NewLocal()
+		return nil
+	}
+	nats, err := broker.ConnectNATS(url, "cropto-"+a.role, a.cfg.Broker.Token)
+	if err != nil {
+		return err
+	}
+	a.broker = nats
+	return nil
+}
+
+// addHealthChecks registers the upstreams readiness depends on. The
+// Coinbase feed only serves the arbitrage monitor, so losing it degrades
+// the node without taking it out of rotation.
+func (a *app) addHealthChecks() {
+	if a.binanceClient != nil {
+		a.health.AddCheck("binance", func() health.Check {
+			return connectionsCheck(a.binanceClient.Health(), health.StatusDown)
+		})
+	}
+	if coinbase, ok := a.venue.(*exchange.CoinbaseClient); ok {
+		a.health.AddCheck("coinbase", func() health.Check {
+			return connectionsCheck([]exchange.ConnectionHealth{coinbase.Health()}, health.StatusDegraded)
+		})
+	}
+	if a.player != nil {
+		a.health.AddCheck("replay", func() health.Check {
+			status := a.player.Status()
+			return health.Check{Status: health.StatusOK, Message: status.State, Details: status}
+		})
+	}
+	a.health.AddCheck("broker", func() health.Check {
+		if !a.broker.Connected() {
+			return health.Check{Status: health.StatusDown, Message: "not connected"}
+		}
+		return health.Check{Status: health.StatusOK}
+	})
+}
+
+// connectionsCheck is ok with every connection up and failing when none is.
+func connectionsCheck(conns []exchange.ConnectionHealth, failing string) health.Check {
+	up := 0
+	for _, c := range conns {
+		if c.Connected {
+			up++
+		}
+	}
+	check := health.Check{
+		Status:  health.StatusOK,
+		Message: fmt.Sprintf("%d of %d connections up", up, len(conns)),
+		Details: conns,
+	}
+	switch {
+	case up == 0:
+		check.Status = failing
+	case up < len(conns):
+		check.Status = health.StatusDegraded
+	}
+	return check
+}
+
+// trackedSymbols lists the streamed symbols in the REST API's upper case.
+func (a *app) trackedSymbols() []string {
+	symbols := exchange.DefaultSymbols
+	if a.binanceClient != nil {
+		symbols = a.binanceClient.GetSymbols()
+	}
+	upper := make([]string, len(symbols))
+	for i, s := range symbols {
+		upper[i] = strings.ToUpper(s)
+	}
+	return upper
+}
+
+// consumers returns every service that sees the normalized stream next to the hub.
+func (a *app) consumers() []func(message []byte) {
+	consumers := []func(message []byte){a.observeStream, a.health.OnMessage, a.rates.OnMessage, a.snapshot.OnMessage, a.paper.OnMessage, a.portfolio.OnMessage, a.anomalies.OnMessage}
+	if a.recorder != nil {
+		consumers = append(consumers, a.recorder.RecordMessage)
+	}
+	if a.arbitrage != nil {
+		consumers = append(consumers, a.arbitrage.OnMessage)
+	}
+	return consumers
+}
+
+// publishToBroker hands the exchange stream to the broker, which carries it
+// to this node's hub and to every edge node.
+func publishToBroker(ctx context.Context, source exchange.Source, b broker.Broker, subject string) {
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-source.GetMessageChannel():
+			if !ok {
+				return
+			}
+			// While the broker is unreachable every publish fails the same way.
+			if err := b.Publish(subject, message); err != nil && err.Error() != lastErr {
+				log.Printf("Failed to publish to broker: %v", err)
+				lastErr = err.Error()
+			} else if err == nil {
+				lastErr = ""
+			}
+		}
+	}
+}
+
+func bridgeExchangeToHub(ctx context.Context, client exchange.Source, hub *websocket.Hub, consumers ...func(message []byte)) {
 	log.Println("Starting  exchange to hub bridge....")
 
 	for {
@@ -86,54 +534,123 @@ func bridgeExchangeToHub(ctx context.Context, client exchange.BinanceClient, hub
 				return
 			}
 			hub.Broadcast(message)
+			for _, consume := range consumers {
+				consume(message)
+			}
 		}
 	}
 }
 
-func setupRouter(hub *websocket.Hub) *gin.Engine {
+// observeStream counts a stream message and its latency since the exchange
+// stamped it. Backfilled and replayed events are old by design and left out
+// of latency.
+func (a *app) observeStream(message []byte) {
+	var head struct {
+		Symbol     string `json:"symbol"`
+		EventType  string `json:"eventType"`
+		Timestamp  int64  `json:"timestamp"`
+		Backfilled bool   `json:"backfilled"`
+	}
+	if err := json.Unmarshal(message, &head); err != nil {
+		return
+	}
+	metrics.MessagesIn.WithLabelValues(head.Symbol, head.EventType).Inc()
+	if !head.Backfilled && a.player == nil {
+		metrics.ObserveLatency(head.Timestamp)
+	}
+}
+
+// registerGauges exposes the current counts read at scrape time.
+func registerGauges(services *app) {
+	metrics.Gauge("websocket_clients", "Connected /ws clients.", func() float64 {
+		return float64(services.hub.GetClientCount())
+	})
+	metrics.Gauge("stream_listeners", "SSE, GraphQL and gRPC stream listeners.", func() float64 {
+		return float64(services.hub.ListenerCount())
+	})
+	metrics.Gauge("open_streams", "Streams holding a rate limiter slot.", func() float64 {
+		return float64(services.limits.OpenSockets())
+	})
+	metrics.Gauge("binance_weight_used", "Binance REST request weight used in the current minute.", func() float64 {
+		return float64(exchange.RESTWeight().Used)
+	})
+	metrics.Gauge("binance_connections_up", "Binance WebSocket connections currently connected.", func() float64 {
+		if services.binanceClient == nil {
+			return 0
+		}
+		up := 0
+		for _, h := range services.binanceClient.Health() {
+			if h.Connected {
+				up++
+			}
+		}
+		return float64(up)
+	})
+}
+
+func setupRouter(services *app) *gin.Engine {
+	hub := services.hub
 
 	router := gin.Default()
 
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	// The per-IP limits key on ClientIP, so X-Forwarded-For is only
+	// believed from TRUSTED_PROXIES.
+	if err := router.SetTrustedProxies(services.cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	router.Use(metricsMiddleware())
+	router.Use(corsMiddleware(services.origins))
+	router.Use(authMiddleware(services.authn, services.limits))
+
+	limited := rateLimit(services.limits, ratelimit.ClassRequest)
+
+	// With the open policy callers may still authenticate, but need not.
+	guard := func(role string) gin.HandlerFunc {
+		if services.authPolicy != auth.PolicyRequired {
+			return func(c *gin.Context) { c.Next() }
 		}
+		return requireRole(role)
+	}
 
-		c.Next()
-	})
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"Status":    "healthy",
-			"Clients":   hub.GetClientCount(),
-			"timestamp": time.Now().Unix(),
-		})
-	})
+	registerHealthRoutes(router, services.health, hub)
 
-	router.GET("/ws", func(ctx *gin.Context) {
-		websocket.ServeWS(hub, ctx.Writer, ctx.Request)
+	router.GET("/ws", guard(auth.RoleUser), limited, func(ctx *gin.Context) {
+		release, ok := acquireSocket(ctx, services.limits)
+		if !ok {
+			return
+		}
+		websocket.ServeWS(hub, ctx.Writer, ctx.Request, release)
 	})
 
-	api := router.Group("/api")
+	public := router.Group("/api", limited)
 	{
-		api.GET("ping", func(ctx *gin.Context) {
+		public.GET("ping", func(ctx *gin.Context) {
 			ctx.JSON(200, gin.H{"message": "pong"})
 		})
+		registerSessionRoutes(public, services.authn)
+	}
+
+	api := router.Group("/api", guard(auth.RoleUser), limited)
+	{
+		// Routes that call the Binance REST API also spend upstream tokens,
+		// which protect the node's IP weight.
+		upstream := api.Group("", rateLimit(services.limits, ratelimit.ClassUpstream))
 
 		api.GET("/stats", func(ctx *gin.Context) {
 			ctx.JSON(200, gin.H{
 				"connected clients": hub.GetClientCount(),
+				"stream listeners":  hub.ListenerCount(),
+				"open streams":      services.limits.OpenSockets(),
+				"binance weight":    exchange.RESTWeight(),
+				"role":              services.role,
 				"uptime in second":  time.Since(startTime).Seconds(),
 			})
 		})
 
-		api.GET("/chart/:symbol", func(c *gin.Context) {
+		upstream.GET("/chart/:symbol", func(c *gin.Context) {
 			symbol := strings.ToUpper(c.Param("symbol"))
 			interval := c.DefaultQuery("interval", "1h")
 			limitStr := c.DefaultQuery("limit", "100")
@@ -141,17 +658,161 @@ func setupRouter(hub *websocket.Hub) *gin.Engine {
 			limit := 100
 			fmt.Sscanf(limitStr, "%d", &limit)
 
+			// The route spent one token for the chart, converting may need more.
+			quote := c.Query("quote")
+			if quote != "" && !spendUpstream(c, services.limits, services.rates.HistoryCalls(symbol, quote)) {
+				return
+			}
+
 			data, err := exchange.GetHistoricalData(symbol, interval, limit)
 			if err != nil {
-				c.JSON(500, gin.H{"error": err.Error()})
+				upstreamError(c, 500, err)
 				return
 			}
 
+			if quote != "" {
+				if err := services.rates.ConvertChart(data, quote); err != nil {
+					c.JSON(400, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
 			c.JSON(200, data)
 		})
 
+		registerBacktestRoutes(upstream)
+		registerPaperRoutes(api, services.paper)
+		registerPortfolioRoutes(api, services.portfolio, services.rates)
+		registerRateRoutes(api, services.rates, services.snapshot)
+		registerMarketRoutes(api, services.snapshot)
+		registerSSERoutes(api, hub, services.rates, services.limits, time.Duration(services.cfg.Stream.HeartbeatSeconds)*time.Second)
+		registerAnomalyRoutes(api, services.anomalies)
+		if services.arbitrage != nil {
+			registerArbitrageRoutes(api, services.arbitrage)
+		}
+		registerAnalyticsRoutes(upstream, services.analytics, services.risk, services.trackedSymbols)
+		registerGraphQLRoutes(api, gql.NewSchema(gql.NewResolver(hub, services.snapshot, services.rates, services.anomalies, services.trackedSymbols)), services.limits)
+
+		// Admin actions need an admin credential whatever the policy.
+		admin := api.Group("/admin", requireRole(auth.RoleAdmin))
+		registerUserRoutes(admin.Group("/users"), services.users)
+		registerConfigRoutes(admin.Group("/config"), services.cfg)
+		if services.binanceClient != nil {
+			registerSymbolRoutes(admin, services.binanceClient, services.symbolStore)
+		}
+		if services.player != nil {
+			registerReplayRoutes(admin, services.player)
+		}
+		if services.recorder != nil {
+			registerRecorderRoutes(admin, services.recorder)
+		}
 	}
 	return router
 }
 
+func newReplayPlayer(cfg config.Replay) (*replay.Player, error) {
+	speed, err := replay.ParseSpeed(cfg.Speed)
+	if err != nil {
+		return nil, err
+	}
+	start, err := replay.ParseTime(cfg.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := replay.ParseTime(cfg.End)
+	if err != nil {
+		return nil, err
+	}
+
+	return replay.NewPlayer(replay.Config{
+		Dir:     cfg.Dir,
+		Speed:   speed,
+		Start:   start,
+		End:     end,
+		Symbols: cfg.Symbols,
+		Loop:    cfg.Loop,
+	})
+}
+
+func newArbitrageMonitor(settings config.Arbitrage, dataDir string) (*arbitrage.Monitor, error) {
+	cfg := arbitrage.DefaultConfig()
+	cfg.MinDuration = time.Duration(settings.MinSeconds) * time.Second
+
+	v, err := decimal.Parse(settings.ThresholdBps)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arbitrage threshold: %w", err)
+	}
+	cfg.ThresholdBps = v
+	v, err = decimal.Parse(settings.Notional)
+	if err != nil || !v.IsPositive() {
+		return nil, fmt.Errorf("invalid arbitrage notional")
+	}
+	cfg.Notional = v
+
+	fees, err := arbitrage.ParseTable(settings.FeesBps, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arbitrage fees: %w", err)
+	}
+	for venue, fee := range fees {
+		cfg.TakerFeeBps[venue] = fee
+	}
+	withdrawal, err := arbitrage.ParseTable(settings.WithdrawalFees, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arbitrage withdrawal fees: %w", err)
+	}
+	cfg.WithdrawalFee = withdrawal
+
+	return arbitrage.NewMonitor(cfg, storage.NewJSONFile(filepath.Join(dataDir, "arbitrage.json")))
+}
+
+func healthConfig(settings config.Health, replaying bool) health.Config {
+	cfg := health.DefaultConfig()
+	cfg.StreamStale = time.Duration(settings.StreamStaleSeconds) * time.Second
+	cfg.SymbolStale = time.Duration(settings.SymbolStaleSeconds) * time.Second
+	cfg.RESTInterval = time.Duration(settings.RESTSeconds) * time.Second
+	cfg.RequireREST = settings.RequireREST
+	// A replay may be paused on purpose.
+	if replaying {
+		cfg.StreamStale, cfg.SymbolStale = 0, 0
+	}
+	return cfg
+}
+
+func anomalyConfig(settings config.Anomaly) anomaly.Config {
+	cfg := anomaly.DefaultConfig()
+	cfg.PriceK = settings.PriceK
+	cfg.VolumeK = settings.VolumeK
+	cfg.VolumeMinRatio = settings.VolumeRatio
+	cfg.VolumeBucket = time.Duration(settings.BucketSeconds) * time.Second
+	cfg.StallAfter = time.Duration(settings.StallSeconds) * time.Second
+	cfg.Cooldown = time.Duration(settings.CooldownSeconds) * time.Second
+	return cfg
+}
+
+// applyLimits sets the package-level endpoints, buffer sizes and timeouts.
+// It runs before any connection or hub is created.
+func applyLimits(cfg *config.Config) {
+	symbols := make([]string, len(cfg.Exchange.Symbols))
+	for i, s := range cfg.Exchange.Symbols {
+		symbols[i] = exchange.NormalizeSymbol(s)
+	}
+	exchange.DefaultSymbols = symbols
+	exchange.BinanceWSURL = cfg.Exchange.BinanceWSURL
+	exchange.BinanceRESTURL = cfg.Exchange.BinanceRESTURL
+	exchange.CoinbaseWSURL = cfg.Exchange.CoinbaseWSURL
+	exchange.MaxReconnectDelay = time.Duration(cfg.Exchange.MaxReconnectSeconds) * time.Second
+	exchange.ConnectionBuffer = cfg.Exchange.ConnectionBuffer
+	exchange.StreamBuffer = cfg.Exchange.StreamBuffer
+	exchange.BinanceWeightLimit = cfg.Exchange.WeightLimit
+	exchange.MaxParallelRequests = cfg.Exchange.MaxParallel
+
+	websocket.PongWait = time.Duration(cfg.WebSocket.PongWaitSeconds) * time.Second
+	websocket.MaxMessageSize = int64(cfg.WebSocket.MaxMessageSize)
+	websocket.SendBuffer = cfg.WebSocket.SendBuffer
+	websocket.BroadcastBuffer = cfg.WebSocket.BroadcastBuffer
+	websocket.ListenerBuffer = cfg.WebSocket.ListenerBuffer
+	gql.PongWait = websocket.PongWait
+	broker.SourceBuffer = cfg.WebSocket.BrokerBuffer
+}
+
 var startTime = time.Now()
@@ -0,0 +1,187 @@
+package market
+
+import (
+	"context"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// OverviewCurrency is what volumes are ranked and totaled in when a valuer is set.
+const OverviewCurrency = "USDT"
+
+// MarketEntry is one symbol in a ranked list. RangePercent is the 24h
+// high-low range over the low, the volatility ranking.
+type MarketEntry struct {
+	Symbol        string          `json:"symbol"`
+	Price         decimal.Decimal `json:"price"`
+	ChangePercent decimal.Decimal `json:"changePercent"`
+	QuoteVolume   decimal.Decimal `json:"quoteVolume"`
+	RangePercent  decimal.Decimal `json:"rangePercent"`
+}
+
+type Breadth struct {
+	Advancers int `json:"advancers"`
+	Decliners int `json:"decliners"`
+	Unchanged int `json:"unchanged"`
+	// Ratio is advancers over decliners, 0 without decliners.
+	Ratio float64 `json:"ratio"`
+}
+
+type Overview struct {
+	EventType    string          `json:"eventType"`
+	Symbols      int             `json:"symbols"`
+	Currency     string          `json:"currency,omitempty"`
+	TotalVolume  decimal.Decimal `json:"totalQuoteVolume"`
+	Breadth      Breadth         `json:"breadth"`
+	Gainers      []MarketEntry   `json:"gainers"`
+	Losers       []MarketEntry   `json:"losers"`
+	VolumeLeader []MarketEntry   `json:"volumeLeaders"`
+	MostVolatile []MarketEntry   `json:"mostVolatile"`
+	// Unpriced lists symbols whose volume could not be converted into
+	// Currency. They count toward breadth but are left out of the lists.
+	Unpriced  []string `json:"unpriced,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// Valuer converts an amount of asset into OverviewCurrency.
+type Valuer func(amount decimal.Decimal, asset string) (decimal.Decimal, error)
+
+// SetValuer makes volumes comparable across quote assets. Without one, quote
+// volumes are used as they are.
+func (s *Snapshot) SetValuer(fn Valuer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.valuer = fn
+}
+
+// SetNotifier receives the overview Run builds every interval.
+func (s *Snapshot) SetNotifier(fn func(message []byte)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notify = fn
+}
+
+// Overview ranks the latest tickers, keeping the top n of each list.
+func (s *Snapshot) Overview(n int) *Overview {
+	s.mutex.RLock()
+	valuer := s.valuer
+	s.mutex.RUnlock()
+
+	tickers := s.Tickers()
+	o := &Overview{
+		EventType:   "overview",
+		Symbols:     len(tickers),
+		TotalVolume: decimal.Zero,
+		Timestamp:   time.Now().UnixMilli(),
+	}
+	if valuer != nil {
+		o.Currency = OverviewCurrency
+	}
+
+	entries := make([]MarketEntry, 0, len(tickers))
+	for _, t := range tickers {
+		switch t.ChangePercent.Sign() {
+		case 1:
+			o.Breadth.Advancers++
+		case -1:
+			o.Breadth.Decliners++
+		default:
+			o.Breadth.Unchanged++
+		}
+
+		e, ok := entryFor(t, valuer)
+		if !ok {
+			o.Unpriced = append(o.Unpriced, t.Symbol)
+			continue
+		}
+		entries = append(entries, e)
+		o.TotalVolume = o.TotalVolume.Add(e.QuoteVolume)
+	}
+	if o.Breadth.Decliners > 0 {
+		o.Breadth.Ratio = float64(o.Breadth.Advancers) / float64(o.Breadth.Decliners)
+	}
+
+	o.Gainers = top(entries, n, func(a, b MarketEntry) bool { return a.ChangePercent.GreaterThan(b.ChangePercent) },
+		func(e MarketEntry) bool { return e.ChangePercent.IsPositive() })
+	o.Losers = top(entries, n, func(a, b MarketEntry) bool { return a.ChangePercent.LessThan(b.ChangePercent) },
+		func(e MarketEntry) bool { return e.ChangePercent.IsNegative() })
+	o.VolumeLeader = top(entries, n, func(a, b MarketEntry) bool { return a.QuoteVolume.GreaterThan(b.QuoteVolume) }, nil)
+	o.MostVolatile = top(entries, n, func(a, b MarketEntry) bool { return a.RangePercent.GreaterThan(b.RangePercent) }, nil)
+	return o
+}
+
+// entryFor reports false when valuer cannot convert the symbol's volume.
+func entryFor(t types.TickerMessage, valuer Valuer) (MarketEntry, bool) {
+	e := MarketEntry{
+		Symbol:        t.Symbol,
+		Price:         t.Price,
+		ChangePercent: t.ChangePercent,
+		QuoteVolume:   t.QuoteVolume,
+		RangePercent:  decimal.Zero,
+	}
+	// Recordings made before quote volume was forwarded only have the base volume.
+	if e.QuoteVolume.IsZero() {
+		e.QuoteVolume = t.Volume.Mul(t.Price)
+	}
+	if valuer != nil {
+		_, quote, ok := exchange.SplitSymbol(t.Symbol)
+		if !ok {
+			return e, false
+		}
+		v, err := valuer(e.QuoteVolume, quote)
+		if err != nil {
+			return e, false
+		}
+		e.QuoteVolume = v
+	}
+	e.QuoteVolume = e.QuoteVolume.Round(2)
+	if t.Low.IsPositive() {
+		e.RangePercent = t.High.Sub(t.Low).Mul(decimal.NewFromInt(100)).Div(t.Low, 2)
+	}
+	return e, true
+}
+
+// top sorts a copy of entries with less and keeps the first n that pass keep.
+// Ties keep symbol order.
+func top(entries []MarketEntry, n int, less func(a, b MarketEntry) bool, keep func(MarketEntry) bool) []MarketEntry {
+	sorted := make([]MarketEntry, 0, len(entries))
+	for _, e := range entries {
+		if keep == nil || keep(e) {
+			sorted = append(sorted, e)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// Run pushes an overview of the top n every interval once tickers have arrived.
+func (s *Snapshot) Run(ctx context.Context, interval time.Duration, n int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mutex.RLock()
+			notify, empty := s.notify, len(s.tickers) == 0
+			s.mutex.RUnlock()
+			if notify == nil || empty {
+				continue
+			}
+			data, err := json.Marshal(s.Overview(n))
+			if err != nil {
+				continue
+			}
+			notify(data)
+		}
+	}
+}
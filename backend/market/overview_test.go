@@ -0,0 +1,60 @@
+package market
+
+import (
+	"cropto-dashboard/decimal"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func ticker(t *testing.T, s *Snapshot, symbol, price, change, quoteVolume string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"eventType":     "ticker",
+		"symbol":        symbol,
+		"price":         price,
+		"changePercent": change,
+		"quoteVolume":   quoteVolume,
+		"high":          price,
+		"low":           price,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.OnMessage(data)
+}
+
+func TestOverviewSkipsUnconvertibleVolume(t *testing.T) {
+	s := NewSnapshot()
+	ticker(t, s, "BTCUSDT", "60000", "2", "1000000")
+	ticker(t, s, "ETHBTC", "0.05", "-1", "500")
+	ticker(t, s, "FOOXYZ", "1", "5", "99999999")
+	s.SetValuer(func(amount decimal.Decimal, asset string) (decimal.Decimal, error) {
+		switch asset {
+		case "USDT":
+			return amount, nil
+		case "BTC":
+			return amount.Mul(decimal.NewFromInt(60000)), nil
+		}
+		return decimal.Zero, errors.New("no rate")
+	})
+
+	o := s.Overview(5)
+	if len(o.Unpriced) != 1 || o.Unpriced[0] != "FOOXYZ" {
+		t.Fatalf("unpriced = %v, want [FOOXYZ]", o.Unpriced)
+	}
+	if want := decimal.NewFromInt(31000000); !o.TotalVolume.Equal(want) {
+		t.Errorf("total = %s, want %s", o.TotalVolume, want)
+	}
+	if o.VolumeLeader[0].Symbol != "ETHBTC" {
+		t.Errorf("volume leader = %s, want ETHBTC", o.VolumeLeader[0].Symbol)
+	}
+	for _, e := range o.Gainers {
+		if e.Symbol == "FOOXYZ" {
+			t.Error("unpriced symbol listed among gainers")
+		}
+	}
+	if o.Breadth.Advancers != 2 || o.Breadth.Decliners != 1 {
+		t.Errorf("breadth = %+v, want 2 up and 1 down", o.Breadth)
+	}
+}
@@ -0,0 +1,52 @@
+package market
+
+import (
+	"cropto-dashboard/types"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Snapshot keeps the latest 24h ticker of every symbol seen on the stream.
+type Snapshot struct {
+	mutex   sync.RWMutex
+	tickers map[string]types.TickerMessage
+	valuer  Valuer
+	notify  func(message []byte)
+}
+
+func NewSnapshot() *Snapshot {
+	return &Snapshot{tickers: make(map[string]types.TickerMessage)}
+}
+
+func (s *Snapshot) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.EventType != "ticker" {
+		return
+	}
+
+	s.mutex.Lock()
+	s.tickers[strings.ToUpper(msg.Symbol)] = msg
+	s.mutex.Unlock()
+}
+
+func (s *Snapshot) Ticker(symbol string) (types.TickerMessage, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	t, ok := s.tickers[strings.ToUpper(symbol)]
+	return t, ok
+}
+
+// Tickers returns the latest ticker of every symbol, sorted by symbol.
+func (s *Snapshot) Tickers() []types.TickerMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tickers := make([]types.TickerMessage, 0, len(s.tickers))
+	for _, t := range s.tickers {
+		tickers = append(tickers, t)
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	return tickers
+}
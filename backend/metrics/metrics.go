@@ -0,0 +1,117 @@
+// Package metrics holds the Prometheus collectors served on /metrics. The
+// packages that own the counted events update them directly.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cropto"
+
+// Reasons a message was dropped.
+const (
+	DropIngestFull   = "ingest_channel_full"
+	DropBrokerFull   = "broker_channel_full"
+	DropSlowClient   = "slow_client"
+	DropSlowListener = "slow_listener"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_in_total",
+		Help:      "Stream messages received by the hub, by symbol and event type.",
+	}, []string{"symbol", "type"})
+
+	MessagesOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_out_total",
+		Help:      "Messages delivered to WebSocket clients and stream listeners, by symbol and event type.",
+	}, []string{"symbol", "type"})
+
+	ParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "parse_errors_total",
+		Help:      "Exchange messages that could not be normalized, by source.",
+	}, []string{"source"})
+
+	Dropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_messages_total",
+		Help:      "Messages dropped because a channel was full or a consumer too slow, by reason.",
+	}, []string{"reason"})
+
+	Reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exchange_reconnects_total",
+		Help:      "Exchange WebSocket reconnects and failed connection attempts, by source.",
+	}, []string{"source"})
+
+	BroadcastLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "broadcast_latency_seconds",
+		Help:      "Time from a market event's exchange timestamp to its hub broadcast.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "REST request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "binance_rest_duration_seconds",
+		Help:      "Binance REST call latency by endpoint and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesIn, MessagesOut, ParseErrors, Dropped, Reconnects,
+		BroadcastLatency, HTTPDuration, UpstreamDuration,
+	)
+
+	// Start the series alerts watch at zero instead of absent.
+	for _, source := range []string{"binance", "coinbase"} {
+		ParseErrors.WithLabelValues(source)
+		Reconnects.WithLabelValues(source)
+	}
+	for _, reason := range []string{DropIngestFull, DropBrokerFull, DropSlowClient, DropSlowListener} {
+		Dropped.WithLabelValues(reason)
+	}
+}
+
+// Gauge registers a gauge read from fn at scrape time.
+func Gauge(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// ObserveLatency records the broadcast latency of an event stamped at
+// timestamp (ms). Events without a timestamp are skipped.
+func ObserveLatency(timestamp int64) {
+	if timestamp <= 0 {
+		return
+	}
+	BroadcastLatency.Observe(max(0, time.Since(time.UnixMilli(timestamp)).Seconds()))
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,620 @@
+package paper
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/ids"
+	"cropto-dashboard/storage"
+	"cropto-dashboard/types"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultFeeRate = decimal.MustParse("0.001")
+
+// feePlaces is the precision fees are charged with.
+const feePlaces = 8
+
+// maxFills bounds the persisted fill history across all accounts.
+const maxFills = 10000
+
+// saveDelay batches the writes of a burst of fills.
+const saveDelay = time.Second
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("invalid account token")
+)
+
+type state struct {
+	Accounts map[string]*Account `json:"accounts"`
+	Orders   []*Order            `json:"orders"`
+	Fills    []Fill              `json:"fills"`
+}
+
+// Event is pushed to the account owner's WebSocket connection.
+type Event struct {
+	EventType string              `json:"eventType"`
+	AccountID string              `json:"accountId"`
+	Order     *Order              `json:"order,omitempty"`
+	Fill      *Fill               `json:"fill,omitempty"`
+	Balances  map[string]*Balance `json:"balances,omitempty"`
+}
+
+// Engine holds simulated accounts and matches their orders against live trades.
+type Engine struct {
+	FeeRate decimal.Decimal
+
+	mutex     sync.Mutex
+	state     state
+	open      map[string][]*Order // resting orders by symbol
+	lastPrice map[string]decimal.Decimal
+	writer    *storage.Writer
+	notify    func(accountID string, message []byte)
+}
+
+func NewEngine(store *storage.JSONFile, feeRate decimal.Decimal) (*Engine, error) {
+	e := &Engine{
+		FeeRate:   feeRate,
+		state:     state{Accounts: make(map[string]*Account)},
+		open:      make(map[string][]*Order),
+		lastPrice: make(map[string]decimal.Decimal),
+	}
+
+	if _, err := store.Load(&e.state); err != nil {
+		return nil, err
+	}
+	if e.state.Accounts == nil {
+		e.state.Accounts = make(map[string]*Account)
+	}
+	for _, o := range e.state.Orders {
+		if o.Status == StatusNew {
+			e.open[o.Symbol] = append(e.open[o.Symbol], o)
+		}
+	}
+	e.writer = storage.NewWriter(store, saveDelay, e.snapshot)
+	return e, nil
+}
+
+// Close writes pending changes to disk.
+func (e *Engine) Close() {
+	e.writer.Close()
+}
+
+// SetNotifier receives order, fill and balance events with the account they
+// belong to, so they can be routed to that account's subscribers only.
+func (e *Engine) SetNotifier(fn func(accountID string, message []byte)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.notify = fn
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAccount opens an account with starting balances and returns its secret token.
+func (e *Engine) CreateAccount(name string, balances map[string]decimal.Decimal) (*Account, string, error) {
+	token := ids.New(24)
+	acc := &Account{
+		ID:        ids.New(8),
+		Name:      name,
+		TokenHash: hashToken(token),
+		Balances:  make(map[string]*Balance),
+		CreatedAt: time.Now(),
+	}
+	for asset, amount := range balances {
+		if amount.IsNegative() {
+			return nil, "", fmt.Errorf("balance for %s cannot be negative", asset)
+		}
+		acc.Balances[strings.ToUpper(asset)] = &Balance{Free: amount}
+	}
+
+	e.mutex.Lock()
+	e.state.Accounts[acc.ID] = acc
+	e.writer.Mark()
+	e.mutex.Unlock()
+
+	return acc, token, nil
+}
+
+// Authenticate checks an account token in constant time.
+func (e *Engine) Authenticate(accountID, token string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	acc, ok := e.state.Accounts[accountID]
+	if !ok {
+		return ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(acc.TokenHash), []byte(hashToken(token))) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+type AccountView struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Balances   map[string]*Balance `json:"balances"`
+	OpenOrders []*Order            `json:"openOrders"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
+
+func (e *Engine) Account(accountID string) (*AccountView, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	acc, ok := e.state.Accounts[accountID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	view := &AccountView{
+		ID:         acc.ID,
+		Name:       acc.Name,
+		Balances:   copyBalances(acc.Balances),
+		OpenOrders: []*Order{},
+		CreatedAt:  acc.CreatedAt,
+	}
+	for _, o := range e.state.Orders {
+		if o.AccountID == accountID && o.Status == StatusNew {
+			copied := *o
+			view.OpenOrders = append(view.OpenOrders, &copied)
+		}
+	}
+	return view, nil
+}
+
+func (e *Engine) Orders(accountID string, openOnly bool) []*Order {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	orders := []*Order{}
+	for _, o := range e.state.Orders {
+		if o.AccountID != accountID || (openOnly && o.Status != StatusNew) {
+			continue
+		}
+		copied := *o
+		orders = append(orders, &copied)
+	}
+	return orders
+}
+
+func (e *Engine) Fills(accountID string) []Fill {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	fills := []Fill{}
+	for _, f := range e.state.Fills {
+		if f.AccountID == accountID {
+			fills = append(fills, f)
+		}
+	}
+	return fills
+}
+
+func copyBalances(in map[string]*Balance) map[string]*Balance {
+	out := make(map[string]*Balance, len(in))
+	for k, v := range in {
+		b := *v
+		out[k] = &b
+	}
+	return out
+}
+
+func (acc *Account) balance(asset string) *Balance {
+	b, ok := acc.Balances[asset]
+	if !ok {
+		b = &Balance{}
+		acc.Balances[asset] = b
+	}
+	return b
+}
+
+func (acc *Account) free(asset string) decimal.Decimal {
+	if b, ok := acc.Balances[asset]; ok {
+		return b.Free
+	}
+	return decimal.Zero
+}
+
+func validate(req *OrderRequest) error {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	req.Side = strings.ToUpper(req.Side)
+	req.Type = strings.ToUpper(req.Type)
+
+	if _, _, ok := exchange.SplitSymbol(req.Symbol); !ok {
+		return fmt.Errorf("unsupported symbol %q", req.Symbol)
+	}
+	if req.Side != SideBuy && req.Side != SideSell {
+		return fmt.Errorf("side must be BUY or SELL")
+	}
+	if !req.Quantity.IsPositive() {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	switch req.Type {
+	case TypeMarket:
+	case TypeLimit:
+		if !req.Price.IsPositive() {
+			return fmt.Errorf("LIMIT orders need a price")
+		}
+	case TypeStop:
+		if !req.StopPrice.IsPositive() {
+			return fmt.Errorf("STOP orders need a stopPrice")
+		}
+	case TypeOCO:
+		if !req.Price.IsPositive() || !req.StopPrice.IsPositive() {
+			return fmt.Errorf("OCO orders need a price and a stopPrice")
+		}
+	default:
+		return fmt.Errorf("type must be MARKET, LIMIT, STOP or OCO")
+	}
+	return nil
+}
+
+// PlaceOrder validates and reserves funds for an order. Market orders fill
+// immediately at the last traded price; the others rest until a trade crosses them.
+func (e *Engine) PlaceOrder(accountID string, req OrderRequest) ([]*Order, error) {
+	if err := validate(&req); err != nil {
+		return nil, err
+	}
+	base, quote, _ := exchange.SplitSymbol(req.Symbol)
+
+	e.mutex.Lock()
+	acc, ok := e.state.Accounts[accountID]
+	if !ok {
+		e.mutex.Unlock()
+		return nil, ErrNotFound
+	}
+
+	last, hasPrice := e.lastPrice[req.Symbol]
+	if req.Type == TypeMarket && !hasPrice {
+		e.mutex.Unlock()
+		return nil, fmt.Errorf("no trade seen yet for %s", req.Symbol)
+	}
+	if req.Type == TypeOCO && hasPrice {
+		if req.Side == SideSell && !(req.Price.GreaterThan(last) && req.StopPrice.LessThan(last)) {
+			e.mutex.Unlock()
+			return nil, fmt.Errorf("SELL OCO needs price above and stopPrice below the last price %s", last)
+		}
+		if req.Side == SideBuy && !(req.Price.LessThan(last) && req.StopPrice.GreaterThan(last)) {
+			e.mutex.Unlock()
+			return nil, fmt.Errorf("BUY OCO needs price below and stopPrice above the last price %s", last)
+		}
+	}
+
+	now := time.Now()
+	newOrder := func(orderType string, price, stop decimal.Decimal) *Order {
+		return &Order{
+			ID:        ids.New(8),
+			AccountID: accountID,
+			Symbol:    req.Symbol,
+			Side:      req.Side,
+			Type:      orderType,
+			Quantity:  req.Quantity,
+			Price:     price,
+			StopPrice: stop,
+			Status:    StatusNew,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+
+	var orders []*Order
+	switch req.Type {
+	case TypeOCO:
+		listID := ids.New(8)
+		limit := newOrder(TypeLimit, req.Price, decimal.Zero)
+		stop := newOrder(TypeStop, decimal.Zero, req.StopPrice)
+		limit.ListID, stop.ListID = listID, listID
+		orders = []*Order{limit, stop}
+	default:
+		orders = []*Order{newOrder(req.Type, req.Price, req.StopPrice)}
+	}
+
+	events := []Event{}
+
+	if req.Type == TypeMarket {
+		o := orders[0]
+		if err := e.fillLocked(acc, o, last, &events); err != nil {
+			e.mutex.Unlock()
+			return nil, err
+		}
+		e.state.Orders = append(e.state.Orders, o)
+	} else {
+		lockAsset, lockAmount := base, req.Quantity
+		if req.Side == SideBuy {
+			worst := decimal.Max(req.Price, req.StopPrice)
+			notional := req.Quantity.Mul(worst)
+			lockAsset, lockAmount = quote, notional.Add(e.fee(notional))
+		}
+
+		if free := acc.free(lockAsset); free.LessThan(lockAmount) {
+			e.mutex.Unlock()
+			return nil, fmt.Errorf("insufficient %s: need %s, free %s", lockAsset, lockAmount, free)
+		}
+		b := acc.balance(lockAsset)
+		b.Free = b.Free.Sub(lockAmount)
+		b.Locked = b.Locked.Add(lockAmount)
+
+		for _, o := range orders {
+			o.LockAsset, o.LockAmount = lockAsset, lockAmount
+			e.state.Orders = append(e.state.Orders, o)
+			e.open[o.Symbol] = append(e.open[o.Symbol], o)
+			events = append(events, orderEvent(o))
+		}
+		events = append(events, e.balanceEvent(acc))
+	}
+
+	e.writer.Mark()
+	result := make([]*Order, len(orders))
+	for i, o := range orders {
+		copied := *o
+		result[i] = &copied
+	}
+	e.mutex.Unlock()
+
+	e.publish(events)
+	return result, nil
+}
+
+// CancelOrder cancels a resting order, and its sibling when it is an OCO leg.
+func (e *Engine) CancelOrder(accountID, orderID string) (*Order, error) {
+	e.mutex.Lock()
+
+	var target *Order
+	for _, o := range e.state.Orders {
+		if o.ID == orderID && o.AccountID == accountID {
+			target = o
+			break
+		}
+	}
+	if target == nil {
+		e.mutex.Unlock()
+		return nil, ErrNotFound
+	}
+	if target.Status != StatusNew {
+		e.mutex.Unlock()
+		return nil, fmt.Errorf("order is already %s", target.Status)
+	}
+
+	acc := e.state.Accounts[accountID]
+	events := []Event{}
+	for _, o := range e.listLocked(target) {
+		e.closeLocked(acc, o, StatusCanceled, "canceled by user", &events)
+	}
+	events = append(events, e.balanceEvent(acc))
+
+	e.writer.Mark()
+	copied := *target
+	e.mutex.Unlock()
+
+	e.publish(events)
+	return &copied, nil
+}
+
+// listLocked returns the order and, for OCO legs, its sibling.
+func (e *Engine) listLocked(o *Order) []*Order {
+	if o.ListID == "" {
+		return []*Order{o}
+	}
+	list := []*Order{}
+	for _, other := range e.open[o.Symbol] {
+		if other.ListID == o.ListID {
+			list = append(list, other)
+		}
+	}
+	return list
+}
+
+// closeLocked takes an order off the book, releasing its lock once per OCO list.
+func (e *Engine) closeLocked(acc *Account, o *Order, status, reason string, events *[]Event) {
+	if o.LockAmount.IsPositive() {
+		b := acc.balance(o.LockAsset)
+		b.Locked = b.Locked.Sub(o.LockAmount)
+		b.Free = b.Free.Add(o.LockAmount)
+		e.clearListLock(o)
+	}
+
+	o.Status = status
+	o.Reason = reason
+	o.UpdatedAt = time.Now()
+	e.removeOpenLocked(o)
+	*events = append(*events, orderEvent(o))
+}
+
+func (e *Engine) clearListLock(o *Order) {
+	for _, other := range e.listLocked(o) {
+		other.LockAmount = decimal.Zero
+	}
+	o.LockAmount = decimal.Zero
+}
+
+func (e *Engine) removeOpenLocked(o *Order) {
+	open := e.open[o.Symbol]
+	for i, other := range open {
+		if other == o {
+			e.open[o.Symbol] = append(open[:i], open[i+1:]...)
+			break
+		}
+	}
+	if len(e.open[o.Symbol]) == 0 {
+		delete(e.open, o.Symbol)
+	}
+}
+
+// fee is the commission on a notional, rounded to feePlaces.
+func (e *Engine) fee(notional decimal.Decimal) decimal.Decimal {
+	return notional.Mul(e.FeeRate).Round(feePlaces)
+}
+
+// fillLocked settles an order completely at price.
+func (e *Engine) fillLocked(acc *Account, o *Order, price decimal.Decimal, events *[]Event) error {
+	base, quote, _ := exchange.SplitSymbol(o.Symbol)
+	notional := o.Quantity.Mul(price)
+	fee := e.fee(notional)
+
+	if o.Side == SideBuy {
+		cost := notional.Add(fee)
+		if acc.free(quote).Add(o.LockAmount).LessThan(cost) {
+			return fmt.Errorf("insufficient %s: need %s", quote, cost)
+		}
+		q := acc.balance(quote)
+		q.Locked = q.Locked.Sub(o.LockAmount)
+		q.Free = q.Free.Add(o.LockAmount).Sub(cost)
+		b := acc.balance(base)
+		b.Free = b.Free.Add(o.Quantity)
+	} else {
+		if acc.free(base).Add(o.LockAmount).LessThan(o.Quantity) {
+			return fmt.Errorf("insufficient %s: need %s", base, o.Quantity)
+		}
+		b := acc.balance(base)
+		b.Locked = b.Locked.Sub(o.LockAmount)
+		b.Free = b.Free.Add(o.LockAmount).Sub(o.Quantity)
+		q := acc.balance(quote)
+		q.Free = q.Free.Add(notional).Sub(fee)
+	}
+
+	// The lock was consumed by this fill, the OCO sibling must not release it again.
+	e.clearListLock(o)
+
+	o.Status = StatusFilled
+	o.FilledQty = o.Quantity
+	o.AvgPrice = price
+	o.Fee = fee
+	o.UpdatedAt = time.Now()
+
+	fill := Fill{
+		OrderID:   o.ID,
+		AccountID: o.AccountID,
+		Symbol:    o.Symbol,
+		Side:      o.Side,
+		Price:     price,
+		Quantity:  o.Quantity,
+		Fee:       fee,
+		FeeAsset:  quote,
+		Time:      o.UpdatedAt,
+	}
+	e.state.Fills = append(e.state.Fills, fill)
+	if len(e.state.Fills) > maxFills {
+		e.state.Fills = e.state.Fills[len(e.state.Fills)-maxFills:]
+	}
+
+	*events = append(*events, orderEvent(o), Event{EventType: "paper.fill", AccountID: o.AccountID, Fill: &fill}, e.balanceEvent(acc))
+	return nil
+}
+
+// OnMessage matches resting orders against a live trade from the hub stream.
+// Orders fill completely at their limit price, or at the trade price for stops.
+func (e *Engine) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Backfilled {
+		return
+	}
+	if msg.EventType != "trade" && msg.EventType != "ticker" {
+		return
+	}
+	price := msg.Price
+	if !price.IsPositive() {
+		return
+	}
+	symbol := strings.ToUpper(msg.Symbol)
+
+	e.mutex.Lock()
+	e.lastPrice[symbol] = price
+
+	// Tickers only seed the market order price, resting orders match on trades.
+	open := append([]*Order(nil), e.open[symbol]...)
+	if msg.EventType != "trade" || len(open) == 0 {
+		e.mutex.Unlock()
+		return
+	}
+
+	events := []Event{}
+	for _, o := range open {
+		if o.Status != StatusNew {
+			continue
+		}
+
+		fillAt, triggered := decimal.Zero, false
+		switch o.Type {
+		case TypeLimit:
+			if (o.Side == SideBuy && price.Cmp(o.Price) <= 0) || (o.Side == SideSell && price.Cmp(o.Price) >= 0) {
+				fillAt, triggered = o.Price, true
+			}
+		case TypeStop:
+			if (o.Side == SideBuy && price.Cmp(o.StopPrice) >= 0) || (o.Side == SideSell && price.Cmp(o.StopPrice) <= 0) {
+				fillAt, triggered = price, true
+			}
+		}
+		if !triggered {
+			continue
+		}
+
+		acc := e.state.Accounts[o.AccountID]
+		siblings := e.listLocked(o)
+		if err := e.fillLocked(acc, o, fillAt, &events); err != nil {
+			for _, s := range siblings {
+				e.closeLocked(acc, s, StatusRejected, err.Error(), &events)
+			}
+			continue
+		}
+		e.removeOpenLocked(o)
+
+		for _, s := range siblings {
+			if s != o && s.Status == StatusNew {
+				e.closeLocked(acc, s, StatusCanceled, "other OCO leg filled", &events)
+			}
+		}
+	}
+
+	if len(events) > 0 {
+		e.writer.Mark()
+	}
+	e.mutex.Unlock()
+
+	e.publish(events)
+}
+
+// snapshot encodes the state for the background writer.
+func (e *Engine) snapshot() ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return storage.Encode(&e.state)
+}
+
+func orderEvent(o *Order) Event {
+	copied := *o
+	return Event{EventType: "paper.order", AccountID: o.AccountID, Order: &copied}
+}
+
+func (e *Engine) balanceEvent(acc *Account) Event {
+	return Event{EventType: "paper.balance", AccountID: acc.ID, Balances: copyBalances(acc.Balances)}
+}
+
+func (e *Engine) publish(events []Event) {
+	e.mutex.Lock()
+	notify := e.notify
+	e.mutex.Unlock()
+
+	if notify == nil {
+		return
+	}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		notify(ev.AccountID, data)
+	}
+}
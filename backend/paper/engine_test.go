@@ -0,0 +1,46 @@
+package paper
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/storage"
+	"path/filepath"
+	"testing"
+)
+
+func TestFillIsSavedInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paper.json")
+	e, err := NewEngine(storage.NewJSONFile(path), DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc, _, err := e.CreateAccount("test", map[string]decimal.Decimal{"USDT": decimal.MustParse("1000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.PlaceOrder(acc.ID, OrderRequest{
+		Symbol:   "BTCUSDT",
+		Side:     SideBuy,
+		Type:     TypeLimit,
+		Quantity: decimal.MustParse("0.01"),
+		Price:    decimal.MustParse("50000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.OnMessage([]byte(`{"symbol":"BTCUSDT","price":"49990","eventType":"trade","timestamp":1}`))
+	e.Close()
+
+	reloaded, err := NewEngine(storage.NewJSONFile(path), DefaultFeeRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	fills := reloaded.Fills(acc.ID)
+	if len(fills) != 1 {
+		t.Fatalf("got %d saved fills, want 1", len(fills))
+	}
+	if orders := reloaded.Orders(acc.ID, true); len(orders) != 0 {
+		t.Errorf("got %d open orders after the fill, want 0", len(orders))
+	}
+}
@@ -0,0 +1,80 @@
+package paper
+
+import (
+	"cropto-dashboard/decimal"
+	"time"
+)
+
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+
+	TypeMarket = "MARKET"
+	TypeLimit  = "LIMIT"
+	TypeStop   = "STOP"
+	TypeOCO    = "OCO"
+
+	StatusNew      = "NEW"
+	StatusFilled   = "FILLED"
+	StatusCanceled = "CANCELED"
+	StatusRejected = "REJECTED"
+)
+
+type Balance struct {
+	Free   decimal.Decimal `json:"free"`
+	Locked decimal.Decimal `json:"locked"`
+}
+
+type Account struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	TokenHash string              `json:"tokenHash"`
+	Balances  map[string]*Balance `json:"balances"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+// Order is a single resting or completed order. Both legs of an OCO share a ListID.
+type Order struct {
+	ID        string          `json:"id"`
+	AccountID string          `json:"accountId"`
+	ListID    string          `json:"listId,omitempty"`
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`
+	Type      string          `json:"type"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Price     decimal.Decimal `json:"price,omitempty"`
+	StopPrice decimal.Decimal `json:"stopPrice,omitempty"`
+	Status    string          `json:"status"`
+	FilledQty decimal.Decimal `json:"filledQty"`
+	AvgPrice  decimal.Decimal `json:"avgPrice"`
+	Fee       decimal.Decimal `json:"fee"`
+	Reason    string          `json:"reason,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	// Funds reserved while the order rests. OCO legs carry the same lock, released once.
+	LockAsset  string          `json:"lockAsset,omitempty"`
+	LockAmount decimal.Decimal `json:"lockAmount,omitempty"`
+}
+
+type Fill struct {
+	OrderID   string          `json:"orderId"`
+	AccountID string          `json:"accountId"`
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Fee       decimal.Decimal `json:"fee"`
+	FeeAsset  string          `json:"feeAsset"`
+	Time      time.Time       `json:"time"`
+}
+
+// OrderRequest is what clients submit. For OCO, Price is the limit leg and
+// StopPrice the stop leg.
+type OrderRequest struct {
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`
+	Type      string          `json:"type"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Price     decimal.Decimal `json:"price"`
+	StopPrice decimal.Decimal `json:"stopPrice"`
+}
@@ -0,0 +1,150 @@
+package portfolio
+
+import (
+	"cropto-dashboard/decimal"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// costPlaces is the precision of per unit costs, which need a division.
+const costPlaces = 18
+
+// lot is an open purchase. It keeps its total cost rather than a unit cost so
+// closing a whole lot gives back exactly what was paid.
+type lot struct {
+	qty  decimal.Decimal
+	cost decimal.Decimal
+}
+
+// holding is the open lots of one asset plus what has been realized so far.
+type holding struct {
+	lots     []lot
+	realized decimal.Decimal
+}
+
+func (h *holding) quantity() decimal.Decimal {
+	total := decimal.Zero
+	for _, l := range h.lots {
+		total = total.Add(l.qty)
+	}
+	return total
+}
+
+func (h *holding) costBasis() decimal.Decimal {
+	total := decimal.Zero
+	for _, l := range h.lots {
+		total = total.Add(l.cost)
+	}
+	return total
+}
+
+func (h *holding) add(qty, cost decimal.Decimal, method string) {
+	if method == MethodAverage && len(h.lots) > 0 {
+		h.lots[0] = lot{qty: h.lots[0].qty.Add(qty), cost: h.lots[0].cost.Add(cost)}
+		return
+	}
+	h.lots = append(h.lots, lot{qty: qty, cost: cost})
+}
+
+// remove takes qty out of the open lots in method order and returns its cost.
+func (h *holding) remove(qty decimal.Decimal, method string) (decimal.Decimal, error) {
+	if held := h.quantity(); qty.GreaterThan(held) {
+		return decimal.Zero, fmt.Errorf("only %s held", held)
+	}
+
+	cost := decimal.Zero
+	for qty.IsPositive() && len(h.lots) > 0 {
+		i := 0
+		if method == MethodLIFO {
+			i = len(h.lots) - 1
+		}
+		l := &h.lots[i]
+
+		if !qty.LessThan(l.qty) {
+			cost = cost.Add(l.cost)
+			qty = qty.Sub(l.qty)
+			h.lots = append(h.lots[:i], h.lots[i+1:]...)
+			continue
+		}
+
+		part := l.cost.Mul(qty).Div(l.qty, costPlaces)
+		cost = cost.Add(part)
+		l.cost = l.cost.Sub(part)
+		l.qty = l.qty.Sub(qty)
+		qty = decimal.Zero
+	}
+	return cost, nil
+}
+
+func validMethod(method string) bool {
+	return method == MethodFIFO || method == MethodLIFO || method == MethodAverage
+}
+
+func validateTransaction(tx *Transaction) error {
+	tx.Type = strings.ToLower(strings.TrimSpace(tx.Type))
+	tx.Asset = strings.ToUpper(strings.TrimSpace(tx.Asset))
+
+	switch tx.Type {
+	case TxBuy, TxSell, TxTransferIn, TxTransferOut, TxFee:
+	default:
+		return fmt.Errorf("type must be one of buy, sell, transfer_in, transfer_out, fee")
+	}
+	if tx.Asset == "" {
+		return fmt.Errorf("asset is required")
+	}
+	if !tx.Quantity.IsPositive() {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if tx.Price.IsNegative() || tx.Fee.IsNegative() {
+		return fmt.Errorf("price and fee cannot be negative")
+	}
+	if (tx.Type == TxBuy || tx.Type == TxSell) && tx.Price.IsZero() {
+		return fmt.Errorf("%s needs a price", tx.Type)
+	}
+	if tx.Time.IsZero() {
+		tx.Time = time.Now()
+	}
+	return nil
+}
+
+// replay rebuilds every holding from the ledger in time order.
+//
+//   - buy adds a lot costing quantity × price plus the fee
+//   - sell realizes proceeds minus fee minus the cost of the lots it consumes
+//   - transfer_in adds a lot at price (the cost basis brought in, may be 0)
+//   - transfer_out removes lots without realizing anything
+//   - fee removes lots and realizes their cost as a loss
+func replay(txs []Transaction, method string) (map[string]*holding, error) {
+	ordered := append([]Transaction(nil), txs...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Time.Before(ordered[j].Time) })
+
+	holdings := make(map[string]*holding)
+	for _, tx := range ordered {
+		h, ok := holdings[tx.Asset]
+		if !ok {
+			h = &holding{}
+			holdings[tx.Asset] = h
+		}
+
+		switch tx.Type {
+		case TxBuy:
+			h.add(tx.Quantity, tx.Quantity.Mul(tx.Price).Add(tx.Fee), method)
+		case TxTransferIn:
+			h.add(tx.Quantity, tx.Quantity.Mul(tx.Price), method)
+		case TxSell, TxTransferOut, TxFee:
+			cost, err := h.remove(tx.Quantity, method)
+			if err != nil {
+				return nil, fmt.Errorf("%s of %s %s at %s: %w", tx.Type, tx.Quantity, tx.Asset, tx.Time.Format(time.RFC3339), err)
+			}
+			switch tx.Type {
+			case TxSell:
+				h.realized = h.realized.Add(tx.Quantity.Mul(tx.Price)).Sub(tx.Fee).Sub(cost)
+			case TxFee:
+				h.realized = h.realized.Sub(cost)
+			}
+		}
+	}
+	return holdings, nil
+}
@@ -0,0 +1,444 @@
+package portfolio
+
+import (
+	"context"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/ids"
+	"cropto-dashboard/storage"
+	"cropto-dashboard/types"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Currency is what portfolios are valued in.
+const Currency = "USDT"
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("invalid portfolio token")
+)
+
+// saveDelay batches the writes of quick successive edits.
+const saveDelay = time.Second
+
+// stablecoins are valued at 1 until a market price for them is seen.
+var stablecoins = map[string]bool{"USDT": true, "USDC": true, "FDUSD": true, "BUSD": true, "TUSD": true}
+
+// Tracker keeps the portfolios and the latest USDT price of every asset seen
+// on the stream.
+type Tracker struct {
+	mutex      sync.Mutex
+	portfolios map[string]*Portfolio
+	prices     map[string]decimal.Decimal
+	lastPushed map[string]decimal.Decimal
+	// holdings caches each portfolio's replayed ledger under its own method
+	// until a transaction or the method changes.
+	holdings map[string]map[string]*holding
+	watchers map[string]int
+	writer   *storage.Writer
+	notify   func(portfolioID string, message []byte)
+}
+
+func NewTracker(store *storage.JSONFile) (*Tracker, error) {
+	t := &Tracker{
+		portfolios: make(map[string]*Portfolio),
+		prices:     make(map[string]decimal.Decimal),
+		lastPushed: make(map[string]decimal.Decimal),
+		holdings:   make(map[string]map[string]*holding),
+		watchers:   make(map[string]int),
+	}
+	if _, err := store.Load(&t.portfolios); err != nil {
+		return nil, err
+	}
+	if t.portfolios == nil {
+		t.portfolios = make(map[string]*Portfolio)
+	}
+	t.writer = storage.NewWriter(store, saveDelay, t.snapshot)
+	return t, nil
+}
+
+// Close writes pending changes to disk.
+func (t *Tracker) Close() {
+	t.writer.Close()
+}
+
+// SetNotifier receives a portfolio's valuation whenever its total changes.
+// Only watched portfolios are valued for it.
+func (t *Tracker) SetNotifier(fn func(portfolioID string, message []byte)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.notify = fn
+}
+
+// Watch starts pushing a portfolio to the notifier, once per subscriber.
+func (t *Tracker) Watch(id string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.watchers[id]++
+}
+
+// Unwatch undoes one Watch. The last one stops the pushes.
+func (t *Tracker) Unwatch(id string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.watchers[id]--; t.watchers[id] <= 0 {
+		delete(t.watchers, id)
+		delete(t.lastPushed, id)
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create adds an empty portfolio and returns its secret token.
+func (t *Tracker) Create(name, method string) (*Portfolio, string, error) {
+	if method == "" {
+		method = MethodFIFO
+	}
+	method = strings.ToLower(method)
+	if !validMethod(method) {
+		return nil, "", fmt.Errorf("method must be fifo, lifo or average")
+	}
+
+	token := ids.New(24)
+	p := &Portfolio{
+		ID:           ids.New(8),
+		Name:         name,
+		Method:       method,
+		TokenHash:    hashToken(token),
+		Transactions: []Transaction{},
+		CreatedAt:    time.Now(),
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.portfolios[p.ID] = p
+	t.writer.Mark()
+	return p, token, nil
+}
+
+func (t *Tracker) Authenticate(id, token string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(p.TokenHash), []byte(hashToken(token))) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// Update renames a portfolio or changes its default cost basis method.
+func (t *Tracker) Update(id, name, method string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if method != "" {
+		method = strings.ToLower(method)
+		if !validMethod(method) {
+			return fmt.Errorf("method must be fifo, lifo or average")
+		}
+		if method != p.Method {
+			delete(t.holdings, id)
+		}
+		p.Method = method
+	}
+	if name != "" {
+		p.Name = name
+	}
+	t.writer.Mark()
+	return nil
+}
+
+func (t *Tracker) Delete(id string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.portfolios[id]; !ok {
+		return ErrNotFound
+	}
+	delete(t.portfolios, id)
+	delete(t.lastPushed, id)
+	delete(t.holdings, id)
+	t.writer.Mark()
+	return nil
+}
+
+func (t *Tracker) Transactions(id string) ([]Transaction, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	txs := append([]Transaction(nil), p.Transactions...)
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Time.Before(txs[j].Time) })
+	return txs, nil
+}
+
+// AddTransaction records a ledger entry, rejecting it when it would sell or
+// move more than is held at that point in time.
+func (t *Tracker) AddTransaction(id string, tx Transaction) (Transaction, error) {
+	if err := validateTransaction(&tx); err != nil {
+		return tx, err
+	}
+	tx.ID = ids.New(8)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return tx, ErrNotFound
+	}
+	txs := append(append([]Transaction(nil), p.Transactions...), tx)
+	holdings, err := replay(txs, p.Method)
+	if err != nil {
+		return tx, err
+	}
+	p.Transactions = txs
+	t.holdings[id] = holdings
+	t.writer.Mark()
+	return tx, nil
+}
+
+func (t *Tracker) DeleteTransaction(id, txID string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	txs := make([]Transaction, 0, len(p.Transactions))
+	for _, tx := range p.Transactions {
+		if tx.ID != txID {
+			txs = append(txs, tx)
+		}
+	}
+	if len(txs) == len(p.Transactions) {
+		return ErrNotFound
+	}
+	holdings, err := replay(txs, p.Method)
+	if err != nil {
+		return fmt.Errorf("later transactions depend on it: %w", err)
+	}
+	p.Transactions = txs
+	t.holdings[id] = holdings
+	t.writer.Mark()
+	return nil
+}
+
+// Value computes the portfolio at current prices. An empty method uses the
+// portfolio's own.
+func (t *Tracker) Value(id, method string) (*Valuation, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.portfolios[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if method == "" {
+		method = p.Method
+	}
+	method = strings.ToLower(method)
+	if !validMethod(method) {
+		return nil, fmt.Errorf("method must be fifo, lifo or average")
+	}
+	return t.valueLocked(p, method)
+}
+
+func (t *Tracker) priceLocked(asset string) (decimal.Decimal, bool) {
+	if price, ok := t.prices[asset]; ok {
+		return price, true
+	}
+	if stablecoins[asset] {
+		return decimal.One, true
+	}
+	return decimal.Zero, false
+}
+
+// holdingsLocked replays the ledger, or reuses the last replay under the
+// portfolio's own method. The result must not be modified.
+func (t *Tracker) holdingsLocked(p *Portfolio, method string) (map[string]*holding, error) {
+	if method != p.Method {
+		return replay(p.Transactions, method)
+	}
+	if holdings, ok := t.holdings[p.ID]; ok {
+		return holdings, nil
+	}
+	holdings, err := replay(p.Transactions, method)
+	if err != nil {
+		return nil, err
+	}
+	t.holdings[p.ID] = holdings
+	return holdings, nil
+}
+
+func (t *Tracker) valueLocked(p *Portfolio, method string) (*Valuation, error) {
+	holdings, err := t.holdingsLocked(p, method)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Valuation{
+		EventType:   "portfolio",
+		PortfolioID: p.ID,
+		Name:        p.Name,
+		Method:      method,
+		Currency:    Currency,
+		Positions:   []Position{},
+		Timestamp:   time.Now().UnixMilli(),
+	}
+
+	hundred := decimal.NewFromInt(100)
+	for asset, h := range holdings {
+		pos := Position{
+			Asset:       asset,
+			Quantity:    h.quantity(),
+			CostBasis:   h.costBasis(),
+			RealizedPnL: h.realized,
+		}
+		if pos.Quantity.IsPositive() {
+			pos.AvgCost = pos.CostBasis.Div(pos.Quantity, MoneyPlaces)
+		}
+		if price, ok := t.priceLocked(asset); ok {
+			pos.Price = price
+			pos.Value = pos.Quantity.Mul(price)
+			pos.UnrealizedPnL = pos.Value.Sub(pos.CostBasis)
+			if pos.CostBasis.IsPositive() {
+				pos.UnrealizedPct = pos.UnrealizedPnL.Mul(hundred).Div(pos.CostBasis, 4)
+			}
+			pos.Priced = true
+		}
+
+		v.TotalValue = v.TotalValue.Add(pos.Value)
+		v.TotalCost = v.TotalCost.Add(pos.CostBasis)
+		v.UnrealizedPnL = v.UnrealizedPnL.Add(pos.UnrealizedPnL)
+		v.RealizedPnL = v.RealizedPnL.Add(pos.RealizedPnL)
+		v.Positions = append(v.Positions, pos)
+	}
+	v.TotalPnL = v.UnrealizedPnL.Add(v.RealizedPnL)
+
+	for i := range v.Positions {
+		p := &v.Positions[i]
+		if v.TotalValue.IsPositive() {
+			p.Allocation = p.Value.Mul(hundred).Div(v.TotalValue, 4)
+		}
+		p.CostBasis = p.CostBasis.Round(MoneyPlaces)
+		p.Value = p.Value.Round(MoneyPlaces)
+		p.UnrealizedPnL = p.UnrealizedPnL.Round(MoneyPlaces)
+		p.RealizedPnL = p.RealizedPnL.Round(MoneyPlaces)
+	}
+	v.TotalValue = v.TotalValue.Round(MoneyPlaces)
+	v.TotalCost = v.TotalCost.Round(MoneyPlaces)
+	v.UnrealizedPnL = v.UnrealizedPnL.Round(MoneyPlaces)
+	v.RealizedPnL = v.RealizedPnL.Round(MoneyPlaces)
+	v.TotalPnL = v.TotalPnL.Round(MoneyPlaces)
+
+	sort.Slice(v.Positions, func(i, j int) bool {
+		if c := v.Positions[i].Value.Cmp(v.Positions[j].Value); c != 0 {
+			return c > 0
+		}
+		return v.Positions[i].Asset < v.Positions[j].Asset
+	})
+	return v, nil
+}
+
+// OnMessage keeps the latest USDT price per asset from the hub stream.
+func (t *Tracker) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Backfilled {
+		return
+	}
+	if msg.EventType != "trade" && msg.EventType != "ticker" {
+		return
+	}
+	base, quote, ok := exchange.SplitSymbol(msg.Symbol)
+	if !ok || quote != Currency {
+		return
+	}
+	if !msg.Price.IsPositive() {
+		return
+	}
+
+	t.mutex.Lock()
+	t.prices[base] = msg.Price
+	t.mutex.Unlock()
+}
+
+// Run pushes every watched portfolio whose value changed since the last push.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.push()
+		}
+	}
+}
+
+func (t *Tracker) push() {
+	t.mutex.Lock()
+	notify := t.notify
+	updates := []*Valuation{}
+	for id := range t.watchers {
+		p, ok := t.portfolios[id]
+		if !ok {
+			continue
+		}
+		v, err := t.valueLocked(p, p.Method)
+		if err != nil {
+			continue
+		}
+		if last, ok := t.lastPushed[id]; ok && last.Equal(v.TotalValue) {
+			continue
+		}
+		t.lastPushed[id] = v.TotalValue
+		updates = append(updates, v)
+	}
+	t.mutex.Unlock()
+
+	if notify == nil {
+		return
+	}
+	for _, v := range updates {
+		data, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		notify(v.PortfolioID, data)
+	}
+}
+
+// snapshot encodes the portfolios for the background writer.
+func (t *Tracker) snapshot() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return storage.Encode(t.portfolios)
+}
@@ -0,0 +1,134 @@
+package portfolio
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/storage"
+	"path/filepath"
+	"testing"
+)
+
+func TestTransactionsAreSavedOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portfolios.json")
+	tracker, err := NewTracker(storage.NewJSONFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := tracker.Create("test", MethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tracker.AddTransaction(p.ID, Transaction{
+		Type:     TxBuy,
+		Asset:    "BTC",
+		Quantity: decimal.MustParse("0.5"),
+		Price:    decimal.MustParse("40000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Close()
+
+	reloaded, err := NewTracker(storage.NewJSONFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	txs, err := reloaded.Transactions(p.ID)
+	if err != nil || len(txs) != 1 {
+		t.Fatalf("got %d saved transactions (%v), want 1", len(txs), err)
+	}
+}
+
+func TestPushOnlyWatchedPortfolios(t *testing.T) {
+	tracker, err := NewTracker(storage.NewJSONFile(filepath.Join(t.TempDir(), "portfolios.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Close()
+	p, _, err := tracker.Create("test", MethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.AddTransaction(p.ID, Transaction{Type: TxBuy, Asset: "BTC", Quantity: decimal.One, Price: decimal.MustParse("100")}); err != nil {
+		t.Fatal(err)
+	}
+	pushed := 0
+	tracker.SetNotifier(func(string, []byte) { pushed++ })
+	tracker.OnMessage([]byte(`{"symbol":"BTCUSDT","price":"150","eventType":"trade"}`))
+
+	tracker.push()
+	if pushed != 0 {
+		t.Fatalf("pushed %d valuations without a subscriber", pushed)
+	}
+	tracker.Watch(p.ID)
+	tracker.Watch(p.ID)
+	tracker.push()
+	tracker.push()
+	if pushed != 1 {
+		t.Fatalf("pushed %d valuations, want one until the value changes", pushed)
+	}
+
+	tracker.Unwatch(p.ID)
+	tracker.OnMessage([]byte(`{"symbol":"BTCUSDT","price":"160","eventType":"trade"}`))
+	tracker.push()
+	if pushed != 2 {
+		t.Fatalf("pushed %d valuations, the second subscriber should still get updates", pushed)
+	}
+	tracker.Unwatch(p.ID)
+	tracker.OnMessage([]byte(`{"symbol":"BTCUSDT","price":"170","eventType":"trade"}`))
+	tracker.push()
+	if pushed != 2 || len(tracker.watchers) != 0 || len(tracker.lastPushed) != 0 {
+		t.Fatalf("pushed %d valuations after the last subscriber left (watchers %v)", pushed, tracker.watchers)
+	}
+}
+
+func TestCachedHoldingsFollowTheLedger(t *testing.T) {
+	tracker, err := NewTracker(storage.NewJSONFile(filepath.Join(t.TempDir(), "portfolios.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Close()
+	p, _, err := tracker.Create("test", MethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range []string{"100", "200"} {
+		if _, err := tracker.AddTransaction(p.ID, Transaction{Type: TxBuy, Asset: "BTC", Quantity: decimal.One, Price: decimal.MustParse(price)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	realized := func(method string) string {
+		t.Helper()
+		v, err := tracker.Value(p.ID, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.RealizedPnL.String()
+	}
+	if got := realized(""); got != "0.00000000" {
+		t.Fatalf("realized %s before any sale", got)
+	}
+
+	sell, err := tracker.AddTransaction(p.ID, Transaction{Type: TxSell, Asset: "BTC", Quantity: decimal.One, Price: decimal.MustParse("300")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := realized(""); got != "200.00000000" {
+		t.Errorf("fifo realized %s, want 200 from the sale", got)
+	}
+	if got := realized(MethodLIFO); got != "100.00000000" {
+		t.Errorf("lifo realized %s, want 100", got)
+	}
+	if err := tracker.Update(p.ID, "", MethodLIFO); err != nil {
+		t.Fatal(err)
+	}
+	if got := realized(""); got != "100.00000000" {
+		t.Errorf("realized %s after switching to lifo, want 100", got)
+	}
+	if err := tracker.DeleteTransaction(p.ID, sell.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := realized(""); got != "0.00000000" {
+		t.Errorf("realized %s after deleting the sale", got)
+	}
+}
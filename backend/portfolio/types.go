@@ -0,0 +1,98 @@
+package portfolio
+
+import (
+	"cropto-dashboard/decimal"
+	"time"
+)
+
+const (
+	TxBuy         = "buy"
+	TxSell        = "sell"
+	TxTransferIn  = "transfer_in"
+	TxTransferOut = "transfer_out"
+	TxFee         = "fee"
+
+	MethodFIFO    = "fifo"
+	MethodLIFO    = "lifo"
+	MethodAverage = "average"
+)
+
+// MoneyPlaces is the precision amounts are reported with. The ledger itself
+// is exact and only rounded for output.
+const MoneyPlaces = 8
+
+// Transaction is one ledger entry. Price and Fee are in the valuation currency
+// (USDT); for fee entries Quantity is the amount of Asset paid.
+type Transaction struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Asset    string          `json:"asset"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+	Fee      decimal.Decimal `json:"fee"`
+	Time     time.Time       `json:"time"`
+	Note     string          `json:"note,omitempty"`
+}
+
+type Portfolio struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Method       string        `json:"method"`
+	TokenHash    string        `json:"tokenHash"`
+	Transactions []Transaction `json:"transactions"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+type Position struct {
+	Asset         string          `json:"asset"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	CostBasis     decimal.Decimal `json:"costBasis"`
+	AvgCost       decimal.Decimal `json:"avgCost"`
+	Price         decimal.Decimal `json:"price"`
+	Value         decimal.Decimal `json:"value"`
+	UnrealizedPnL decimal.Decimal `json:"unrealizedPnl"`
+	UnrealizedPct decimal.Decimal `json:"unrealizedPct"`
+	RealizedPnL   decimal.Decimal `json:"realizedPnl"`
+	Allocation    decimal.Decimal `json:"allocation"`
+	// Priced is false while no price has been seen for the asset.
+	Priced bool `json:"priced"`
+}
+
+type Valuation struct {
+	EventType     string          `json:"eventType"`
+	PortfolioID   string          `json:"portfolioId"`
+	Name          string          `json:"name"`
+	Method        string          `json:"method"`
+	Currency      string          `json:"currency"`
+	TotalValue    decimal.Decimal `json:"totalValue"`
+	TotalCost     decimal.Decimal `json:"totalCost"`
+	UnrealizedPnL decimal.Decimal `json:"unrealizedPnl"`
+	RealizedPnL   decimal.Decimal `json:"realizedPnl"`
+	TotalPnL      decimal.Decimal `json:"totalPnl"`
+	Positions     []Position      `json:"positions"`
+	Timestamp     int64           `json:"timestamp"`
+}
+
+// Convert re-expresses every amount in another currency, rate being units of
+// currency per unit of the current one. Quantities and percentages are unchanged.
+func (v *Valuation) Convert(rate decimal.Decimal, currency string) {
+	convert := func(d *decimal.Decimal) {
+		*d = d.Mul(rate).Round(MoneyPlaces)
+	}
+
+	v.Currency = currency
+	convert(&v.TotalValue)
+	convert(&v.TotalCost)
+	convert(&v.UnrealizedPnL)
+	convert(&v.RealizedPnL)
+	convert(&v.TotalPnL)
+	for i := range v.Positions {
+		p := &v.Positions[i]
+		convert(&p.CostBasis)
+		convert(&p.AvgCost)
+		convert(&p.Price)
+		convert(&p.Value)
+		convert(&p.UnrealizedPnL)
+		convert(&p.RealizedPnL)
+	}
+}
@@ -0,0 +1,203 @@
+// Package ratelimit keeps token buckets per client IP and API key, and caps
+// how many streams a client may hold open.
+package ratelimit
+
+import (
+	"context"
+	"cropto-dashboard/auth"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	TierAnonymous = "anonymous"
+
+	// ClassRequest is every API call, ClassUpstream the calls that reach
+	// the Binance REST API and spend its IP weight.
+	ClassRequest  = "request"
+	ClassUpstream = "upstream"
+)
+
+var (
+	ErrTooManySockets = errors.New("too many open streams")
+	ErrServerFull     = errors.New("server is at its stream limit")
+)
+
+// Quota is what one client of a tier may use. A zero rate or socket count
+// is unlimited.
+type Quota struct {
+	PerMinute         float64
+	Burst             int
+	UpstreamPerMinute float64
+	UpstreamBurst     int
+	Sockets           int
+}
+
+type Config struct {
+	// Tiers holds the quota for anonymous callers and for each role.
+	Tiers map[string]Quota
+	// MaxSockets caps the streams open on this node, 0 is unlimited.
+	MaxSockets int
+	// IdleAfter drops buckets that were not used for so long.
+	IdleAfter time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Tiers: map[string]Quota{
+			TierAnonymous:  {PerMinute: 120, Burst: 30, UpstreamPerMinute: 10, UpstreamBurst: 5, Sockets: 5},
+			auth.RoleUser:  {PerMinute: 600, Burst: 100, UpstreamPerMinute: 60, UpstreamBurst: 20, Sockets: 20},
+			auth.RoleAdmin: {UpstreamPerMinute: 240, UpstreamBurst: 60},
+		},
+		MaxSockets: 5000,
+		IdleAfter:  10 * time.Minute,
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time since its last use and spends one
+// token, or says how long until one is available.
+func (b *bucket) take(now time.Time, perMinute float64, burst int) (time.Duration, bool) {
+	rate := perMinute / 60
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+}
+
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	sockets map[string]int
+	open    int
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		sockets: make(map[string]int),
+	}
+}
+
+// Client names the bucket owner: the API key for authenticated callers,
+// sessions included, and the IP address for everyone else.
+func Client(identity *auth.Identity, ip string) (client, tier string) {
+	if identity != nil {
+		return "key:" + identity.KeyID, identity.Role
+	}
+	return "ip:" + ip, TierAnonymous
+}
+
+func (l *Limiter) quota(tier string) Quota {
+	if q, ok := l.cfg.Tiers[tier]; ok {
+		return q
+	}
+	return l.cfg.Tiers[TierAnonymous]
+}
+
+// Allow spends a token of class for client. When the bucket is empty it
+// returns how long the client should wait.
+func (l *Limiter) Allow(client, tier, class string) (time.Duration, bool) {
+	q := l.quota(tier)
+	perMinute, burst := q.PerMinute, q.Burst
+	if class == ClassUpstream {
+		perMinute, burst = q.UpstreamPerMinute, q.UpstreamBurst
+	}
+	if perMinute <= 0 {
+		return 0, true
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := l.now()
+	key := class + "|" + client
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	return b.take(now, perMinute, burst)
+}
+
+// AcquireSocket reserves a stream slot for client. release must be called
+// once the stream ends; calling it again does nothing.
+func (l *Limiter) AcquireSocket(client, tier string) (release func(), err error) {
+	limit := l.quota(tier).Sockets
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if limit > 0 && l.sockets[client] >= limit {
+		return nil, ErrTooManySockets
+	}
+	if l.cfg.MaxSockets > 0 && l.open >= l.cfg.MaxSockets {
+		return nil, ErrServerFull
+	}
+	l.sockets[client]++
+	l.open++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.open--
+			if l.sockets[client]--; l.sockets[client] <= 0 {
+				delete(l.sockets, client)
+			}
+		})
+	}, nil
+}
+
+// OpenSockets returns the number of streams holding a slot.
+func (l *Limiter) OpenSockets() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.open
+}
+
+// Run drops idle buckets until ctx is done. An idle bucket has refilled,
+// so dropping it changes nothing for its client.
+func (l *Limiter) Run(ctx context.Context) {
+	if l.cfg.IdleAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(l.cfg.IdleAfter / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep()
+		}
+	}
+}
+
+func (l *Limiter) sweep() {
+	cutoff := l.now().Add(-l.cfg.IdleAfter)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for key, b := range l.buckets {
+		if b.last.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+}
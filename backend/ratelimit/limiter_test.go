@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"cropto-dashboard/auth"
+	"testing"
+	"time"
+)
+
+func newLimiter(now *time.Time) *Limiter {
+	l := New(Config{
+		Tiers: map[string]Quota{
+			TierAnonymous:  {PerMinute: 60, Burst: 3, UpstreamPerMinute: 6, UpstreamBurst: 1, Sockets: 2},
+			auth.RoleUser:  {PerMinute: 600, Burst: 10},
+			auth.RoleAdmin: {},
+		},
+		MaxSockets: 3,
+		IdleAfter:  time.Minute,
+	})
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestBurstThenRefill(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newLimiter(&now)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	wait, ok := l.Allow("ip:1", TierAnonymous, ClassRequest)
+	if ok || wait != time.Second {
+		t.Fatalf("got ok=%v wait=%v, want a refusal for one second", ok, wait)
+	}
+
+	// 60 a minute is one token a second.
+	now = now.Add(500 * time.Millisecond)
+	if wait, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); ok || wait != 500*time.Millisecond {
+		t.Fatalf("got ok=%v wait=%v after half a token", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); !ok {
+		t.Fatal("refused after a token refilled")
+	}
+
+	// A long pause refills no more than the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); !ok {
+			t.Fatalf("request %d refused after a pause", i+1)
+		}
+	}
+	if _, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); ok {
+		t.Fatal("burst exceeded after a pause")
+	}
+}
+
+func TestBucketsAreSeparate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newLimiter(&now)
+
+	if _, ok := l.Allow("ip:1", TierAnonymous, ClassUpstream); !ok {
+		t.Fatal("first upstream call refused")
+	}
+	if wait, ok := l.Allow("ip:1", TierAnonymous, ClassUpstream); ok || wait != 10*time.Second {
+		t.Fatalf("got ok=%v wait=%v, want the upstream bucket empty for 10s", ok, wait)
+	}
+	if _, ok := l.Allow("ip:1", TierAnonymous, ClassRequest); !ok {
+		t.Error("the upstream bucket spent request tokens")
+	}
+	if _, ok := l.Allow("ip:2", TierAnonymous, ClassUpstream); !ok {
+		t.Error("another client shares the bucket")
+	}
+	// Unknown tiers fall back to the anonymous quota, zero rates are unlimited.
+	if _, ok := l.Allow("key:x", "unknown", ClassUpstream); !ok {
+		t.Error("first call of an unknown tier refused")
+	}
+	if _, ok := l.Allow("key:x", "unknown", ClassUpstream); ok {
+		t.Error("unknown tier was not limited like anonymous callers")
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := l.Allow("key:admin", auth.RoleAdmin, ClassRequest); !ok {
+			t.Fatal("an unlimited tier was refused")
+		}
+	}
+}
+
+func TestSweepDropsIdleBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newLimiter(&now)
+	l.Allow("ip:1", TierAnonymous, ClassRequest)
+	now = now.Add(30 * time.Second)
+	l.Allow("ip:2", TierAnonymous, ClassRequest)
+
+	now = now.Add(45 * time.Second)
+	l.sweep()
+	if _, ok := l.buckets[ClassRequest+"|ip:1"]; ok {
+		t.Error("idle bucket kept")
+	}
+	if _, ok := l.buckets[ClassRequest+"|ip:2"]; !ok {
+		t.Error("recent bucket dropped")
+	}
+}
+
+func TestSockets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newLimiter(&now)
+
+	a, err := l.AcquireSocket("ip:1", TierAnonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AcquireSocket("ip:1", TierAnonymous); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AcquireSocket("ip:1", TierAnonymous); err != ErrTooManySockets {
+		t.Fatalf("got %v, want ErrTooManySockets", err)
+	}
+	if _, err := l.AcquireSocket("ip:2", TierAnonymous); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AcquireSocket("ip:3", TierAnonymous); err != ErrServerFull {
+		t.Fatalf("got %v, want ErrServerFull", err)
+	}
+
+	a()
+	a()
+	if l.OpenSockets() != 2 {
+		t.Fatalf("open = %d after releasing one twice, want 2", l.OpenSockets())
+	}
+	if _, err := l.AcquireSocket("ip:1", TierAnonymous); err != nil {
+		t.Fatalf("slot not freed: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package rates
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"fmt"
+	"strings"
+)
+
+// ConvertChart re-quotes historical candles. When the exchange lists a pair
+// between the two assets every candle is converted at that pair's close for
+// the same period; otherwise the current rate is used for the whole series.
+func (e *Engine) ConvertChart(data *exchange.ChartData, quote string) error {
+	_, native, ok := exchange.SplitSymbol(data.Symbol)
+	if !ok {
+		return fmt.Errorf("unknown quote asset for %s", data.Symbol)
+	}
+	quote = strings.ToUpper(quote)
+	if native == quote || len(data.Candlesticks) == 0 {
+		return nil
+	}
+
+	current, err := e.Rate(native, quote)
+	if err != nil {
+		return err
+	}
+	historical := e.historicalRates(native, quote, data.Interval, len(data.Candlesticks))
+
+	for i := range data.Candlesticks {
+		c := &data.Candlesticks[i]
+		rate, ok := historical[c.OpenTime]
+		if !ok {
+			rate = current
+		}
+		c.Open = scale(c.Open, rate)
+		c.High = scale(c.High, rate)
+		c.Low = scale(c.Low, rate)
+		c.Close = scale(c.Close, rate)
+	}
+	data.Quote = quote
+	return nil
+}
+
+// HistoryCalls is the most Binance requests ConvertChart makes to convert
+// symbol's candles to quote, so callers can charge them up front. A pair not
+// yet seen trading costs two, one per direction.
+func (e *Engine) HistoryCalls(symbol, quote string) int {
+	_, native, ok := exchange.SplitSymbol(symbol)
+	quote = strings.ToUpper(quote)
+	if !ok || native == quote {
+		return 0
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.listed[quote+native] || e.listed[native+quote] {
+		return 1
+	}
+	return 2
+}
+
+// historicalRates returns native->quote rates by candle open time, or nil when
+// neither QUOTE+NATIVE nor NATIVE+QUOTE trades on the exchange. Only a pair
+// known to trade is asked for, otherwise both are tried in that order.
+func (e *Engine) historicalRates(native, quote, interval string, limit int) map[int64]decimal.Decimal {
+	candidates := []string{quote + native, native + quote}
+	e.mutex.RLock()
+	switch {
+	case e.listed[quote+native]:
+		candidates = candidates[:1]
+	case e.listed[native+quote]:
+		candidates = candidates[1:]
+	}
+	e.mutex.RUnlock()
+
+	for _, symbol := range candidates {
+		data, err := exchange.GetHistoricalData(symbol, interval, limit)
+		if err != nil {
+			continue
+		}
+		e.mutex.Lock()
+		e.listed[symbol] = true
+		e.mutex.Unlock()
+
+		invert := symbol == quote+native
+		rates := make(map[int64]decimal.Decimal, len(data.Candlesticks))
+		for _, c := range data.Candlesticks {
+			price := c.Close
+			if !price.IsPositive() {
+				continue
+			}
+			if invert {
+				price = decimal.One.Div(price, ratePlaces)
+			}
+			rates[c.OpenTime] = price
+		}
+		return rates
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package rates
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeKlines serves one candle at close for each listed symbol and 400 for
+// the rest, counting the requests per symbol.
+func fakeKlines(t *testing.T, closes map[string]string) map[string]int {
+	t.Helper()
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		mutex.Lock()
+		calls[symbol]++
+		mutex.Unlock()
+		close, ok := closes[symbol]
+		if !ok {
+			w.WriteHeader(400)
+			fmt.Fprint(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+			return
+		}
+		fmt.Fprintf(w, `[[1700000000000,"1","1","1","%s","1",1700003599999,"1",1,"1","1","0"]]`, close)
+	}))
+	t.Cleanup(srv.Close)
+	restURL := exchange.BinanceRESTURL
+	exchange.BinanceRESTURL = srv.URL
+	t.Cleanup(func() { exchange.BinanceRESTURL = restURL })
+	return calls
+}
+
+func chart(symbol string) *exchange.ChartData {
+	return &exchange.ChartData{
+		Symbol:   symbol,
+		Interval: "1h",
+		Candlesticks: []exchange.CandleStick{{
+			OpenTime: 1700000000000,
+			Close:    decimal.MustParse("10"),
+		}},
+	}
+}
+
+func TestConvertChartAsksForALivePairOnce(t *testing.T) {
+	calls := fakeKlines(t, map[string]string{"BTCUSDT": "50000"})
+	e := newTestEngine()
+
+	if n := e.HistoryCalls("ETHBTC", "USDT"); n != 1 {
+		t.Fatalf("HistoryCalls = %d for a pair trading live, want 1", n)
+	}
+	data := chart("ETHBTC")
+	if err := e.ConvertChart(data, "USDT"); err != nil {
+		t.Fatal(err)
+	}
+	if got := data.Candlesticks[0].Close.String(); got != "500000.00000000" {
+		t.Errorf("close = %s, want 500000.00000000 at the historical rate", got)
+	}
+	if len(calls) != 1 || calls["BTCUSDT"] != 1 {
+		t.Errorf("requests = %v, want BTCUSDT once", calls)
+	}
+}
+
+func TestConvertChartLearnsThePairDirection(t *testing.T) {
+	calls := fakeKlines(t, map[string]string{"USDTEUR": "0.9"})
+	e := NewEngine(DefaultFX)
+
+	if n := e.HistoryCalls("BTCUSDT", "EUR"); n != 2 {
+		t.Fatalf("HistoryCalls = %d for an unknown pair, want 2", n)
+	}
+	if err := e.ConvertChart(chart("BTCUSDT"), "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if calls["EURUSDT"] != 1 || calls["USDTEUR"] != 1 {
+		t.Fatalf("requests = %v, want both directions tried once", calls)
+	}
+
+	if n := e.HistoryCalls("BTCUSDT", "EUR"); n != 1 {
+		t.Fatalf("HistoryCalls = %d once USDTEUR answered, want 1", n)
+	}
+	data := chart("ETHUSDT")
+	if err := e.ConvertChart(data, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if calls["EURUSDT"] != 1 || calls["USDTEUR"] != 2 {
+		t.Errorf("requests = %v, want only USDTEUR asked again", calls)
+	}
+	if got := data.Candlesticks[0].Close.String(); got != "9.00000000" {
+		t.Errorf("close = %s, want 9.0", got)
+	}
+}
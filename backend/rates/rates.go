@@ -0,0 +1,254 @@
+package rates
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultFX is units of each fiat currency per US dollar. It is only a
+// fallback for currencies without a live pair such as EURUSDT, override it
+// with FX_RATES.
+var DefaultFX = map[string]decimal.Decimal{
+	"USD": decimal.One,
+	"EUR": decimal.MustParse("0.92"),
+	"GBP": decimal.MustParse("0.79"),
+}
+
+// ratePlaces is the precision kept for inverted and chained rates.
+const ratePlaces = 18
+
+// ParseFX reads a table like "EUR=0.92,GBP=0.79".
+func ParseFX(s string) (map[string]decimal.Decimal, error) {
+	table := make(map[string]decimal.Decimal)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid FX entry %q, expected CUR=rate", part)
+		}
+		rate, err := decimal.Parse(kv[1])
+		if err != nil || !rate.IsPositive() {
+			return nil, fmt.Errorf("invalid FX rate for %s", kv[0])
+		}
+		table[strings.ToUpper(strings.TrimSpace(kv[0]))] = rate
+	}
+	return table, nil
+}
+
+// Engine converts between assets over a graph built from live symbol prices,
+// falling back to the static FX table (with USD pegged to USDT) when no live
+// path exists.
+type Engine struct {
+	mutex  sync.RWMutex
+	live   map[string]map[string]decimal.Decimal
+	static map[string]map[string]decimal.Decimal
+	listed map[string]bool // symbols known to trade, for historical rates
+}
+
+func NewEngine(fx map[string]decimal.Decimal) *Engine {
+	e := &Engine{
+		live:   make(map[string]map[string]decimal.Decimal),
+		static: make(map[string]map[string]decimal.Decimal),
+		listed: make(map[string]bool),
+	}
+
+	addEdge(e.static, "USD", "USDT", decimal.One)
+	for currency, perUSD := range fx {
+		if currency != "USD" {
+			addEdge(e.static, "USD", currency, perUSD)
+		}
+	}
+	return e
+}
+
+func addEdge(graph map[string]map[string]decimal.Decimal, from, to string, rate decimal.Decimal) {
+	if graph[from] == nil {
+		graph[from] = make(map[string]decimal.Decimal)
+	}
+	if graph[to] == nil {
+		graph[to] = make(map[string]decimal.Decimal)
+	}
+	graph[from][to] = rate
+	graph[to][from] = decimal.One.Div(rate, ratePlaces)
+}
+
+// SetPrice records the last price of a symbol such as ETHBTC.
+func (e *Engine) SetPrice(symbol string, price decimal.Decimal) {
+	base, quote, ok := exchange.SplitSymbol(symbol)
+	if !ok || !price.IsPositive() {
+		return
+	}
+	e.mutex.Lock()
+	addEdge(e.live, base, quote, price)
+	e.listed[base+quote] = true
+	e.mutex.Unlock()
+}
+
+// OnMessage feeds live trade prices into the graph. Tickers only repeat the
+// last trade price a second later, so they add nothing.
+func (e *Engine) OnMessage(message []byte) {
+	var msg types.TickerMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Backfilled {
+		return
+	}
+	if msg.EventType != "trade" {
+		return
+	}
+	e.SetPrice(msg.Symbol, msg.Price)
+}
+
+// Rate returns how many units of to one unit of from is worth. Paths over live
+// prices win over paths that need the static FX table.
+func (e *Engine) Rate(from, to string) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return decimal.One, nil
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if rate, ok := search(from, to, e.live); ok {
+		return rate, nil
+	}
+	if rate, ok := search(from, to, e.live, e.static); ok {
+		return rate, nil
+	}
+	return decimal.Zero, fmt.Errorf("no conversion path from %s to %s", from, to)
+}
+
+func (e *Engine) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, error) {
+	rate, err := e.Rate(from, to)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.Mul(rate), nil
+}
+
+// search is a breadth first walk, so the path with the fewest hops is used.
+func search(from, to string, graphs ...map[string]map[string]decimal.Decimal) (decimal.Decimal, bool) {
+	rates := map[string]decimal.Decimal{from: decimal.One}
+	queue := []string{from}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for _, graph := range graphs {
+			for next, rate := range graph[node] {
+				if _, seen := rates[next]; seen {
+					continue
+				}
+				rates[next] = rates[node].Mul(rate).Round(ratePlaces)
+				if next == to {
+					return rates[next], true
+				}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return decimal.Zero, false
+}
+
+// Supports reports whether anything can currently be quoted in quote.
+func (e *Engine) Supports(quote string) bool {
+	quote = strings.ToUpper(quote)
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return len(e.live[quote]) > 0 || len(e.static[quote]) > 0
+}
+
+// Currencies lists every asset that appears in the graph.
+func (e *Engine) Currencies() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	for asset := range e.live {
+		seen[asset] = true
+	}
+	for asset := range e.static {
+		seen[asset] = true
+	}
+	currencies := make([]string, 0, len(seen))
+	for asset := range seen {
+		currencies = append(currencies, asset)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// scale converts a price, keeping its own precision but at least 8 places.
+func scale(value, rate decimal.Decimal) decimal.Decimal {
+	places := value.Scale()
+	if places < 8 {
+		places = 8
+	}
+	return value.Mul(rate).Round(places)
+}
+
+// ConvertMessage re-quotes a trade, ticker or candle message into quote.
+// Base volumes stay as they are, quote volumes are converted. Other messages
+// carry no market prices and are returned unchanged.
+func (e *Engine) ConvertMessage(message []byte, quote string) ([]byte, error) {
+	var env struct {
+		Symbol    string `json:"symbol"`
+		EventType string `json:"eventType"`
+	}
+	if err := json.Unmarshal(message, &env); err != nil {
+		return nil, err
+	}
+	if env.EventType != "trade" && env.EventType != "ticker" && env.EventType != "candle" {
+		return message, nil
+	}
+	_, native, ok := exchange.SplitSymbol(env.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("unknown quote asset for %s", env.Symbol)
+	}
+	quote = strings.ToUpper(quote)
+	if native == quote {
+		return message, nil
+	}
+	rate, err := e.Rate(native, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	switch env.EventType {
+	case "trade", "ticker":
+		var msg types.TickerMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			return nil, err
+		}
+		msg.Price = scale(msg.Price, rate)
+		msg.Change = scale(msg.Change, rate)
+		msg.High = scale(msg.High, rate)
+		msg.Low = scale(msg.Low, rate)
+		msg.QuoteVolume = scale(msg.QuoteVolume, rate)
+		msg.Bid = scale(msg.Bid, rate)
+		msg.Ask = scale(msg.Ask, rate)
+		msg.Quote = quote
+		return json.Marshal(msg)
+	case "candle":
+		var msg types.CandleMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			return nil, err
+		}
+		msg.Open = scale(msg.Open, rate)
+		msg.High = scale(msg.High, rate)
+		msg.Low = scale(msg.Low, rate)
+		msg.Close = scale(msg.Close, rate)
+		msg.Quote = quote
+		return json.Marshal(msg)
+	}
+	return message, nil
+}
@@ -0,0 +1,86 @@
+package rates
+
+import (
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"testing"
+)
+
+func newTestEngine() *Engine {
+	e := NewEngine(DefaultFX)
+	e.SetPrice("ETHBTC", decimal.MustParse("0.05"))
+	e.SetPrice("BTCUSDT", decimal.MustParse("60000"))
+	return e
+}
+
+func TestRateChainsLivePrices(t *testing.T) {
+	e := newTestEngine()
+
+	cases := []struct {
+		from, to, want string
+	}{
+		{"ETH", "USDT", "3000"},
+		{"BTC", "ETH", "20"},
+		// USDT to USD is pegged, USD to EUR comes from the FX table.
+		{"ETH", "EUR", "2760"},
+		{"USDT", "USDT", "1"},
+	}
+	for _, c := range cases {
+		got, err := e.Rate(c.from, c.to)
+		if err != nil {
+			t.Errorf("Rate(%s, %s): %v", c.from, c.to, err)
+			continue
+		}
+		if got.Cmp(decimal.MustParse(c.want)) != 0 {
+			t.Errorf("Rate(%s, %s) = %s, want %s", c.from, c.to, got, c.want)
+		}
+	}
+
+	if _, err := e.Rate("ETH", "JPY"); err == nil {
+		t.Error("expected an error without a path to JPY")
+	}
+}
+
+func TestOnMessageUsesTradesOnly(t *testing.T) {
+	e := NewEngine(DefaultFX)
+	e.OnMessage([]byte(`{"symbol":"SOLUSDT","price":"1700000000000","eventType":"ticker"}`))
+	if _, err := e.Rate("SOL", "USDT"); err == nil {
+		t.Fatal("ticker prices must not enter the graph")
+	}
+
+	e.OnMessage([]byte(`{"symbol":"SOLUSDT","price":"150","eventType":"trade"}`))
+	e.OnMessage([]byte(`{"symbol":"SOLUSDT","price":"1","eventType":"trade","backfilled":true}`))
+	got, err := e.Rate("SOL", "USDT")
+	if err != nil || got.Cmp(decimal.MustParse("150")) != 0 {
+		t.Fatalf("Rate(SOL, USDT) = %s, %v, want 150", got, err)
+	}
+}
+
+func TestConvertMessage(t *testing.T) {
+	e := newTestEngine()
+
+	out, err := e.ConvertMessage([]byte(`{"symbol":"ETHBTC","price":"0.05","volume":"2","eventType":"trade"}`), "usdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg types.TickerMessage
+	if err := json.Unmarshal(out, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Price.Cmp(decimal.MustParse("3000")) != 0 || msg.Quote != "USDT" {
+		t.Errorf("converted price = %s %s, want 3000 USDT", msg.Price, msg.Quote)
+	}
+	if msg.Volume.Cmp(decimal.MustParse("2")) != 0 {
+		t.Errorf("base volume changed to %s", msg.Volume)
+	}
+
+	if _, err := e.ConvertMessage([]byte(`{"symbol":"ETHBTC","price":"0.05","eventType":"trade"}`), "JPY"); err == nil {
+		t.Error("expected an error without a rate to JPY")
+	}
+
+	plain := []byte(`{"eventType":"market.overview","gainers":[]}`)
+	if out, err := e.ConvertMessage(plain, "EUR"); err != nil || string(out) != string(plain) {
+		t.Errorf("messages without prices should pass unchanged, got %s, %v", out, err)
+	}
+}
@@ -0,0 +1,76 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LoadIndex reads the segment index of a recording directory, oldest first.
+func LoadIndex(dir string) ([]*Segment, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorder index: %w", err)
+	}
+
+	var segments []*Segment
+	if err := json.Unmarshal(data, &segments); err != nil {
+		return nil, fmt.Errorf("failed to decode recorder index: %w", err)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	return segments, nil
+}
+
+// SegmentReader iterates over the records of one segment file.
+type SegmentReader struct {
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+func OpenSegment(dir, name string) (*SegmentReader, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+
+	return &SegmentReader{file: file, gz: gz, scanner: scanner}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the segment. A segment
+// cut short by a crash ends at its last complete line.
+func (s *SegmentReader) Next() (Record, error) {
+	for s.scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(s.scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		return rec, nil
+	}
+
+	if err := s.scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func (s *SegmentReader) Close() error {
+	s.gz.Close()
+	return s.file.Close()
+}
@@ -0,0 +1,437 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	KindRaw     = "raw"
+	KindMessage = "message"
+)
+
+// Record is one NDJSON line in a segment file.
+type Record struct {
+	Time   int64           `json:"ts"`
+	Kind   string          `json:"kind"`
+	Symbol string          `json:"symbol,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type Config struct {
+	Dir           string
+	SegmentBytes  int64
+	SegmentAge    time.Duration
+	Retention     time.Duration
+	MaxTotalBytes int64
+	RecordRaw     bool
+	BufferSize    int
+	FlushInterval time.Duration
+}
+
+func DefaultConfig(dir string) Config {
+	return Config{
+		Dir:           dir,
+		SegmentBytes:  64 << 20,
+		SegmentAge:    time.Hour,
+		Retention:     7 * 24 * time.Hour,
+		MaxTotalBytes: 10 << 30,
+		RecordRaw:     true,
+		BufferSize:    4096,
+		FlushInterval: time.Second,
+	}
+}
+
+type SymbolRange struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+	Count int64 `json:"count"`
+}
+
+// Segment describes one closed (or the currently open) segment file.
+type Segment struct {
+	File    string `json:"file"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Records int64  `json:"records"`
+	Bytes   int64  `json:"bytes"`
+	// DiskBytes is the compressed size, known once the segment is closed.
+	DiskBytes int64                   `json:"diskBytes"`
+	Symbols   map[string]*SymbolRange `json:"symbols"`
+	Open      bool                    `json:"open,omitempty"`
+}
+
+func (s *Segment) observe(r Record) {
+	if s.Start == 0 || r.Time < s.Start {
+		s.Start = r.Time
+	}
+	if r.Time > s.End {
+		s.End = r.Time
+	}
+	s.Records++
+
+	if r.Symbol == "" {
+		return
+	}
+	rng, ok := s.Symbols[r.Symbol]
+	if !ok {
+		rng = &SymbolRange{First: r.Time}
+		s.Symbols[r.Symbol] = rng
+	}
+	rng.Last = r.Time
+	rng.Count++
+}
+
+// clone copies the segment with its symbol ranges, which the writer keeps
+// updating on the open segment.
+func (s *Segment) clone() Segment {
+	c := *s
+	c.Symbols = make(map[string]*SymbolRange, len(s.Symbols))
+	for symbol, rng := range s.Symbols {
+		copied := *rng
+		c.Symbols[symbol] = &copied
+	}
+	return c
+}
+
+// Recorder appends raw frames and normalized messages to rotating gzip NDJSON
+// segments and keeps an index of which symbols and times each segment covers.
+type Recorder struct {
+	cfg     Config
+	records chan Record
+
+	mutex    sync.RWMutex
+	index    []*Segment
+	current  *Segment
+	file     *os.File
+	gz       *gzip.Writer
+	buf      *bufio.Writer
+	openedAt time.Time
+	dropped  atomic.Int64
+	closed   bool
+
+	done chan struct{}
+}
+
+func New(cfg Config) (*Recorder, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recorder dir: %w", err)
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 4096
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	r := &Recorder{
+		cfg:     cfg,
+		records: make(chan Record, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+
+	if err := r.loadIndex(); err != nil {
+		log.Printf("Recorder index unreadable, starting fresh: %v", err)
+	}
+	return r, nil
+}
+
+func (r *Recorder) indexPath() string {
+	return filepath.Join(r.cfg.Dir, "index.json")
+}
+
+func (r *Recorder) loadIndex() error {
+	data, err := os.ReadFile(r.indexPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.index)
+}
+
+func (r *Recorder) saveIndex() error {
+	data, err := json.MarshalIndent(r.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.indexPath())
+}
+
+func (r *Recorder) Run() {
+	defer close(r.done)
+
+	flush := time.NewTicker(r.cfg.FlushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case rec, ok := <-r.records:
+			if !ok {
+				r.closeSegment()
+				return
+			}
+			if err := r.write(rec); err != nil {
+				log.Printf("Recorder write failed: %v", err)
+			}
+		case <-flush.C:
+			r.flush()
+			if r.file != nil && time.Since(r.openedAt) > r.cfg.SegmentAge {
+				r.closeSegment()
+			}
+		}
+	}
+}
+
+// RecordRaw queues a raw exchange frame. It never blocks the ingest path.
+func (r *Recorder) RecordRaw(frame []byte) {
+	if !r.cfg.RecordRaw {
+		return
+	}
+	r.enqueue(Record{
+		Time:   time.Now().UnixMilli(),
+		Kind:   KindRaw,
+		Symbol: rawSymbol(frame),
+		Data:   append(json.RawMessage(nil), frame...),
+	})
+}
+
+// RecordMessage queues a normalized message as broadcast to the hub.
+func (r *Recorder) RecordMessage(message []byte) {
+	var head struct {
+		Symbol string `json:"symbol"`
+	}
+	json.Unmarshal(message, &head)
+
+	r.enqueue(Record{
+		Time:   time.Now().UnixMilli(),
+		Kind:   KindMessage,
+		Symbol: strings.ToUpper(head.Symbol),
+		Data:   append(json.RawMessage(nil), message...),
+	})
+}
+
+func (r *Recorder) enqueue(rec Record) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return
+	}
+	select {
+	case r.records <- rec:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func rawSymbol(frame []byte) string {
+	var wrapper struct {
+		Stream string `json:"stream"`
+	}
+	if err := json.Unmarshal(frame, &wrapper); err != nil || wrapper.Stream == "" {
+		return ""
+	}
+	symbol, _, _ := strings.Cut(wrapper.Stream, "@")
+	return strings.ToUpper(symbol)
+}
+
+func (r *Recorder) write(rec Record) error {
+	if r.file == nil {
+		if err := r.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := r.buf.Write(line); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.current.observe(rec)
+	r.current.Bytes += int64(len(line))
+	size := r.current.Bytes
+	r.mutex.Unlock()
+
+	if size >= r.cfg.SegmentBytes {
+		r.closeSegment()
+	}
+	return nil
+}
+
+func (r *Recorder) openSegment() error {
+	now := time.Now().UTC()
+	name := filepath.Join(now.Format("2006-01-02"), fmt.Sprintf("segment-%d.ndjson.gz", now.UnixNano()))
+	path := filepath.Join(r.cfg.Dir, name)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	r.file = file
+	r.gz = gzip.NewWriter(file)
+	r.buf = bufio.NewWriterSize(r.gz, 64<<10)
+	r.openedAt = time.Now()
+
+	r.mutex.Lock()
+	r.current = &Segment{File: name, Symbols: make(map[string]*SymbolRange), Open: true}
+	r.mutex.Unlock()
+	return nil
+}
+
+func (r *Recorder) flush() {
+	if r.buf == nil {
+		return
+	}
+	r.buf.Flush()
+	r.gz.Flush()
+}
+
+func (r *Recorder) closeSegment() {
+	if r.file == nil {
+		return
+	}
+
+	r.buf.Flush()
+	r.gz.Close()
+	r.file.Close()
+	r.file, r.gz, r.buf = nil, nil, nil
+
+	r.mutex.Lock()
+	r.current.Open = false
+	if info, err := os.Stat(filepath.Join(r.cfg.Dir, r.current.File)); err == nil {
+		r.current.DiskBytes = info.Size()
+	}
+	if r.current.Records > 0 {
+		r.index = append(r.index, r.current)
+	} else {
+		os.Remove(filepath.Join(r.cfg.Dir, r.current.File))
+	}
+	r.current = nil
+	r.enforceRetentionLocked()
+	err := r.saveIndex()
+	r.mutex.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to save recorder index: %v", err)
+	}
+}
+
+// enforceRetentionLocked deletes the oldest segments past the age or size caps.
+func (r *Recorder) enforceRetentionLocked() {
+	sort.Slice(r.index, func(i, j int) bool { return r.index[i].Start < r.index[j].Start })
+
+	total := int64(0)
+	for _, seg := range r.index {
+		total += seg.DiskBytes
+	}
+
+	cutoff := time.Now().Add(-r.cfg.Retention).UnixMilli()
+	kept := r.index[:0]
+	for _, seg := range r.index {
+		expired := r.cfg.Retention > 0 && seg.End < cutoff
+		oversize := r.cfg.MaxTotalBytes > 0 && total > r.cfg.MaxTotalBytes
+		if expired || oversize {
+			if err := os.Remove(filepath.Join(r.cfg.Dir, seg.File)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to delete segment %s: %v", seg.File, err)
+			}
+			total -= seg.DiskBytes
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	r.index = kept
+}
+
+// Segments lists segments overlapping [from, to] (ms, 0 = open ended) that
+// contain symbol (empty = any).
+func (r *Recorder) Segments(symbol string, from, to int64) []Segment {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	all := append([]*Segment(nil), r.index...)
+	if r.current != nil {
+		all = append(all, r.current)
+	}
+
+	return FilterSegments(all, symbol, from, to)
+}
+
+// FilterSegments returns copies of the matching segments, safe to use after
+// the caller releases the lock guarding segments.
+func FilterSegments(segments []*Segment, symbol string, from, to int64) []Segment {
+	symbol = strings.ToUpper(symbol)
+	out := []Segment{}
+	for _, seg := range segments {
+		if to > 0 && seg.Start > to {
+			continue
+		}
+		if from > 0 && seg.End < from {
+			continue
+		}
+		if symbol != "" {
+			if _, ok := seg.Symbols[symbol]; !ok {
+				continue
+			}
+		}
+		out = append(out, seg.clone())
+	}
+	return out
+}
+
+func (r *Recorder) Stats() map[string]interface{} {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	total := int64(0)
+	for _, seg := range r.index {
+		total += seg.DiskBytes
+	}
+	return map[string]interface{}{
+		"dir":      r.cfg.Dir,
+		"segments": len(r.index),
+		"bytes":    total,
+		"dropped":  r.dropped.Load(),
+		"queued":   len(r.records),
+	}
+}
+
+// Close flushes and closes the open segment. Nothing may be recorded afterwards.
+func (r *Recorder) Close() {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.records)
+	r.mutex.Unlock()
+
+	<-r.done
+}
@@ -0,0 +1,46 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// Segments must not share the open segment's symbol map with the writer;
+// run with -race to catch it.
+func TestSegmentsAreCopies(t *testing.T) {
+	rec, err := New(DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rec.Run()
+	defer rec.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			rec.RecordMessage([]byte(fmt.Sprintf(`{"symbol":"SYM%d","price":"1","timestamp":%d}`, i%50, 1000+i)))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			segments := rec.Segments("", 0, 0)
+			if len(segments) == 0 {
+				t.Fatal("expected the open segment to be listed")
+			}
+			return
+		default:
+			for _, seg := range rec.Segments("", 0, 0) {
+				if _, err := json.Marshal(seg); err != nil {
+					t.Fatal(err)
+				}
+				for _, rng := range seg.Symbols {
+					rng.Count = -1
+				}
+			}
+		}
+	}
+}
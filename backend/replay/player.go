@@ -0,0 +1,391 @@
+package replay
+
+import (
+	"cropto-dashboard/recorder"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatePlaying  = "playing"
+	StatePaused   = "paused"
+	StateFinished = "finished"
+	StateStopped  = "stopped"
+)
+
+type Config struct {
+	Dir     string
+	Speed   float64 // 0 replays as fast as the consumer reads
+	Start   int64   // ms, 0 = beginning of the recording
+	End     int64   // ms, 0 = end of the recording
+	Symbols []string
+	Loop    bool
+}
+
+type Status struct {
+	State    string  `json:"state"`
+	Speed    float64 `json:"speed"`
+	Position int64   `json:"position"`
+	Start    int64   `json:"start"`
+	End      int64   `json:"end"`
+	Sent     int64   `json:"sent"`
+	Loop     bool    `json:"loop"`
+}
+
+// Player serves recorded normalized messages through the same channel contract
+// as exchange.BinanceClient, pacing them by their recorded timestamps.
+type Player struct {
+	dir         string
+	MessageChan chan []byte
+
+	mutex    sync.Mutex
+	symbols  map[string]bool
+	speed    float64
+	start    int64
+	end      int64
+	loop     bool
+	paused   bool
+	state    string
+	position int64
+	sent     int64
+	seekTo   int64
+
+	control chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func NewPlayer(cfg Config) (*Player, error) {
+	segments, err := recorder.LoadIndex(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no recorded segments in %s", cfg.Dir)
+	}
+
+	p := &Player{
+		dir:         cfg.Dir,
+		MessageChan: make(chan []byte, 256),
+		speed:       cfg.Speed,
+		start:       cfg.Start,
+		end:         cfg.End,
+		loop:        cfg.Loop,
+		state:       StateStopped,
+		seekTo:      -1,
+		control:     make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	if p.start == 0 {
+		p.start = segments[0].Start
+	}
+	if p.end == 0 {
+		p.end = segments[len(segments)-1].End
+	}
+
+	if len(cfg.Symbols) > 0 {
+		p.symbols = make(map[string]bool)
+		for _, s := range cfg.Symbols {
+			p.symbols[strings.ToUpper(s)] = true
+		}
+	}
+	return p, nil
+}
+
+func (p *Player) Start() {
+	go p.run()
+}
+
+func (p *Player) GetMessageChannel() <-chan []byte {
+	return p.MessageChan
+}
+
+func (p *Player) Close() {
+	select {
+	case <-p.quit:
+		return
+	default:
+	}
+	close(p.quit)
+	<-p.done
+	close(p.MessageChan)
+}
+
+func (p *Player) Status() Status {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return Status{
+		State:    p.state,
+		Speed:    p.speed,
+		Position: p.position,
+		Start:    p.start,
+		End:      p.end,
+		Sent:     p.sent,
+		Loop:     p.loop,
+	}
+}
+
+func (p *Player) Pause() {
+	p.update(func() { p.paused = true })
+}
+
+func (p *Player) Resume() {
+	p.update(func() { p.paused = false })
+}
+
+func (p *Player) SetSpeed(speed float64) {
+	p.update(func() { p.speed = speed })
+}
+
+// SeekTo jumps to a recorded time (ms) inside the configured window.
+func (p *Player) SeekTo(at int64) error {
+	p.mutex.Lock()
+	if at < p.start || at > p.end {
+		p.mutex.Unlock()
+		return fmt.Errorf("seek target outside replay window [%d, %d]", p.start, p.end)
+	}
+	p.mutex.Unlock()
+
+	p.update(func() { p.seekTo = at })
+	return nil
+}
+
+func (p *Player) update(fn func()) {
+	p.mutex.Lock()
+	fn()
+	p.mutex.Unlock()
+
+	select {
+	case p.control <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Player) setState(state string) {
+	p.mutex.Lock()
+	p.state = state
+	p.mutex.Unlock()
+}
+
+func (p *Player) run() {
+	defer close(p.done)
+
+	from := p.Status().Start
+	for {
+		next, err := p.play(from)
+		if err != nil {
+			log.Printf("Replay failed: %v", err)
+		}
+
+		switch {
+		case next == quitPlayback:
+			p.setState(StateStopped)
+			return
+		case next >= 0:
+			from = next
+			continue
+		}
+
+		// Reached the end of the window.
+		p.mutex.Lock()
+		loop := p.loop
+		p.mutex.Unlock()
+		if loop {
+			from = p.Status().Start
+			continue
+		}
+
+		p.setState(StateFinished)
+		log.Println("Replay finished")
+		select {
+		case <-p.quit:
+			p.setState(StateStopped)
+			return
+		case <-p.control:
+			p.mutex.Lock()
+			from, p.seekTo = p.seekTo, -1
+			p.mutex.Unlock()
+			if from < 0 {
+				from = p.Status().Start
+			}
+		}
+	}
+}
+
+const (
+	quitPlayback = -2
+	noSeek       = -1
+)
+
+// play streams records from the given time and returns the next seek target,
+// noSeek at the end of the window, or quitPlayback when the player was closed.
+func (p *Player) play(from int64) (int64, error) {
+	segments, err := recorder.LoadIndex(p.dir)
+	if err != nil {
+		return noSeek, err
+	}
+
+	p.mutex.Lock()
+	end := p.end
+	p.position = from
+	p.mutex.Unlock()
+
+	var baseRec int64
+	var baseWall time.Time
+
+	for _, seg := range segments {
+		if seg.End < from || seg.Start > end {
+			continue
+		}
+
+		reader, err := recorder.OpenSegment(p.dir, seg.File)
+		if err != nil {
+			log.Printf("Skipping segment %s: %v", seg.File, err)
+			continue
+		}
+
+		for {
+			rec, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("Segment %s ended early: %v", seg.File, err)
+				break
+			}
+
+			if rec.Kind != recorder.KindMessage || rec.Time < from {
+				continue
+			}
+			if rec.Time > end {
+				reader.Close()
+				return noSeek, nil
+			}
+			if p.symbols != nil && !p.symbols[rec.Symbol] {
+				continue
+			}
+
+			if baseWall.IsZero() {
+				baseRec, baseWall = rec.Time, time.Now()
+			}
+
+			next, reset := p.wait(rec.Time, baseRec, baseWall)
+			if next != noSeek {
+				reader.Close()
+				return next, nil
+			}
+			if reset {
+				baseRec, baseWall = rec.Time, time.Now()
+			}
+
+			select {
+			case p.MessageChan <- []byte(rec.Data):
+			case <-p.quit:
+				reader.Close()
+				return quitPlayback, nil
+			}
+
+			p.mutex.Lock()
+			p.position = rec.Time
+			p.sent++
+			p.mutex.Unlock()
+		}
+		reader.Close()
+	}
+
+	return noSeek, nil
+}
+
+// wait blocks until a record recorded at recTime is due. It returns a seek
+// target or quitPlayback to abort, and reset=true when pacing must restart from
+// this record (after a pause or speed change).
+func (p *Player) wait(recTime, baseRec int64, baseWall time.Time) (int64, bool) {
+	reset := false
+	for {
+		p.mutex.Lock()
+		if p.seekTo >= 0 {
+			target := p.seekTo
+			p.seekTo = -1
+			p.mutex.Unlock()
+			return target, false
+		}
+		paused, speed := p.paused, p.speed
+		if paused {
+			p.state = StatePaused
+		} else {
+			p.state = StatePlaying
+		}
+		p.mutex.Unlock()
+
+		if paused {
+			select {
+			case <-p.quit:
+				return quitPlayback, false
+			case <-p.control:
+				reset = true
+				continue
+			}
+		}
+
+		if reset {
+			baseRec, baseWall = recTime, time.Now()
+		}
+		if speed <= 0 {
+			return noSeek, reset
+		}
+
+		due := baseWall.Add(time.Duration(float64(recTime-baseRec)/speed) * time.Millisecond)
+		delay := time.Until(due)
+		if delay <= 0 {
+			return noSeek, reset
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-p.quit:
+			timer.Stop()
+			return quitPlayback, false
+		case <-p.control:
+			timer.Stop()
+			reset = true
+		case <-timer.C:
+			return noSeek, reset
+		}
+	}
+}
+
+// ParseSpeed accepts "1", "10", "0.5" or "max".
+func ParseSpeed(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "x")
+	if s == "max" || s == "" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil || speed < 0 {
+		return 0, fmt.Errorf("invalid speed %q", s)
+	}
+	return speed, nil
+}
+
+// ParseTime accepts unix milliseconds or RFC3339.
+func ParseTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use unix ms or RFC3339", s)
+	}
+	return t.UnixMilli(), nil
+}
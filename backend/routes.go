@@ -0,0 +1,1335 @@
+package main
+
+import (
+	"cropto-dashboard/analytics"
+	"cropto-dashboard/anomaly"
+	"cropto-dashboard/arbitrage"
+	"cropto-dashboard/auth"
+	"cropto-dashboard/backtest"
+	"cropto-dashboard/config"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/health"
+	"cropto-dashboard/market"
+	"cropto-dashboard/metrics"
+	"cropto-dashboard/paper"
+	"cropto-dashboard/portfolio"
+	"cropto-dashboard/ratelimit"
+	"cropto-dashboard/rates"
+	"cropto-dashboard/recorder"
+	"cropto-dashboard/replay"
+	"cropto-dashboard/server/gql"
+	"cropto-dashboard/server/websocket"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	gorilla "github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+func registerSymbolRoutes(admin *gin.RouterGroup, binanceClient *exchange.ConnectionManager, symbolStore *exchange.SymbolStore) {
+	admin.GET("/symbols", func(c *gin.Context) {
+		c.JSON(200, gin.H{"symbols": binanceClient.GetSymbols()})
+	})
+
+	admin.POST("/symbols", func(c *gin.Context) {
+		var req struct {
+			Symbols []string `json:"symbols"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Symbols) == 0 {
+			c.JSON(400, gin.H{"error": "expected {\"symbols\": [\"pepeusdt\"]}"})
+			return
+		}
+
+		for _, s := range req.Symbols {
+			if !exchange.IsValidSymbol(s) {
+				c.JSON(400, gin.H{"error": fmt.Sprintf("invalid symbol %q", s)})
+				return
+			}
+		}
+
+		added, err := binanceClient.AddSymbols(req.Symbols...)
+		if err != nil {
+			log.Printf("Subscribe failed, will apply on reconnect: %v", err)
+		}
+		if err := symbolStore.Save(binanceClient.GetSymbols()); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"added": added, "symbols": binanceClient.GetSymbols()})
+	})
+
+	admin.POST("/symbols/top", func(c *gin.Context) {
+		var req struct {
+			Quote string `json:"quote"`
+			Count int    `json:"count"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Count <= 0 {
+			c.JSON(400, gin.H{"error": "expected {\"quote\": \"usdt\", \"count\": 200}"})
+			return
+		}
+		if req.Quote == "" {
+			req.Quote = "usdt"
+		}
+
+		top, err := exchange.GetTopSymbols(req.Quote, req.Count)
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error()})
+			return
+		}
+
+		added, err := binanceClient.AddSymbols(top...)
+		if err != nil {
+			log.Printf("Subscribe failed, will apply on reconnect: %v", err)
+		}
+		if err := symbolStore.Save(binanceClient.GetSymbols()); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"added": added, "symbols": binanceClient.GetSymbols()})
+	})
+
+	admin.GET("/connections", func(c *gin.Context) {
+		c.JSON(200, gin.H{"connections": binanceClient.Health()})
+	})
+
+	admin.DELETE("/symbols/:symbol", func(c *gin.Context) {
+		removed, err := binanceClient.RemoveSymbols(c.Param("symbol"))
+		if err != nil {
+			log.Printf("Unsubscribe failed, will apply on reconnect: %v", err)
+		}
+		if len(removed) == 0 {
+			c.JSON(404, gin.H{"error": "symbol is not tracked"})
+			return
+		}
+		if err := symbolStore.Save(binanceClient.GetSymbols()); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"removed": removed, "symbols": binanceClient.GetSymbols()})
+	})
+}
+
+func registerRecorderRoutes(admin *gin.RouterGroup, rec *recorder.Recorder) {
+	admin.GET("/recorder", func(c *gin.Context) {
+		c.JSON(200, rec.Stats())
+	})
+
+	admin.GET("/recorder/segments", func(c *gin.Context) {
+		from, _ := strconv.ParseInt(c.Query("from"), 10, 64)
+		to, _ := strconv.ParseInt(c.Query("to"), 10, 64)
+
+		c.JSON(200, gin.H{"segments": rec.Segments(c.Query("symbol"), from, to)})
+	})
+}
+
+// registerConfigRoutes shows the effective settings, which describe the
+// whole deployment.
+func registerConfigRoutes(admin *gin.RouterGroup, cfg *config.Config) {
+	admin.GET("", func(c *gin.Context) {
+		c.JSON(200, gin.H{"file": cfg.File(), "config": cfg.Redacted()})
+	})
+}
+
+func registerReplayRoutes(admin *gin.RouterGroup, player *replay.Player) {
+	admin.GET("/replay", func(c *gin.Context) {
+		c.JSON(200, player.Status())
+	})
+
+	admin.POST("/replay/pause", func(c *gin.Context) {
+		player.Pause()
+		c.JSON(200, player.Status())
+	})
+
+	admin.POST("/replay/resume", func(c *gin.Context) {
+		player.Resume()
+		c.JSON(200, player.Status())
+	})
+
+	admin.POST("/replay/speed", func(c *gin.Context) {
+		var req struct {
+			Speed string `json:"speed"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "expected {\"speed\": \"10\"} (or \"max\")"})
+			return
+		}
+		speed, err := replay.ParseSpeed(req.Speed)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		player.SetSpeed(speed)
+		c.JSON(200, player.Status())
+	})
+
+	admin.POST("/replay/seek", func(c *gin.Context) {
+		var req struct {
+			Time string `json:"time"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "expected {\"time\": \"2025-01-01T00:00:00Z\"}"})
+			return
+		}
+		at, err := replay.ParseTime(req.Time)
+		if err == nil {
+			err = player.SeekTo(at)
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, player.Status())
+	})
+}
+
+func registerBacktestRoutes(api *gin.RouterGroup) {
+	api.GET("/backtest/strategies", func(c *gin.Context) {
+		c.JSON(200, gin.H{"strategies": backtest.Strategies()})
+	})
+
+	api.POST("/backtest", func(c *gin.Context) {
+		req := struct {
+			Symbol   string          `json:"symbol"`
+			Interval string          `json:"interval"`
+			Limit    int             `json:"limit"`
+			Start    string          `json:"start"`
+			End      string          `json:"end"`
+			Strategy string          `json:"strategy"`
+			Params   backtest.Params `json:"params"`
+			Config   backtest.Config `json:"config"`
+		}{
+			Interval: "1h",
+			Limit:    500,
+			Config:   backtest.DefaultConfig(),
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Symbol == "" || req.Strategy == "" {
+			c.JSON(400, gin.H{"error": "symbol and strategy are required"})
+			return
+		}
+
+		strategy, err := backtest.NewStrategy(req.Strategy, req.Params)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		candles, err := backtestCandles(req.Symbol, req.Interval, req.Limit, req.Start, req.End)
+		if err != nil {
+			upstreamError(c, 502, err)
+			return
+		}
+
+		report, err := backtest.Run(strategy, candles, req.Config, req.Interval)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, report)
+	})
+}
+
+// backtestCandles loads the most recent limit candles, or a start/end range of up
+// to 10000 candles paged from Binance.
+func backtestCandles(symbol, interval string, limit int, start, end string) ([]backtest.Candle, error) {
+	symbol = strings.ToUpper(symbol)
+
+	if start == "" {
+		data, err := exchange.GetHistoricalData(symbol, interval, limit)
+		if err != nil {
+			return nil, err
+		}
+		return backtest.CandlesFromChart(data.Candlesticks), nil
+	}
+
+	from, err := replay.ParseTime(start)
+	if err != nil {
+		return nil, err
+	}
+	to := time.Now().UnixMilli()
+	if end != "" {
+		if to, err = replay.ParseTime(end); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := exchange.GetCandleSeries(symbol, interval, from, to, 10000)
+	if err != nil {
+		return nil, err
+	}
+	return backtest.CandlesFromChart(data), nil
+}
+
+// upstreamError answers 503 with Retry-After while Binance requests are
+// paused, and status for any other error.
+func upstreamError(c *gin.Context, status int, err error) {
+	var limited *exchange.RateLimitError
+	if errors.As(err, &limited) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func registerPaperRoutes(api *gin.RouterGroup, engine *paper.Engine) {
+	group := api.Group("/paper")
+
+	group.POST("/accounts", func(c *gin.Context) {
+		var req struct {
+			Name     string                     `json:"name"`
+			Balances map[string]decimal.Decimal `json:"balances"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Balances) == 0 {
+			req.Balances = map[string]decimal.Decimal{"USDT": decimal.NewFromInt(10000)}
+		}
+
+		acc, token, err := engine.CreateAccount(req.Name, req.Balances)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, gin.H{"id": acc.ID, "name": acc.Name, "token": token, "balances": acc.Balances})
+	})
+
+	// Everything below needs the token returned at account creation.
+	account := group.Group("/accounts/:id", func(c *gin.Context) {
+		switch err := engine.Authenticate(c.Param("id"), c.GetHeader("X-Paper-Token")); err {
+		case nil:
+			c.Next()
+		case paper.ErrNotFound:
+			c.AbortWithStatusJSON(404, gin.H{"error": "account not found"})
+		default:
+			c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+		}
+	})
+
+	account.GET("", func(c *gin.Context) {
+		view, err := engine.Account(c.Param("id"))
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, view)
+	})
+
+	account.GET("/orders", func(c *gin.Context) {
+		c.JSON(200, gin.H{"orders": engine.Orders(c.Param("id"), c.Query("status") == "open")})
+	})
+
+	account.POST("/orders", func(c *gin.Context) {
+		var req paper.OrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		orders, err := engine.PlaceOrder(c.Param("id"), req)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, gin.H{"orders": orders})
+	})
+
+	account.DELETE("/orders/:orderId", func(c *gin.Context) {
+		order, err := engine.CancelOrder(c.Param("id"), c.Param("orderId"))
+		if err == paper.ErrNotFound {
+			c.JSON(404, gin.H{"error": "order not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, order)
+	})
+
+	account.GET("/fills", func(c *gin.Context) {
+		c.JSON(200, gin.H{"fills": engine.Fills(c.Param("id"))})
+	})
+}
+
+// registerPaperCommands lets a socket receive its account's order, fill and
+// balance events: {"action":"paper.subscribe","accountId":"...","token":"..."}.
+func registerPaperCommands(hub *websocket.Hub, engine *paper.Engine) {
+	engine.SetNotifier(func(accountID string, message []byte) {
+		hub.SendTo("paper:"+accountID, message)
+	})
+
+	hub.HandleCommand("paper.subscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		var req struct {
+			AccountID string `json:"accountId"`
+			Token     string `json:"token"`
+		}
+		if err := json.Unmarshal(cmd.Raw, &req); err != nil {
+			return err
+		}
+		if err := engine.Authenticate(req.AccountID, req.Token); err != nil {
+			return err
+		}
+		c.Join("paper:" + req.AccountID)
+		return nil
+	})
+
+	hub.HandleCommand("paper.unsubscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		var req struct {
+			AccountID string `json:"accountId"`
+		}
+		if err := json.Unmarshal(cmd.Raw, &req); err != nil {
+			return err
+		}
+		c.Leave("paper:" + req.AccountID)
+		return nil
+	})
+}
+
+func registerPortfolioRoutes(api *gin.RouterGroup, tracker *portfolio.Tracker, rateEngine *rates.Engine) {
+	api.POST("/portfolios", func(c *gin.Context) {
+		var req struct {
+			Name   string `json:"name"`
+			Method string `json:"method"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		p, token, err := tracker.Create(req.Name, req.Method)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, gin.H{"id": p.ID, "name": p.Name, "method": p.Method, "token": token})
+	})
+
+	// Everything below needs the token returned at creation.
+	group := api.Group("/portfolios/:id", func(c *gin.Context) {
+		switch err := tracker.Authenticate(c.Param("id"), c.GetHeader("X-Portfolio-Token")); err {
+		case nil:
+			c.Next()
+		case portfolio.ErrNotFound:
+			c.AbortWithStatusJSON(404, gin.H{"error": "portfolio not found"})
+		default:
+			c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+		}
+	})
+
+	group.GET("", func(c *gin.Context) {
+		v, err := portfolioValue(tracker, rateEngine, c.Param("id"), c.Query("method"), c.Query("quote"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, v)
+	})
+
+	group.PUT("", func(c *gin.Context) {
+		var req struct {
+			Name   string `json:"name"`
+			Method string `json:"method"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tracker.Update(c.Param("id"), req.Name, req.Method); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		v, _ := tracker.Value(c.Param("id"), "")
+		c.JSON(200, v)
+	})
+
+	group.DELETE("", func(c *gin.Context) {
+		if err := tracker.Delete(c.Param("id")); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(204)
+	})
+
+	group.GET("/transactions", func(c *gin.Context) {
+		txs, err := tracker.Transactions(c.Param("id"))
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"transactions": txs})
+	})
+
+	group.POST("/transactions", func(c *gin.Context) {
+		var tx portfolio.Transaction
+		if err := c.ShouldBindJSON(&tx); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		tx, err := tracker.AddTransaction(c.Param("id"), tx)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, tx)
+	})
+
+	group.DELETE("/transactions/:txId", func(c *gin.Context) {
+		err := tracker.DeleteTransaction(c.Param("id"), c.Param("txId"))
+		if err == portfolio.ErrNotFound {
+			c.JSON(404, gin.H{"error": "transaction not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(204)
+	})
+
+	// Holdings are a shortcut for a transfer_in at the given cost price.
+	group.POST("/holdings", func(c *gin.Context) {
+		var req struct {
+			Asset     string          `json:"asset"`
+			Quantity  decimal.Decimal `json:"quantity"`
+			CostPrice decimal.Decimal `json:"costPrice"`
+			Time      time.Time       `json:"time"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		tx, err := tracker.AddTransaction(c.Param("id"), portfolio.Transaction{
+			Type:     portfolio.TxTransferIn,
+			Asset:    req.Asset,
+			Quantity: req.Quantity,
+			Price:    req.CostPrice,
+			Time:     req.Time,
+			Note:     "holding",
+		})
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, tx)
+	})
+}
+
+// registerPortfolioCommands pushes valuation updates to sockets that send
+// {"action":"portfolio.subscribe","portfolioId":"...","token":"...","quote":"EUR"}.
+// Each requested quote gets its own channel so updates are converted once.
+// The tracker only values portfolios that have a subscriber.
+func registerPortfolioCommands(hub *websocket.Hub, tracker *portfolio.Tracker, rateEngine *rates.Engine) {
+	type subscription struct{ portfolioID, quote string }
+
+	var mutex sync.Mutex
+	// quotes counts the subscribers of each converted quote per portfolio.
+	quotes := make(map[string]map[string]int)
+	joined := make(map[*websocket.Client]map[subscription]bool)
+
+	channel := func(portfolioID, quote string) string {
+		if quote == portfolio.Currency {
+			return "portfolio:" + portfolioID
+		}
+		return "portfolio:" + portfolioID + ":" + quote
+	}
+
+	// leaveLocked drops one subscriber and the quote once nobody wants it.
+	leaveLocked := func(sub subscription) {
+		tracker.Unwatch(sub.portfolioID)
+		if sub.quote == portfolio.Currency {
+			return
+		}
+		if quotes[sub.portfolioID][sub.quote]--; quotes[sub.portfolioID][sub.quote] <= 0 {
+			delete(quotes[sub.portfolioID], sub.quote)
+			if len(quotes[sub.portfolioID]) == 0 {
+				delete(quotes, sub.portfolioID)
+			}
+		}
+	}
+
+	tracker.SetNotifier(func(portfolioID string, message []byte) {
+		hub.SendTo(channel(portfolioID, portfolio.Currency), message)
+
+		mutex.Lock()
+		wanted := make([]string, 0, len(quotes[portfolioID]))
+		for quote := range quotes[portfolioID] {
+			wanted = append(wanted, quote)
+		}
+		mutex.Unlock()
+
+		for _, quote := range wanted {
+			var v portfolio.Valuation
+			if err := json.Unmarshal(message, &v); err != nil {
+				return
+			}
+			rate, err := rateEngine.Rate(portfolio.Currency, quote)
+			if err != nil {
+				continue
+			}
+			v.Convert(rate, quote)
+			if data, err := json.Marshal(v); err == nil {
+				hub.SendTo(channel(portfolioID, quote), data)
+			}
+		}
+	})
+
+	hub.HandleCommand("portfolio.subscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		var req struct {
+			PortfolioID string `json:"portfolioId"`
+			Token       string `json:"token"`
+			Quote       string `json:"quote"`
+		}
+		if err := json.Unmarshal(cmd.Raw, &req); err != nil {
+			return err
+		}
+		if err := tracker.Authenticate(req.PortfolioID, req.Token); err != nil {
+			return err
+		}
+
+		quote := strings.ToUpper(req.Quote)
+		if quote == "" {
+			quote = portfolio.Currency
+		}
+		v, err := portfolioValue(tracker, rateEngine, req.PortfolioID, "", quote)
+		if err != nil {
+			return err
+		}
+
+		sub := subscription{req.PortfolioID, quote}
+		mutex.Lock()
+		if joined[c] == nil {
+			joined[c] = make(map[subscription]bool)
+			c.OnClose(func() {
+				mutex.Lock()
+				defer mutex.Unlock()
+				for sub := range joined[c] {
+					leaveLocked(sub)
+				}
+				delete(joined, c)
+			})
+		}
+		if !joined[c][sub] {
+			joined[c][sub] = true
+			tracker.Watch(sub.portfolioID)
+			if quote != portfolio.Currency {
+				if quotes[sub.portfolioID] == nil {
+					quotes[sub.portfolioID] = make(map[string]int)
+				}
+				quotes[sub.portfolioID][quote]++
+			}
+		}
+		mutex.Unlock()
+		c.Join(channel(req.PortfolioID, quote))
+
+		if data, err := json.Marshal(v); err == nil {
+			c.Send(data)
+		}
+		return nil
+	})
+
+	hub.HandleCommand("portfolio.unsubscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		var req struct {
+			PortfolioID string `json:"portfolioId"`
+			Quote       string `json:"quote"`
+		}
+		if err := json.Unmarshal(cmd.Raw, &req); err != nil {
+			return err
+		}
+		quote := strings.ToUpper(req.Quote)
+		if quote == "" {
+			quote = portfolio.Currency
+		}
+		c.Leave(channel(req.PortfolioID, quote))
+
+		sub := subscription{req.PortfolioID, quote}
+		mutex.Lock()
+		if joined[c][sub] {
+			delete(joined[c], sub)
+			leaveLocked(sub)
+		}
+		mutex.Unlock()
+		return nil
+	})
+}
+
+// portfolioValue values a portfolio and converts it when quote is not USDT.
+func portfolioValue(tracker *portfolio.Tracker, rateEngine *rates.Engine, id, method, quote string) (*portfolio.Valuation, error) {
+	v, err := tracker.Value(id, method)
+	if err != nil {
+		return nil, err
+	}
+	if quote = strings.ToUpper(quote); quote != "" && quote != portfolio.Currency {
+		rate, err := rateEngine.Rate(portfolio.Currency, quote)
+		if err != nil {
+			return nil, err
+		}
+		v.Convert(rate, quote)
+	}
+	return v, nil
+}
+
+func registerRateRoutes(api *gin.RouterGroup, rateEngine *rates.Engine, snapshot *market.Snapshot) {
+	api.GET("/rates", func(c *gin.Context) {
+		from := strings.ToUpper(c.DefaultQuery("from", "BTC"))
+		to := strings.ToUpper(c.DefaultQuery("to", "USD"))
+		amount := decimal.One
+		if s := c.Query("amount"); s != "" {
+			var err error
+			if amount, err = decimal.Parse(s); err != nil {
+				c.JSON(400, gin.H{"error": "invalid amount"})
+				return
+			}
+		}
+
+		rate, err := rateEngine.Rate(from, to)
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"from": from, "to": to, "rate": rate, "amount": amount, "result": amount.Mul(rate)})
+	})
+
+	api.GET("/rates/currencies", func(c *gin.Context) {
+		c.JSON(200, gin.H{"currencies": rateEngine.Currencies()})
+	})
+
+	api.GET("/tickers", func(c *gin.Context) {
+		quote := c.Query("quote")
+		if quote != "" && !rateEngine.Supports(quote) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("unsupported quote %q", quote)})
+			return
+		}
+
+		tickers := []json.RawMessage{}
+		for _, t := range snapshot.Tickers() {
+			data, err := json.Marshal(t)
+			if err != nil {
+				continue
+			}
+			if quote != "" {
+				converted, err := rateEngine.ConvertMessage(data, quote)
+				if err != nil {
+					continue
+				}
+				data = converted
+			}
+			tickers = append(tickers, data)
+		}
+		c.JSON(200, gin.H{"tickers": tickers})
+	})
+}
+
+// registerStreamCommands lets a socket narrow the shared stream:
+// {"action":"subscribe","symbols":["BTCUSDT"],"events":["trade"],"quote":"EUR"}.
+// "unsubscribe" goes back to receiving everything.
+func registerStreamCommands(hub *websocket.Hub, rateEngine *rates.Engine) {
+	hub.HandleCommand("subscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		var req struct {
+			Symbols []string `json:"symbols"`
+			Events  []string `json:"events"`
+			Quote   string   `json:"quote"`
+		}
+		if err := json.Unmarshal(cmd.Raw, &req); err != nil {
+			return err
+		}
+		if req.Quote != "" && !rateEngine.Supports(req.Quote) {
+			return fmt.Errorf("unsupported quote %q", req.Quote)
+		}
+		c.SetSubscription(websocket.NewSubscription(req.Symbols, req.Events, req.Quote))
+		return nil
+	})
+
+	hub.HandleCommand("unsubscribe", func(c *websocket.Client, cmd websocket.Command) error {
+		c.SetSubscription(nil)
+		return nil
+	})
+}
+
+// registerAnalyticsRoutes serves cross-symbol statistics computed from
+// historical candles. symbols lists what to analyze when none are given.
+func registerAnalyticsRoutes(api *gin.RouterGroup, correlations *analytics.Correlations, risk *analytics.Risk, symbols func() []string) {
+	api.GET("/analytics/correlation", func(c *gin.Context) {
+		req := analytics.CorrelationRequest{
+			Interval:  c.DefaultQuery("interval", "1d"),
+			Benchmark: c.DefaultQuery("benchmark", "BTCUSDT"),
+		}
+		if s := c.Query("symbols"); s != "" {
+			req.Symbols = strings.Split(s, ",")
+		} else {
+			req.Symbols = firstSymbols(symbols(), analytics.MaxSymbols-1)
+		}
+		for key, dst := range map[string]*int{"window": &req.Window, "rolling": &req.Rolling} {
+			if s := c.Query(key); s != "" {
+				v, err := strconv.Atoi(s)
+				if err != nil {
+					c.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s", key)})
+					return
+				}
+				*dst = v
+			}
+		}
+
+		report, err := correlations.Compute(req)
+		if errors.Is(err, analytics.ErrUpstream) {
+			upstreamError(c, 502, err)
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, report)
+	})
+	api.GET("/analytics/risk", func(c *gin.Context) {
+		req := analytics.RiskRequest{Interval: c.Query("interval")}
+		if s := c.Query("symbols"); s != "" {
+			req.Symbols = strings.Split(s, ",")
+		} else {
+			req.Symbols = firstSymbols(symbols(), analytics.MaxSymbols)
+		}
+		if s := c.Query("window"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid window"})
+				return
+			}
+			req.Window = v
+		}
+		if s := c.Query("confidence"); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "invalid confidence"})
+				return
+			}
+			req.Confidence = v
+		}
+
+		reports, missing, err := risk.Compute(req)
+		if errors.Is(err, analytics.ErrUpstream) {
+			upstreamError(c, 502, err)
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"reports": reports, "missing": missing})
+	})
+}
+
+// firstSymbols keeps the default symbol list within a request's limit.
+func firstSymbols(symbols []string, n int) []string {
+	if len(symbols) > n {
+		return symbols[:n]
+	}
+	return symbols
+}
+
+func registerMarketRoutes(api *gin.RouterGroup, snapshot *market.Snapshot) {
+	api.GET("/market/overview", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+		if err != nil || limit < 1 {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		c.JSON(200, snapshot.Overview(limit))
+	})
+}
+
+func registerArbitrageRoutes(api *gin.RouterGroup, monitor *arbitrage.Monitor) {
+	api.GET("/arbitrage/spreads", func(c *gin.Context) {
+		cfg := monitor.Config()
+		c.JSON(200, gin.H{
+			"spreads":       monitor.Spreads(),
+			"thresholdBps":  cfg.ThresholdBps,
+			"minDurationMs": cfg.MinDuration.Milliseconds(),
+		})
+	})
+
+	api.GET("/arbitrage/opportunities", func(c *gin.Context) {
+		c.JSON(200, gin.H{"opportunities": monitor.Open()})
+	})
+
+	api.GET("/arbitrage/history", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		c.JSON(200, gin.H{"history": monitor.History(c.Query("symbol"), limit)})
+	})
+}
+
+func registerAnomalyRoutes(api *gin.RouterGroup, detector *anomaly.Detector) {
+	api.GET("/anomalies", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		since, _ := strconv.ParseInt(c.Query("since"), 10, 64)
+		c.JSON(200, gin.H{"anomalies": detector.Query(c.Query("symbol"), c.Query("type"), since, limit)})
+	})
+
+	api.GET("/anomalies/stalled", func(c *gin.Context) {
+		c.JSON(200, gin.H{"symbols": detector.Stalled()})
+	})
+}
+
+// defaultHeartbeat keeps idle SSE connections open through proxies.
+const defaultHeartbeat = 15 * time.Second
+
+// registerSSERoutes serves the broadcast stream as Server-Sent Events for
+// clients that cannot keep a WebSocket open. symbols, events and quote filter
+// like the /ws subscribe command, and a reconnecting EventSource resumes from
+// its Last-Event-ID while the hub still buffers it. A heartbeat that is not
+// positive falls back to defaultHeartbeat.
+func registerSSERoutes(api *gin.RouterGroup, hub *websocket.Hub, rateEngine *rates.Engine, limits *ratelimit.Limiter, heartbeat time.Duration) {
+	if heartbeat <= 0 {
+		log.Printf("Invalid SSE heartbeat %v, using %v", heartbeat, defaultHeartbeat)
+		heartbeat = defaultHeartbeat
+	}
+	api.GET("/stream", func(c *gin.Context) {
+		var symbols, events []string
+		if s := c.Query("symbols"); s != "" {
+			symbols = strings.Split(s, ",")
+		}
+		if s := c.Query("events"); s != "" {
+			events = strings.Split(s, ",")
+		}
+		quote := c.Query("quote")
+		if quote != "" && !rateEngine.Supports(quote) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("unsupported quote %q", quote)})
+			return
+		}
+		var sub *websocket.Subscription
+		if symbols != nil || events != nil || quote != "" {
+			sub = websocket.NewSubscription(symbols, events, quote)
+		}
+
+		release, ok := acquireSocket(c, limits)
+		if !ok {
+			return
+		}
+		defer release()
+
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("lastEventId")
+		}
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		resume := lastID != "" && err == nil
+
+		listener, backlog, missed := hub.Listen(sub, id, resume)
+		defer hub.Unlisten(listener)
+
+		// The server's write timeout would cut the stream off.
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", sse.ContentType)
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(200)
+
+		write := func(ev sse.Event) bool {
+			if err := sse.Encode(c.Writer, ev); err != nil {
+				return false
+			}
+			c.Writer.Flush()
+			return true
+		}
+
+		if missed {
+			reset, _ := json.Marshal(gin.H{"eventType": "reset", "reason": "events since Last-Event-ID are no longer buffered"})
+			if !write(sse.Event{Event: "reset", Data: reset, Retry: 3000}) {
+				return
+			}
+		} else if !write(sse.Event{Event: "open", Data: "{}", Retry: 3000}) {
+			return
+		}
+		for _, ev := range backlog {
+			if !write(sse.Event{Id: strconv.FormatUint(ev.ID, 10), Data: ev.Message}) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case ev, ok := <-listener.C:
+				if !ok {
+					// Dropped for falling behind, the client reconnects and resumes.
+					return
+				}
+				if !write(sse.Event{Id: strconv.FormatUint(ev.ID, 10), Data: ev.Message}) {
+					return
+				}
+			case t := <-ticker.C:
+				if !write(sse.Event{Event: "heartbeat", Data: strconv.FormatInt(t.UnixMilli(), 10)}) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// registerGraphQLRoutes serves queries over POST (or GET with ?query=) and
+// upgrades GET requests to graphql-transport-ws sockets for subscriptions.
+func registerGraphQLRoutes(api *gin.RouterGroup, schema *graphql.Schema, limits *ratelimit.Limiter) {
+	// Only chart fields reach Binance, each one spends an upstream token.
+	budget := func(c *gin.Context) {
+		client, tier := ratelimit.Client(auth.FromContext(c.Request.Context()), c.ClientIP())
+		spend := func() error {
+			if wait, ok := limits.Allow(client, tier, ratelimit.ClassUpstream); !ok {
+				return fmt.Errorf("upstream rate limit exceeded, retry in %s", wait.Round(time.Second))
+			}
+			return nil
+		}
+		c.Request = c.Request.WithContext(gql.WithBudget(c.Request.Context(), spend))
+	}
+	api = api.Group("", budget)
+
+	api.POST("/graphql", func(c *gin.Context) {
+		var req gql.Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "invalid request body"})
+			return
+		}
+		c.JSON(200, schema.Exec(c.Request.Context(), req.Query, req.OperationName, req.Variables))
+	})
+
+	api.GET("/graphql", func(c *gin.Context) {
+		if gorilla.IsWebSocketUpgrade(c.Request) {
+			release, ok := acquireSocket(c, limits)
+			if !ok {
+				return
+			}
+			defer release()
+			gql.ServeWS(schema, c.Writer, c.Request)
+			return
+		}
+
+		req := gql.Request{Query: c.Query("query"), OperationName: c.Query("operationName")}
+		if s := c.Query("variables"); s != "" {
+			if err := json.Unmarshal([]byte(s), &req.Variables); err != nil {
+				c.JSON(400, gin.H{"error": "invalid variables"})
+				return
+			}
+		}
+		if req.Query == "" {
+			c.JSON(400, gin.H{"error": "query is required"})
+			return
+		}
+		c.JSON(200, schema.Exec(c.Request.Context(), req.Query, req.OperationName, req.Variables))
+	})
+}
+
+// registerHealthRoutes serves the probes. /health/live only fails when the
+// process cannot answer, /health/ready answers 503 while the feed is down
+// so load balancers route around the node. /health keeps its old shape.
+func registerHealthRoutes(router *gin.Engine, monitor *health.Monitor, hub *websocket.Hub) {
+	router.GET("/health/live", func(c *gin.Context) {
+		c.JSON(200, monitor.Live())
+	})
+
+	router.GET("/health/ready", func(c *gin.Context) {
+		report := monitor.Report()
+		status := 200
+		if report.Status == health.StatusDown {
+			status = 503
+		}
+		c.JSON(status, report)
+	})
+
+	router.GET("/health", func(c *gin.Context) {
+		report := monitor.Report()
+		status := "healthy"
+		switch report.Status {
+		case health.StatusDegraded:
+			status = "degraded"
+		case health.StatusDown:
+			status = "unhealthy"
+		}
+		c.JSON(200, gin.H{
+			"Status":    status,
+			"Reasons":   report.Reasons,
+			"Clients":   hub.GetClientCount(),
+			"timestamp": time.Now().Unix(),
+		})
+	})
+}
+
+// metricsMiddleware times requests by route template. Streams are left out,
+// their duration is how long the client stayed.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if gorilla.IsWebSocketUpgrade(c.Request) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPDuration.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// corsMiddleware only serves browsers from allowed origins. With "*" any
+// origin may read responses, but not with credentials.
+func corsMiddleware(origins auth.Origins) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if !origins.CheckOrigin(c.Request) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "origin not allowed"})
+			return
+		}
+
+		if origin != "" {
+			if origins.Any() {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Paper-Token, X-Portfolio-Token, accept, origin, Cache-Control, X-Requested-With, Last-Event-ID")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authMiddleware attaches the caller's identity to the request context.
+// Wrong credentials are refused even where anonymous access is allowed, and
+// spend the IP's tokens so keys cannot be guessed at full speed.
+func authMiddleware(authn *auth.Authenticator, limits *ratelimit.Limiter) gin.HandlerFunc {
+	refuse := func(c *gin.Context, err error) {
+		client, tier := ratelimit.Client(nil, c.ClientIP())
+		if wait, ok := limits.Allow(client, tier, ratelimit.ClassRequest); !ok {
+			tooManyRequests(c, wait, "rate limit exceeded")
+			return
+		}
+		c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+	}
+
+	return func(c *gin.Context) {
+		credential, err := auth.Credential(c.Request)
+		if err != nil {
+			refuse(c, err)
+			return
+		}
+		if credential == "" {
+			c.Next()
+			return
+		}
+		identity, err := authn.Authenticate(credential)
+		if err != nil {
+			refuse(c, err)
+			return
+		}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := auth.FromContext(c.Request.Context())
+		if identity == nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+			return
+		}
+		if role == auth.RoleAdmin && !identity.IsAdmin() {
+			c.AbortWithStatusJSON(403, gin.H{"error": "admin role required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimit spends one token of class per request from the caller's
+// bucket, per API key when authenticated and per IP otherwise.
+func rateLimit(limits *ratelimit.Limiter, class string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, tier := ratelimit.Client(auth.FromContext(c.Request.Context()), c.ClientIP())
+		if wait, ok := limits.Allow(client, tier, class); !ok {
+			tooManyRequests(c, wait, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// spendUpstream takes n more upstream tokens from the caller's bucket, or
+// answers 429 when they run out.
+func spendUpstream(c *gin.Context, limits *ratelimit.Limiter, n int) bool {
+	client, tier := ratelimit.Client(auth.FromContext(c.Request.Context()), c.ClientIP())
+	for i := 0; i < n; i++ {
+		if wait, ok := limits.Allow(client, tier, ratelimit.ClassUpstream); !ok {
+			tooManyRequests(c, wait, "upstream rate limit exceeded")
+			return false
+		}
+	}
+	return true
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(429, gin.H{"error": message, "retryAfter": seconds})
+}
+
+// socketRetryAfter is a hint only, slots free up when other streams close.
+const socketRetryAfter = 5 * time.Second
+
+// acquireSocket reserves a stream slot for the caller, or answers 429 when
+// the caller holds too many and 503 when the node is full.
+func acquireSocket(c *gin.Context, limits *ratelimit.Limiter) (func(), bool) {
+	client, tier := ratelimit.Client(auth.FromContext(c.Request.Context()), c.ClientIP())
+	release, err := limits.AcquireSocket(client, tier)
+	if err == ratelimit.ErrServerFull {
+		c.Header("Retry-After", strconv.Itoa(int(socketRetryAfter.Seconds())))
+		c.AbortWithStatusJSON(503, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		tooManyRequests(c, socketRetryAfter, err.Error())
+		return nil, false
+	}
+	return release, true
+}
+
+// registerSessionRoutes trades an API key for a short-lived session token,
+// which browsers can also pass as ?access_token= to /ws and /api/stream.
+func registerSessionRoutes(public *gin.RouterGroup, authn *auth.Authenticator) {
+	public.POST("/auth/token", func(c *gin.Context) {
+		identity := auth.FromContext(c.Request.Context())
+		if identity == nil {
+			c.JSON(401, gin.H{"error": "send an API key as Authorization: Bearer or X-API-Key"})
+			return
+		}
+		// A session renewing itself would never expire.
+		if identity.Method != auth.MethodAPIKey {
+			c.JSON(403, gin.H{"error": "sessions are issued for API keys only"})
+			return
+		}
+		token, expires := authn.IssueSession(identity)
+		c.JSON(200, gin.H{"token": token, "expiresAt": expires.UnixMilli(), "user": identity})
+	})
+
+	public.GET("/auth/me", func(c *gin.Context) {
+		identity := auth.FromContext(c.Request.Context())
+		if identity == nil {
+			c.JSON(401, gin.H{"error": "not authenticated"})
+			return
+		}
+		c.JSON(200, identity)
+	})
+}
+
+func registerUserRoutes(users *gin.RouterGroup, store *auth.Store) {
+	users.GET("", func(c *gin.Context) {
+		c.JSON(200, gin.H{"users": store.Users()})
+	})
+
+	// Creating a user also creates its first API key.
+	users.POST("", func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name"`
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		user, err := store.CreateUser(req.Name, req.Role)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		key, info, err := store.CreateKey(user.ID, "default")
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		user.Keys = append(user.Keys, info)
+		c.JSON(201, gin.H{"user": user, "apiKey": key})
+	})
+
+	users.DELETE("/:id", func(c *gin.Context) {
+		err := store.DeleteUser(c.Param("id"))
+		if err == auth.ErrNotFound {
+			c.JSON(404, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(204)
+	})
+
+	users.POST("/:id/keys", func(c *gin.Context) {
+		var req struct {
+			Label string `json:"label"`
+		}
+		c.ShouldBindJSON(&req)
+		key, info, err := store.CreateKey(c.Param("id"), req.Label)
+		if err == auth.ErrNotFound {
+			c.JSON(404, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, gin.H{"key": info, "apiKey": key})
+	})
+
+	users.DELETE("/:id/keys/:keyId", func(c *gin.Context) {
+		err := store.RevokeKey(c.Param("id"), c.Param("keyId"))
+		if err == auth.ErrNotFound {
+			c.JSON(404, gin.H{"error": "key not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(204)
+	})
+}
@@ -0,0 +1,62 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// MaxCharts bounds the chart fields of one operation, aliases included.
+// Each one is a Binance REST call, plus those converting it to a quote.
+const MaxCharts = 5
+
+// budget counts the charts of one operation and charges each to the caller.
+type budget struct {
+	spend  func() error
+	charts atomic.Int32
+}
+
+type budgetKey struct{}
+
+// WithBudget makes every chart resolved under ctx call spend first, which
+// returns an error when the caller has no upstream requests left.
+func WithBudget(ctx context.Context, spend func() error) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &budget{spend: spend})
+}
+
+// perOperation gives an operation on a socket a chart count of its own.
+func perOperation(ctx context.Context) context.Context {
+	if b, ok := ctx.Value(budgetKey{}).(*budget); ok {
+		return WithBudget(ctx, b.spend)
+	}
+	return ctx
+}
+
+func chargeChart(ctx context.Context) error {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return nil
+	}
+	if b.charts.Add(1) > MaxCharts {
+		return fmt.Errorf("at most %d charts per request", MaxCharts)
+	}
+	if b.spend == nil {
+		return nil
+	}
+	return b.spend()
+}
+
+// chargeUpstream spends n more tokens for the requests a chart's quote
+// conversion makes.
+func chargeUpstream(ctx context.Context, n int) error {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok || b.spend == nil {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if err := b.spend(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
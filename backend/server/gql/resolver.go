@@ -0,0 +1,363 @@
+package gql
+
+import (
+	"context"
+	"cropto-dashboard/anomaly"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/market"
+	"cropto-dashboard/rates"
+	"cropto-dashboard/server/websocket"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Resolver is the root of both queries and subscriptions.
+type Resolver struct {
+	hub       *websocket.Hub
+	snapshot  *market.Snapshot
+	rates     *rates.Engine
+	anomalies *anomaly.Detector
+	symbols   func() []string
+}
+
+func NewResolver(hub *websocket.Hub, snapshot *market.Snapshot, rateEngine *rates.Engine, anomalies *anomaly.Detector, symbols func() []string) *Resolver {
+	return &Resolver{
+		hub:       hub,
+		snapshot:  snapshot,
+		rates:     rateEngine,
+		anomalies: anomalies,
+		symbols:   symbols,
+	}
+}
+
+type ticker struct {
+	Symbol        string
+	Price         string
+	Change        string
+	ChangePercent string
+	Volume        string
+	QuoteVolume   *string
+	High          string
+	Low           string
+	Bid           *string
+	Ask           *string
+	Timestamp     float64
+	Quote         *string
+}
+
+func newTicker(t types.TickerMessage) *ticker {
+	return &ticker{
+		Symbol:        t.Symbol,
+		Price:         t.Price.String(),
+		Change:        t.Change.String(),
+		ChangePercent: t.ChangePercent.String(),
+		Volume:        t.Volume.String(),
+		QuoteVolume:   optional(t.QuoteVolume),
+		High:          t.High.String(),
+		Low:           t.Low.String(),
+		Bid:           optional(t.Bid),
+		Ask:           optional(t.Ask),
+		Timestamp:     float64(t.Timestamp),
+		Quote:         nonEmpty(t.Quote),
+	}
+}
+
+type chart struct {
+	Symbol     string
+	Interval   string
+	Quote      *string
+	Candles    []*candle
+	Indicators *indicators
+}
+
+type candle struct {
+	OpenTime  float64
+	Open      string
+	High      string
+	Low       string
+	Close     string
+	Volume    string
+	CloseTime float64
+}
+
+type indicators struct {
+	Ma20      *[]float64
+	Ma50      *[]float64
+	Ma200     *[]float64
+	Rsi       *[]float64
+	Macd      *[]float64
+	Signal    *[]float64
+	Histogram *[]float64
+}
+
+type alert struct {
+	ID        string
+	Type      string
+	Symbol    string
+	Value     float64
+	Baseline  float64
+	Threshold float64
+	ZScore    float64
+	Price     float64
+	Message   string
+	Timestamp float64
+}
+
+func (r *Resolver) Symbols() []string {
+	return r.symbols()
+}
+
+func (r *Resolver) Ticker(args struct {
+	Symbol string
+	Quote  *string
+}) (*ticker, error) {
+	quote, err := r.quote(args.Quote)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := r.snapshot.Ticker(strings.ToUpper(args.Symbol))
+	if !ok {
+		return nil, nil
+	}
+	if quote != "" {
+		if t, err = r.convert(t, quote); err != nil {
+			return nil, err
+		}
+	}
+	return newTicker(t), nil
+}
+
+func (r *Resolver) Tickers(args struct {
+	Symbols *[]string
+	Quote   *string
+}) ([]*ticker, error) {
+	quote, err := r.quote(args.Quote)
+	if err != nil {
+		return nil, err
+	}
+	var want map[string]bool
+	if args.Symbols != nil {
+		want = make(map[string]bool)
+		for _, s := range *args.Symbols {
+			want[strings.ToUpper(s)] = true
+		}
+	}
+
+	tickers := []*ticker{}
+	for _, t := range r.snapshot.Tickers() {
+		if want != nil && !want[strings.ToUpper(t.Symbol)] {
+			continue
+		}
+		if quote != "" {
+			// Like /api/tickers, symbols without a rate path are left out.
+			if t, err = r.convert(t, quote); err != nil {
+				continue
+			}
+		}
+		tickers = append(tickers, newTicker(t))
+	}
+	return tickers, nil
+}
+
+// maxChartLimit is the most candles Binance returns in one request.
+const maxChartLimit = 1000
+
+func (r *Resolver) Chart(ctx context.Context, args struct {
+	Symbol   string
+	Interval string
+	Limit    int32
+	Quote    *string
+}) (*chart, error) {
+	quote, err := r.quote(args.Quote)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.IntervalDuration(args.Interval) <= 0 {
+		return nil, fmt.Errorf("invalid interval %q", args.Interval)
+	}
+	if args.Limit < 1 || args.Limit > maxChartLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxChartLimit)
+	}
+	if err := chargeChart(ctx); err != nil {
+		return nil, err
+	}
+	if quote != "" {
+		if err := chargeUpstream(ctx, r.rates.HistoryCalls(args.Symbol, quote)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := exchange.GetHistoricalData(strings.ToUpper(args.Symbol), args.Interval, int(args.Limit))
+	if err != nil {
+		return nil, err
+	}
+	if quote != "" {
+		if err := r.rates.ConvertChart(data, quote); err != nil {
+			return nil, err
+		}
+	}
+	if graphql.HasSelectedField(ctx, "indicators") {
+		exchange.AddIndicators(data)
+	}
+
+	out := &chart{
+		Symbol:   data.Symbol,
+		Interval: data.Interval,
+		Quote:    nonEmpty(data.Quote),
+		Candles:  make([]*candle, len(data.Candlesticks)),
+		Indicators: &indicators{
+			Ma20:      series(data.Indicators.MA20),
+			Ma50:      series(data.Indicators.MA50),
+			Ma200:     series(data.Indicators.MA200),
+			Rsi:       series(data.Indicators.RSI),
+			Macd:      series(data.Indicators.MACD),
+			Signal:    series(data.Indicators.Signal),
+			Histogram: series(data.Indicators.Histogram),
+		},
+	}
+	for i, c := range data.Candlesticks {
+		out.Candles[i] = &candle{
+			OpenTime:  float64(c.OpenTime),
+			Open:      c.Open.String(),
+			High:      c.High.String(),
+			Low:       c.Low.String(),
+			Close:     c.Close.String(),
+			Volume:    c.Volume.String(),
+			CloseTime: float64(c.CloseTime),
+		}
+	}
+	return out, nil
+}
+
+func (r *Resolver) Alerts(args struct {
+	Symbol *string
+	Type   *string
+	Since  *float64
+	Limit  int32
+}) ([]*alert, error) {
+	if args.Limit < 1 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+	var symbol, kind string
+	var since int64
+	if args.Symbol != nil {
+		symbol = *args.Symbol
+	}
+	if args.Type != nil {
+		kind = *args.Type
+	}
+	if args.Since != nil {
+		since = int64(*args.Since)
+	}
+
+	alerts := []*alert{}
+	for _, ev := range r.anomalies.Query(symbol, kind, since, int(args.Limit)) {
+		alerts = append(alerts, &alert{
+			ID:        ev.ID,
+			Type:      ev.Type,
+			Symbol:    ev.Symbol,
+			Value:     ev.Value,
+			Baseline:  ev.Baseline,
+			Threshold: ev.Threshold,
+			ZScore:    ev.ZScore,
+			Price:     ev.Price,
+			Message:   ev.Message,
+			Timestamp: float64(ev.Timestamp),
+		})
+	}
+	return alerts, nil
+}
+
+// TickerUpdates follows the hub as a listener, the same fan-out /ws and
+// /api/stream use, until the subscription is stopped.
+func (r *Resolver) TickerUpdates(ctx context.Context, args struct {
+	Symbols *[]string
+	Quote   *string
+}) (<-chan *ticker, error) {
+	quote, err := r.quote(args.Quote)
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	if args.Symbols != nil {
+		symbols = *args.Symbols
+	}
+
+	listener, _, _ := r.hub.Listen(websocket.NewSubscription(symbols, []string{"ticker"}, quote), 0, false)
+	out := make(chan *ticker)
+	go func() {
+		defer close(out)
+		defer r.hub.Unlisten(listener)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-listener.C:
+				if !ok {
+					return
+				}
+				var msg types.TickerMessage
+				if err := json.Unmarshal(ev.Message, &msg); err != nil {
+					continue
+				}
+				select {
+				case out <- newTicker(msg):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *Resolver) quote(quote *string) (string, error) {
+	if quote == nil || *quote == "" {
+		return "", nil
+	}
+	if !r.rates.Supports(*quote) {
+		return "", fmt.Errorf("unsupported quote %q", *quote)
+	}
+	return strings.ToUpper(*quote), nil
+}
+
+func (r *Resolver) convert(t types.TickerMessage, quote string) (types.TickerMessage, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return t, err
+	}
+	converted, err := r.rates.ConvertMessage(data, quote)
+	if err != nil {
+		return t, err
+	}
+	var out types.TickerMessage
+	err = json.Unmarshal(converted, &out)
+	return out, err
+}
+
+func optional(d decimal.Decimal) *string {
+	if d.IsZero() {
+		return nil
+	}
+	s := d.String()
+	return &s
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func series(values []float64) *[]float64 {
+	if values == nil {
+		return nil
+	}
+	return &values
+}
@@ -0,0 +1,138 @@
+package gql
+
+import (
+	"context"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/rates"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func fakeKlines(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[[1700000000000,"100","101","99","100.5","10",1700003599999,"1005",5,"5","502","0"]]`)
+	}))
+	t.Cleanup(srv.Close)
+	restURL := exchange.BinanceRESTURL
+	exchange.BinanceRESTURL = srv.URL
+	t.Cleanup(func() { exchange.BinanceRESTURL = restURL })
+	return &calls
+}
+
+func TestChartsAreCappedAndCharged(t *testing.T) {
+	calls := fakeKlines(t)
+	schema := NewSchema(NewResolver(nil, nil, nil, nil, nil))
+
+	var fields []string
+	for i := 0; i <= MaxCharts; i++ {
+		fields = append(fields, fmt.Sprintf(`c%d: chart(symbol: "BTCUSDT", limit: 1) { symbol }`, i))
+	}
+	var spent atomic.Int32
+	ctx := WithBudget(context.Background(), func() error {
+		spent.Add(1)
+		return nil
+	})
+
+	result := schema.Exec(ctx, "{ "+strings.Join(fields, " ")+" }", "", nil)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "charts per request") {
+		t.Fatalf("errors = %v, want one over the chart limit", result.Errors)
+	}
+	// Identical charts may share one cached request, each still costs a token.
+	if spent.Load() != MaxCharts || calls.Load() == 0 {
+		t.Errorf("spent %d tokens for %d requests, want %d tokens", spent.Load(), calls.Load(), MaxCharts)
+	}
+}
+
+func TestChartSpendErrorStopsTheRequest(t *testing.T) {
+	calls := fakeKlines(t)
+	schema := NewSchema(NewResolver(nil, nil, nil, nil, nil))
+	ctx := WithBudget(context.Background(), func() error {
+		return fmt.Errorf("upstream rate limit exceeded")
+	})
+
+	result := schema.Exec(ctx, `{ chart(symbol: "BTCUSDT") { symbol } }`, "", nil)
+	if len(result.Errors) != 1 || calls.Load() != 0 {
+		t.Fatalf("errors = %v after %d requests, want the chart refused", result.Errors, calls.Load())
+	}
+}
+
+func TestChartLimitIsValidated(t *testing.T) {
+	calls := fakeKlines(t)
+	schema := NewSchema(NewResolver(nil, nil, nil, nil, nil))
+
+	for _, limit := range []int{0, -5, 1001} {
+		query := fmt.Sprintf(`{ chart(symbol: "BTCUSDT", limit: %d) { symbol } }`, limit)
+		if result := schema.Exec(context.Background(), query, "", nil); len(result.Errors) == 0 {
+			t.Errorf("limit %d was accepted", limit)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("made %d upstream requests for invalid limits", calls.Load())
+	}
+}
+
+func TestChartIndicatorsFollowTheQuote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("symbol") != "BTCUSDT" {
+			w.WriteHeader(400)
+			fmt.Fprint(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+			return
+		}
+		var candles []string
+		for i := 0; i < 20; i++ {
+			open := int64(1700000000000) + int64(i)*3600000
+			candles = append(candles, fmt.Sprintf(`[%d,"100","200","50","%d","1",%d,"1",1,"1","1","0"]`, open, 100+i, open+3599999))
+		}
+		fmt.Fprint(w, "["+strings.Join(candles, ",")+"]")
+	}))
+	defer srv.Close()
+	restURL := exchange.BinanceRESTURL
+	exchange.BinanceRESTURL = srv.URL
+	defer func() { exchange.BinanceRESTURL = restURL }()
+
+	schema := NewSchema(NewResolver(nil, nil, rates.NewEngine(rates.DefaultFX), nil, nil))
+	var spent atomic.Int32
+	ctx := WithBudget(context.Background(), func() error {
+		spent.Add(1)
+		return nil
+	})
+	result := schema.Exec(ctx, `{ chart(symbol: "BTCUSDT", interval: "1h", limit: 20, quote: "EUR") { candles { close } indicators { ma20 rsi } } }`, "", nil)
+	if len(result.Errors) != 0 {
+		t.Fatal(result.Errors)
+	}
+	var out struct {
+		Chart struct {
+			Candles    []struct{ Close string }
+			Indicators struct {
+				Ma20 []float64
+				Rsi  []float64
+			}
+		}
+	}
+	if err := json.Unmarshal(result.Data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closes 100..119 USDT average 109.5, at 0.92 EUR a dollar that is 100.74.
+	ma := out.Chart.Indicators.Ma20
+	if len(ma) != 20 || math.Abs(ma[19]-100.74) > 1e-6 {
+		t.Fatalf("ma20 = %v, want 100.74 in EUR (closes %v)", ma, out.Chart.Candles)
+	}
+	// The chart, then EURUSDT and USDTEUR looking for historical rates.
+	if spent.Load() != 3 {
+		t.Errorf("spent %d tokens, want 3", spent.Load())
+	}
+	if rsi := out.Chart.Indicators.Rsi; len(rsi) != 20 || rsi[19] != 100 {
+		t.Errorf("rsi = %v, want 100 for closes that only rise", rsi)
+	}
+}
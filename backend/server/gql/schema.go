@@ -0,0 +1,99 @@
+// Package gql serves the dashboard data as one GraphQL schema so a frontend
+// can load a whole page in a single request and follow tickers over a
+// graphql-transport-ws socket.
+package gql
+
+import graphql "github.com/graph-gophers/graphql-go"
+
+// Timestamps are Unix milliseconds. They are Float because GraphQL's Int is
+// 32 bits; doubles hold them exactly. Prices stay decimal strings as in the
+// REST API.
+const Schema = `
+schema {
+	query: Query
+	subscription: Subscription
+}
+
+type Query {
+	"Symbols streamed from the exchange."
+	symbols: [String!]!
+	"Latest ticker of a symbol, null until one has arrived."
+	ticker(symbol: String!, quote: String): Ticker
+	"Latest tickers, every symbol when none are given."
+	tickers(symbols: [String!], quote: String): [Ticker!]!
+	"Historical candles. Indicators are only computed when selected."
+	chart(symbol: String!, interval: String = "1h", limit: Int = 100, quote: String): Chart!
+	"Anomaly alerts, newest first."
+	alerts(symbol: String, type: String, since: Float, limit: Int = 100): [Alert!]!
+}
+
+type Subscription {
+	"Live tickers from the hub stream."
+	tickerUpdates(symbols: [String!], quote: String): Ticker!
+}
+
+type Ticker {
+	symbol: String!
+	price: String!
+	change: String!
+	changePercent: String!
+	volume: String!
+	quoteVolume: String
+	high: String!
+	low: String!
+	bid: String
+	ask: String
+	timestamp: Float!
+	"Set when prices were converted out of the symbol's own quote asset."
+	quote: String
+}
+
+type Chart {
+	symbol: String!
+	interval: String!
+	quote: String
+	candles: [Candle!]!
+	indicators: Indicators!
+}
+
+type Candle {
+	openTime: Float!
+	open: String!
+	high: String!
+	low: String!
+	close: String!
+	volume: String!
+	closeTime: Float!
+}
+
+"Series aligned with the candles, null when there are too few candles."
+type Indicators {
+	ma20: [Float!]
+	ma50: [Float!]
+	ma200: [Float!]
+	rsi: [Float!]
+	macd: [Float!]
+	signal: [Float!]
+	histogram: [Float!]
+}
+
+type Alert {
+	id: String!
+	type: String!
+	symbol: String!
+	value: Float!
+	baseline: Float!
+	threshold: Float!
+	zScore: Float!
+	price: Float!
+	message: String!
+	timestamp: Float!
+}
+`
+
+func NewSchema(r *Resolver) *graphql.Schema {
+	return graphql.MustParseSchema(Schema, r,
+		graphql.UseFieldResolvers(),
+		graphql.MaxDepth(6),
+	)
+}
@@ -0,0 +1,236 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Subprotocol is the graphql-transport-ws protocol spoken by graphql-ws and
+// recent Apollo clients.
+const Subprotocol = "graphql-transport-ws"
+
+const (
+	writeWait      = 10 * time.Second
+	initWait       = 10 * time.Second
+	maxMessageSize = 64 << 10
+)
+
+// PongWait is how long a subscription socket may stay silent before it is
+// closed.
+var PongWait = 60 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{Subprotocol},
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// SetCheckOrigin replaces the upgrader's origin check. It must be called
+// before serving.
+func SetCheckOrigin(fn func(r *http.Request) bool) {
+	upgrader.CheckOrigin = fn
+}
+
+// Request is a GraphQL operation as sent over HTTP or in a subscribe message.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type conn struct {
+	ws     *websocket.Conn
+	schema *graphql.Schema
+
+	writeMutex sync.Mutex
+
+	mutex  sync.Mutex
+	ops    map[string]context.CancelFunc
+	closed bool
+}
+
+// ServeWS runs one graphql-transport-ws connection. Queries are answered
+// with a single result, subscriptions stream until the client completes
+// them, the socket closes or r's context ends.
+func ServeWS(schema *graphql.Schema, w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("GraphQL WebSocket upgrade failed: %v", err)
+		return
+	}
+	if ws.Subprotocol() != Subprotocol {
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4406, "Subprotocol not acceptable"), time.Now().Add(writeWait))
+		ws.Close()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &conn{ws: ws, schema: schema, ops: make(map[string]context.CancelFunc)}
+	defer func() {
+		cancel()
+		c.mutex.Lock()
+		c.closed = true
+		c.mutex.Unlock()
+		ws.Close()
+	}()
+
+	// The server shutting down ends the request context; unblock the reader.
+	go func() {
+		<-ctx.Done()
+		ws.Close()
+	}()
+	go c.pingLoop(ctx)
+
+	ws.SetReadLimit(maxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(initWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(PongWait))
+		return nil
+	})
+
+	initialized := false
+	for {
+		var msg message
+		if err := ws.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.closeWith(4400, "Invalid message")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			if initialized {
+				c.closeWith(4429, "Too many initialisation requests")
+				return
+			}
+			initialized = true
+			ws.SetReadDeadline(time.Now().Add(PongWait))
+			c.send(message{Type: "connection_ack"})
+		case "ping":
+			c.send(message{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !initialized {
+				c.closeWith(4401, "Unauthorized")
+				return
+			}
+			var req Request
+			if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.ID == "" {
+				c.closeWith(4400, "Invalid subscribe message")
+				return
+			}
+			if !c.start(ctx, msg.ID, req) {
+				c.closeWith(4409, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+		case "complete":
+			c.stop(msg.ID)
+		default:
+			c.closeWith(4400, "Unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+func (c *conn) start(ctx context.Context, id string, req Request) bool {
+	c.mutex.Lock()
+	if _, ok := c.ops[id]; ok {
+		c.mutex.Unlock()
+		return false
+	}
+	opCtx, cancel := context.WithCancel(perOperation(ctx))
+	c.ops[id] = cancel
+	c.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+		results, err := c.schema.Subscribe(opCtx, req.Query, req.OperationName, req.Variables)
+		if err != nil {
+			errs, _ := json.Marshal([]map[string]string{{"message": err.Error()}})
+			c.send(message{ID: id, Type: "error", Payload: errs})
+			c.finish(id, false)
+			return
+		}
+		for result := range results {
+			payload, err := json.Marshal(result)
+			if err != nil {
+				continue
+			}
+			c.send(message{ID: id, Type: "next", Payload: payload})
+		}
+		c.finish(id, true)
+	}()
+	return true
+}
+
+// finish forgets an operation and, unless the client stopped it, tells the
+// client it is complete.
+func (c *conn) finish(id string, notify bool) {
+	c.mutex.Lock()
+	_, active := c.ops[id]
+	delete(c.ops, id)
+	closed := c.closed
+	c.mutex.Unlock()
+
+	if notify && active && !closed {
+		c.send(message{ID: id, Type: "complete"})
+	}
+}
+
+func (c *conn) stop(id string) {
+	c.mutex.Lock()
+	cancel, ok := c.ops[id]
+	delete(c.ops, id)
+	c.mutex.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (c *conn) send(msg message) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.ws.WriteJSON(msg); err != nil {
+		c.ws.Close()
+	}
+}
+
+func (c *conn) closeWith(code int, reason string) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+}
+
+func (c *conn) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(PongWait * 9 / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.writeMutex.Lock()
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			c.writeMutex.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
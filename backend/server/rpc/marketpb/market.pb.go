@@ -0,0 +1,917 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: server/rpc/marketpb/market.proto
+
+package marketpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SubscribeRequest filters the stream. Empty lists match everything.
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbols       []string               `protobuf:"bytes,1,rep,name=symbols,proto3" json:"symbols,omitempty"`
+	Events        []string               `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetSymbols() []string {
+	if x != nil {
+		return x.Symbols
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetEvents() []string {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// Prices and volumes are decimal strings so no precision is lost.
+type Ticker struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Price         string                 `protobuf:"bytes,2,opt,name=price,proto3" json:"price,omitempty"`
+	Change        string                 `protobuf:"bytes,3,opt,name=change,proto3" json:"change,omitempty"`
+	ChangePercent string                 `protobuf:"bytes,4,opt,name=change_percent,json=changePercent,proto3" json:"change_percent,omitempty"`
+	Volume        string                 `protobuf:"bytes,5,opt,name=volume,proto3" json:"volume,omitempty"`
+	QuoteVolume   string                 `protobuf:"bytes,6,opt,name=quote_volume,json=quoteVolume,proto3" json:"quote_volume,omitempty"`
+	High          string                 `protobuf:"bytes,7,opt,name=high,proto3" json:"high,omitempty"`
+	Low           string                 `protobuf:"bytes,8,opt,name=low,proto3" json:"low,omitempty"`
+	Bid           string                 `protobuf:"bytes,9,opt,name=bid,proto3" json:"bid,omitempty"`
+	Ask           string                 `protobuf:"bytes,10,opt,name=ask,proto3" json:"ask,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,11,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ticker) Reset() {
+	*x = Ticker{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ticker) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ticker) ProtoMessage() {}
+
+func (x *Ticker) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ticker.ProtoReflect.Descriptor instead.
+func (*Ticker) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Ticker) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Ticker) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Ticker) GetChange() string {
+	if x != nil {
+		return x.Change
+	}
+	return ""
+}
+
+func (x *Ticker) GetChangePercent() string {
+	if x != nil {
+		return x.ChangePercent
+	}
+	return ""
+}
+
+func (x *Ticker) GetVolume() string {
+	if x != nil {
+		return x.Volume
+	}
+	return ""
+}
+
+func (x *Ticker) GetQuoteVolume() string {
+	if x != nil {
+		return x.QuoteVolume
+	}
+	return ""
+}
+
+func (x *Ticker) GetHigh() string {
+	if x != nil {
+		return x.High
+	}
+	return ""
+}
+
+func (x *Ticker) GetLow() string {
+	if x != nil {
+		return x.Low
+	}
+	return ""
+}
+
+func (x *Ticker) GetBid() string {
+	if x != nil {
+		return x.Bid
+	}
+	return ""
+}
+
+func (x *Ticker) GetAsk() string {
+	if x != nil {
+		return x.Ask
+	}
+	return ""
+}
+
+func (x *Ticker) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type Trade struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Price         string                 `protobuf:"bytes,2,opt,name=price,proto3" json:"price,omitempty"`
+	Quantity      string                 `protobuf:"bytes,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	TradeId       int64                  `protobuf:"varint,4,opt,name=trade_id,json=tradeId,proto3" json:"trade_id,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Backfilled    bool                   `protobuf:"varint,6,opt,name=backfilled,proto3" json:"backfilled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trade) Reset() {
+	*x = Trade{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trade) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trade) ProtoMessage() {}
+
+func (x *Trade) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trade.ProtoReflect.Descriptor instead.
+func (*Trade) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Trade) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Trade) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Trade) GetQuantity() string {
+	if x != nil {
+		return x.Quantity
+	}
+	return ""
+}
+
+func (x *Trade) GetTradeId() int64 {
+	if x != nil {
+		return x.TradeId
+	}
+	return 0
+}
+
+func (x *Trade) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Trade) GetBackfilled() bool {
+	if x != nil {
+		return x.Backfilled
+	}
+	return false
+}
+
+type Candle struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Interval      string                 `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	OpenTime      int64                  `protobuf:"varint,3,opt,name=open_time,json=openTime,proto3" json:"open_time,omitempty"`
+	Open          string                 `protobuf:"bytes,4,opt,name=open,proto3" json:"open,omitempty"`
+	High          string                 `protobuf:"bytes,5,opt,name=high,proto3" json:"high,omitempty"`
+	Low           string                 `protobuf:"bytes,6,opt,name=low,proto3" json:"low,omitempty"`
+	Close         string                 `protobuf:"bytes,7,opt,name=close,proto3" json:"close,omitempty"`
+	Volume        string                 `protobuf:"bytes,8,opt,name=volume,proto3" json:"volume,omitempty"`
+	CloseTime     int64                  `protobuf:"varint,9,opt,name=close_time,json=closeTime,proto3" json:"close_time,omitempty"`
+	Backfilled    bool                   `protobuf:"varint,10,opt,name=backfilled,proto3" json:"backfilled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Candle) Reset() {
+	*x = Candle{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Candle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Candle) ProtoMessage() {}
+
+func (x *Candle) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Candle.ProtoReflect.Descriptor instead.
+func (*Candle) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Candle) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Candle) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *Candle) GetOpenTime() int64 {
+	if x != nil {
+		return x.OpenTime
+	}
+	return 0
+}
+
+func (x *Candle) GetOpen() string {
+	if x != nil {
+		return x.Open
+	}
+	return ""
+}
+
+func (x *Candle) GetHigh() string {
+	if x != nil {
+		return x.High
+	}
+	return ""
+}
+
+func (x *Candle) GetLow() string {
+	if x != nil {
+		return x.Low
+	}
+	return ""
+}
+
+func (x *Candle) GetClose() string {
+	if x != nil {
+		return x.Close
+	}
+	return ""
+}
+
+func (x *Candle) GetVolume() string {
+	if x != nil {
+		return x.Volume
+	}
+	return ""
+}
+
+func (x *Candle) GetCloseTime() int64 {
+	if x != nil {
+		return x.CloseTime
+	}
+	return 0
+}
+
+func (x *Candle) GetBackfilled() bool {
+	if x != nil {
+		return x.Backfilled
+	}
+	return false
+}
+
+// Indicators are aligned with the candles, zero where a series is not yet
+// defined.
+type Indicators struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ma20          []float64              `protobuf:"fixed64,1,rep,packed,name=ma20,proto3" json:"ma20,omitempty"`
+	Ma50          []float64              `protobuf:"fixed64,2,rep,packed,name=ma50,proto3" json:"ma50,omitempty"`
+	Ma200         []float64              `protobuf:"fixed64,3,rep,packed,name=ma200,proto3" json:"ma200,omitempty"`
+	Rsi           []float64              `protobuf:"fixed64,4,rep,packed,name=rsi,proto3" json:"rsi,omitempty"`
+	Macd          []float64              `protobuf:"fixed64,5,rep,packed,name=macd,proto3" json:"macd,omitempty"`
+	Signal        []float64              `protobuf:"fixed64,6,rep,packed,name=signal,proto3" json:"signal,omitempty"`
+	Histogram     []float64              `protobuf:"fixed64,7,rep,packed,name=histogram,proto3" json:"histogram,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Indicators) Reset() {
+	*x = Indicators{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Indicators) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Indicators) ProtoMessage() {}
+
+func (x *Indicators) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Indicators.ProtoReflect.Descriptor instead.
+func (*Indicators) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Indicators) GetMa20() []float64 {
+	if x != nil {
+		return x.Ma20
+	}
+	return nil
+}
+
+func (x *Indicators) GetMa50() []float64 {
+	if x != nil {
+		return x.Ma50
+	}
+	return nil
+}
+
+func (x *Indicators) GetMa200() []float64 {
+	if x != nil {
+		return x.Ma200
+	}
+	return nil
+}
+
+func (x *Indicators) GetRsi() []float64 {
+	if x != nil {
+		return x.Rsi
+	}
+	return nil
+}
+
+func (x *Indicators) GetMacd() []float64 {
+	if x != nil {
+		return x.Macd
+	}
+	return nil
+}
+
+func (x *Indicators) GetSignal() []float64 {
+	if x != nil {
+		return x.Signal
+	}
+	return nil
+}
+
+func (x *Indicators) GetHistogram() []float64 {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+// MarketEvent is one hub broadcast. Tickers, trades and candles are typed,
+// every other event type (anomalies, arbitrage, overview, ...) is passed on
+// as the JSON the WebSocket clients receive.
+type MarketEvent struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	EventType string                 `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*MarketEvent_Ticker
+	//	*MarketEvent_Trade
+	//	*MarketEvent_Candle
+	//	*MarketEvent_Json
+	Payload       isMarketEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarketEvent) Reset() {
+	*x = MarketEvent{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarketEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarketEvent) ProtoMessage() {}
+
+func (x *MarketEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarketEvent.ProtoReflect.Descriptor instead.
+func (*MarketEvent) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MarketEvent) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *MarketEvent) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *MarketEvent) GetPayload() isMarketEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *MarketEvent) GetTicker() *Ticker {
+	if x != nil {
+		if x, ok := x.Payload.(*MarketEvent_Ticker); ok {
+			return x.Ticker
+		}
+	}
+	return nil
+}
+
+func (x *MarketEvent) GetTrade() *Trade {
+	if x != nil {
+		if x, ok := x.Payload.(*MarketEvent_Trade); ok {
+			return x.Trade
+		}
+	}
+	return nil
+}
+
+func (x *MarketEvent) GetCandle() *Candle {
+	if x != nil {
+		if x, ok := x.Payload.(*MarketEvent_Candle); ok {
+			return x.Candle
+		}
+	}
+	return nil
+}
+
+func (x *MarketEvent) GetJson() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*MarketEvent_Json); ok {
+			return x.Json
+		}
+	}
+	return nil
+}
+
+type isMarketEvent_Payload interface {
+	isMarketEvent_Payload()
+}
+
+type MarketEvent_Ticker struct {
+	Ticker *Ticker `protobuf:"bytes,3,opt,name=ticker,proto3,oneof"`
+}
+
+type MarketEvent_Trade struct {
+	Trade *Trade `protobuf:"bytes,4,opt,name=trade,proto3,oneof"`
+}
+
+type MarketEvent_Candle struct {
+	Candle *Candle `protobuf:"bytes,5,opt,name=candle,proto3,oneof"`
+}
+
+type MarketEvent_Json struct {
+	Json []byte `protobuf:"bytes,6,opt,name=json,proto3,oneof"`
+}
+
+func (*MarketEvent_Ticker) isMarketEvent_Payload() {}
+
+func (*MarketEvent_Trade) isMarketEvent_Payload() {}
+
+func (*MarketEvent_Candle) isMarketEvent_Payload() {}
+
+func (*MarketEvent_Json) isMarketEvent_Payload() {}
+
+type GetCandlesRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Symbol string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	// interval defaults to 1h and limit to 100, as on /api/chart.
+	Interval      string `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	Limit         int32  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Indicators    bool   `protobuf:"varint,4,opt,name=indicators,proto3" json:"indicators,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCandlesRequest) Reset() {
+	*x = GetCandlesRequest{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCandlesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCandlesRequest) ProtoMessage() {}
+
+func (x *GetCandlesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCandlesRequest.ProtoReflect.Descriptor instead.
+func (*GetCandlesRequest) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetCandlesRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *GetCandlesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetCandlesRequest) GetIndicators() bool {
+	if x != nil {
+		return x.Indicators
+	}
+	return false
+}
+
+type GetCandlesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Interval      string                 `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	Candles       []*Candle              `protobuf:"bytes,3,rep,name=candles,proto3" json:"candles,omitempty"`
+	Indicators    *Indicators            `protobuf:"bytes,4,opt,name=indicators,proto3" json:"indicators,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCandlesResponse) Reset() {
+	*x = GetCandlesResponse{}
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCandlesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCandlesResponse) ProtoMessage() {}
+
+func (x *GetCandlesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rpc_marketpb_market_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCandlesResponse.ProtoReflect.Descriptor instead.
+func (*GetCandlesResponse) Descriptor() ([]byte, []int) {
+	return file_server_rpc_marketpb_market_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetCandlesResponse) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *GetCandlesResponse) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *GetCandlesResponse) GetCandles() []*Candle {
+	if x != nil {
+		return x.Candles
+	}
+	return nil
+}
+
+func (x *GetCandlesResponse) GetIndicators() *Indicators {
+	if x != nil {
+		return x.Indicators
+	}
+	return nil
+}
+
+var File_server_rpc_marketpb_market_proto protoreflect.FileDescriptor
+
+const file_server_rpc_marketpb_market_proto_rawDesc = "" +
+	"\n" +
+	" server/rpc/marketpb/market.proto\x12\x10cropto.market.v1\"D\n" +
+	"\x10SubscribeRequest\x12\x18\n" +
+	"\asymbols\x18\x01 \x03(\tR\asymbols\x12\x16\n" +
+	"\x06events\x18\x02 \x03(\tR\x06events\"\x98\x02\n" +
+	"\x06Ticker\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\tR\x05price\x12\x16\n" +
+	"\x06change\x18\x03 \x01(\tR\x06change\x12%\n" +
+	"\x0echange_percent\x18\x04 \x01(\tR\rchangePercent\x12\x16\n" +
+	"\x06volume\x18\x05 \x01(\tR\x06volume\x12!\n" +
+	"\fquote_volume\x18\x06 \x01(\tR\vquoteVolume\x12\x12\n" +
+	"\x04high\x18\a \x01(\tR\x04high\x12\x10\n" +
+	"\x03low\x18\b \x01(\tR\x03low\x12\x10\n" +
+	"\x03bid\x18\t \x01(\tR\x03bid\x12\x10\n" +
+	"\x03ask\x18\n" +
+	" \x01(\tR\x03ask\x12\x1c\n" +
+	"\ttimestamp\x18\v \x01(\x03R\ttimestamp\"\xaa\x01\n" +
+	"\x05Trade\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\tR\x05price\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\tR\bquantity\x12\x19\n" +
+	"\btrade_id\x18\x04 \x01(\x03R\atradeId\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x1e\n" +
+	"\n" +
+	"backfilled\x18\x06 \x01(\bR\n" +
+	"backfilled\"\x80\x02\n" +
+	"\x06Candle\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x1a\n" +
+	"\binterval\x18\x02 \x01(\tR\binterval\x12\x1b\n" +
+	"\topen_time\x18\x03 \x01(\x03R\bopenTime\x12\x12\n" +
+	"\x04open\x18\x04 \x01(\tR\x04open\x12\x12\n" +
+	"\x04high\x18\x05 \x01(\tR\x04high\x12\x10\n" +
+	"\x03low\x18\x06 \x01(\tR\x03low\x12\x14\n" +
+	"\x05close\x18\a \x01(\tR\x05close\x12\x16\n" +
+	"\x06volume\x18\b \x01(\tR\x06volume\x12\x1d\n" +
+	"\n" +
+	"close_time\x18\t \x01(\x03R\tcloseTime\x12\x1e\n" +
+	"\n" +
+	"backfilled\x18\n" +
+	" \x01(\bR\n" +
+	"backfilled\"\xa6\x01\n" +
+	"\n" +
+	"Indicators\x12\x12\n" +
+	"\x04ma20\x18\x01 \x03(\x01R\x04ma20\x12\x12\n" +
+	"\x04ma50\x18\x02 \x03(\x01R\x04ma50\x12\x14\n" +
+	"\x05ma200\x18\x03 \x03(\x01R\x05ma200\x12\x10\n" +
+	"\x03rsi\x18\x04 \x03(\x01R\x03rsi\x12\x12\n" +
+	"\x04macd\x18\x05 \x03(\x01R\x04macd\x12\x16\n" +
+	"\x06signal\x18\x06 \x03(\x01R\x06signal\x12\x1c\n" +
+	"\thistogram\x18\a \x03(\x01R\thistogram\"\xf6\x01\n" +
+	"\vMarketEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventType\x122\n" +
+	"\x06ticker\x18\x03 \x01(\v2\x18.cropto.market.v1.TickerH\x00R\x06ticker\x12/\n" +
+	"\x05trade\x18\x04 \x01(\v2\x17.cropto.market.v1.TradeH\x00R\x05trade\x122\n" +
+	"\x06candle\x18\x05 \x01(\v2\x18.cropto.market.v1.CandleH\x00R\x06candle\x12\x14\n" +
+	"\x04json\x18\x06 \x01(\fH\x00R\x04jsonB\t\n" +
+	"\apayload\"}\n" +
+	"\x11GetCandlesRequest\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x1a\n" +
+	"\binterval\x18\x02 \x01(\tR\binterval\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x1e\n" +
+	"\n" +
+	"indicators\x18\x04 \x01(\bR\n" +
+	"indicators\"\xba\x01\n" +
+	"\x12GetCandlesResponse\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x1a\n" +
+	"\binterval\x18\x02 \x01(\tR\binterval\x122\n" +
+	"\acandles\x18\x03 \x03(\v2\x18.cropto.market.v1.CandleR\acandles\x12<\n" +
+	"\n" +
+	"indicators\x18\x04 \x01(\v2\x1c.cropto.market.v1.IndicatorsR\n" +
+	"indicators2\xb7\x01\n" +
+	"\n" +
+	"MarketData\x12P\n" +
+	"\tSubscribe\x12\".cropto.market.v1.SubscribeRequest\x1a\x1d.cropto.market.v1.MarketEvent0\x01\x12W\n" +
+	"\n" +
+	"GetCandles\x12#.cropto.market.v1.GetCandlesRequest\x1a$.cropto.market.v1.GetCandlesResponseB&Z$cropto-dashboard/server/rpc/marketpbb\x06proto3"
+
+var (
+	file_server_rpc_marketpb_market_proto_rawDescOnce sync.Once
+	file_server_rpc_marketpb_market_proto_rawDescData []byte
+)
+
+func file_server_rpc_marketpb_market_proto_rawDescGZIP() []byte {
+	file_server_rpc_marketpb_market_proto_rawDescOnce.Do(func() {
+		file_server_rpc_marketpb_market_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_server_rpc_marketpb_market_proto_rawDesc), len(file_server_rpc_marketpb_market_proto_rawDesc)))
+	})
+	return file_server_rpc_marketpb_market_proto_rawDescData
+}
+
+var file_server_rpc_marketpb_market_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_server_rpc_marketpb_market_proto_goTypes = []any{
+	(*SubscribeRequest)(nil),   // 0: cropto.market.v1.SubscribeRequest
+	(*Ticker)(nil),             // 1: cropto.market.v1.Ticker
+	(*Trade)(nil),              // 2: cropto.market.v1.Trade
+	(*Candle)(nil),             // 3: cropto.market.v1.Candle
+	(*Indicators)(nil),         // 4: cropto.market.v1.Indicators
+	(*MarketEvent)(nil),        // 5: cropto.market.v1.MarketEvent
+	(*GetCandlesRequest)(nil),  // 6: cropto.market.v1.GetCandlesRequest
+	(*GetCandlesResponse)(nil), // 7: cropto.market.v1.GetCandlesResponse
+}
+var file_server_rpc_marketpb_market_proto_depIdxs = []int32{
+	1, // 0: cropto.market.v1.MarketEvent.ticker:type_name -> cropto.market.v1.Ticker
+	2, // 1: cropto.market.v1.MarketEvent.trade:type_name -> cropto.market.v1.Trade
+	3, // 2: cropto.market.v1.MarketEvent.candle:type_name -> cropto.market.v1.Candle
+	3, // 3: cropto.market.v1.GetCandlesResponse.candles:type_name -> cropto.market.v1.Candle
+	4, // 4: cropto.market.v1.GetCandlesResponse.indicators:type_name -> cropto.market.v1.Indicators
+	0, // 5: cropto.market.v1.MarketData.Subscribe:input_type -> cropto.market.v1.SubscribeRequest
+	6, // 6: cropto.market.v1.MarketData.GetCandles:input_type -> cropto.market.v1.GetCandlesRequest
+	5, // 7: cropto.market.v1.MarketData.Subscribe:output_type -> cropto.market.v1.MarketEvent
+	7, // 8: cropto.market.v1.MarketData.GetCandles:output_type -> cropto.market.v1.GetCandlesResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_server_rpc_marketpb_market_proto_init() }
+func file_server_rpc_marketpb_market_proto_init() {
+	if File_server_rpc_marketpb_market_proto != nil {
+		return
+	}
+	file_server_rpc_marketpb_market_proto_msgTypes[5].OneofWrappers = []any{
+		(*MarketEvent_Ticker)(nil),
+		(*MarketEvent_Trade)(nil),
+		(*MarketEvent_Candle)(nil),
+		(*MarketEvent_Json)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_server_rpc_marketpb_market_proto_rawDesc), len(file_server_rpc_marketpb_market_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_server_rpc_marketpb_market_proto_goTypes,
+		DependencyIndexes: file_server_rpc_marketpb_market_proto_depIdxs,
+		MessageInfos:      file_server_rpc_marketpb_market_proto_msgTypes,
+	}.Build()
+	File_server_rpc_marketpb_market_proto = out.File
+	file_server_rpc_marketpb_market_proto_goTypes = nil
+	file_server_rpc_marketpb_market_proto_depIdxs = nil
+}
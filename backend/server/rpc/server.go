@@ -0,0 +1,322 @@
+// Package rpc serves the market data stream over gRPC for backend consumers.
+// The generated code in marketpb is rebuilt with:
+//
+//	protoc --go_out=. --go_opt=paths=import --go-grpc_out=. --go-grpc_opt=paths=import server/rpc/marketpb/market.proto
+package rpc
+
+import (
+	"context"
+	"cropto-dashboard/auth"
+	"cropto-dashboard/decimal"
+	"cropto-dashboard/exchange"
+	"cropto-dashboard/ratelimit"
+	"cropto-dashboard/server/rpc/marketpb"
+	"cropto-dashboard/server/websocket"
+	"cropto-dashboard/types"
+	"encoding/json"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Server shares the hub with the HTTP server, each Subscribe call is a hub
+// listener like an SSE response.
+type Server struct {
+	marketpb.UnimplementedMarketDataServer
+
+	hub  *websocket.Hub
+	grpc *grpc.Server
+
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer requires authenticate to accept the caller's "authorization:
+// Bearer" or "x-api-key" metadata on every call, unless it is nil. limits,
+// when set, applies the HTTP API's quotas: GetCandles spends request and
+// upstream tokens, and each Subscribe holds a stream slot.
+func NewServer(hub *websocket.Hub, authenticate func(credential string) (*auth.Identity, error), limits *ratelimit.Limiter) *Server {
+	var opts []grpc.ServerOption
+	if authenticate != nil || limits != nil {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				ctx, err := authorize(ctx, authenticate)
+				if err != nil {
+					return nil, err
+				}
+				if limits != nil {
+					client, tier := caller(ctx)
+					classes := []string{ratelimit.ClassRequest}
+					if info.FullMethod == marketpb.MarketData_GetCandles_FullMethodName {
+						classes = append(classes, ratelimit.ClassUpstream)
+					}
+					for _, class := range classes {
+						if wait, ok := limits.Allow(client, tier, class); !ok {
+							return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", wait.Round(time.Second))
+						}
+					}
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				ctx, err := authorize(ss.Context(), authenticate)
+				if err != nil {
+					return err
+				}
+				if limits != nil {
+					client, tier := caller(ctx)
+					release, err := limits.AcquireSocket(client, tier)
+					if err != nil {
+						return status.Error(codes.ResourceExhausted, err.Error())
+					}
+					defer release()
+				}
+				return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+			}),
+		)
+	}
+
+	s := &Server{
+		hub:  hub,
+		grpc: grpc.NewServer(opts...),
+		quit: make(chan struct{}),
+	}
+	marketpb.RegisterMarketDataServer(s.grpc, s)
+	return s
+}
+
+// caller names the rate limit bucket like the HTTP API, by API key or by
+// the peer's IP address.
+func caller(ctx context.Context) (client, tier string) {
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return ratelimit.Client(auth.FromContext(ctx), ip)
+}
+
+func authorize(ctx context.Context, authenticate func(credential string) (*auth.Identity, error)) (context.Context, error) {
+	if authenticate == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var credential string
+	if v := md.Get("authorization"); len(v) > 0 {
+		if scheme, token, ok := strings.Cut(v[0], " "); ok && strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(token)
+		}
+	}
+	if v := md.Get("x-api-key"); credential == "" && len(v) > 0 {
+		credential = v[0]
+	}
+	if credential == "" {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	identity, err := authenticate(credential)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+// identityStream carries the authenticated context into stream handlers.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown ends open streams and waits for calls in flight, cutting them off
+// when ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) Subscribe(req *marketpb.SubscribeRequest, stream grpc.ServerStreamingServer[marketpb.MarketEvent]) error {
+	var sub *websocket.Subscription
+	if len(req.GetSymbols()) > 0 || len(req.GetEvents()) > 0 {
+		sub = websocket.NewSubscription(req.GetSymbols(), req.GetEvents(), "")
+	}
+
+	listener, _, _ := s.hub.Listen(sub, 0, false)
+	defer s.hub.Unlisten(listener)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.quit:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case ev, ok := <-listener.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell behind the stream")
+			}
+			out, err := toEvent(ev)
+			if err != nil {
+				log.Printf("Failed to convert stream event %d for gRPC: %v", ev.ID, err)
+				continue
+			}
+			if err := stream.Send(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) GetCandles(ctx context.Context, req *marketpb.GetCandlesRequest) (*marketpb.GetCandlesResponse, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(req.GetSymbol()))
+	if symbol == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol is required")
+	}
+	interval := req.GetInterval()
+	if interval == "" {
+		interval = "1h"
+	}
+	if exchange.IntervalDuration(interval) <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid interval %q", interval)
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = 100
+	}
+
+	data, err := exchange.GetHistoricalDataWithIndicators(symbol, interval, limit, req.GetIndicators())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	resp := &marketpb.GetCandlesResponse{
+		Symbol:   data.Symbol,
+		Interval: data.Interval,
+		Candles:  make([]*marketpb.Candle, len(data.Candlesticks)),
+	}
+	for i, c := range data.Candlesticks {
+		resp.Candles[i] = &marketpb.Candle{
+			Symbol:    data.Symbol,
+			Interval:  data.Interval,
+			OpenTime:  c.OpenTime,
+			Open:      c.Open.String(),
+			High:      c.High.String(),
+			Low:       c.Low.String(),
+			Close:     c.Close.String(),
+			Volume:    c.Volume.String(),
+			CloseTime: c.CloseTime,
+		}
+	}
+	if req.GetIndicators() {
+		ind := data.Indicators
+		resp.Indicators = &marketpb.Indicators{
+			Ma20:      ind.MA20,
+			Ma50:      ind.MA50,
+			Ma200:     ind.MA200,
+			Rsi:       ind.RSI,
+			Macd:      ind.MACD,
+			Signal:    ind.Signal,
+			Histogram: ind.Histogram,
+		}
+	}
+	return resp, nil
+}
+
+// toEvent types tickers, trades and candles and passes everything else on as JSON.
+func toEvent(ev websocket.Event) (*marketpb.MarketEvent, error) {
+	var head struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.Unmarshal(ev.Message, &head); err != nil {
+		return nil, err
+	}
+	out := &marketpb.MarketEvent{Id: ev.ID, EventType: head.EventType}
+
+	switch head.EventType {
+	case "ticker", "trade":
+		var msg types.TickerMessage
+		if err := json.Unmarshal(ev.Message, &msg); err != nil {
+			return nil, err
+		}
+		if head.EventType == "trade" {
+			out.Payload = &marketpb.MarketEvent_Trade{Trade: &marketpb.Trade{
+				Symbol:     msg.Symbol,
+				Price:      msg.Price.String(),
+				Quantity:   msg.Volume.String(),
+				TradeId:    msg.TradeID,
+				Timestamp:  msg.Timestamp,
+				Backfilled: msg.Backfilled,
+			}}
+			break
+		}
+		out.Payload = &marketpb.MarketEvent_Ticker{Ticker: &marketpb.Ticker{
+			Symbol:        msg.Symbol,
+			Price:         msg.Price.String(),
+			Change:        msg.Change.String(),
+			ChangePercent: msg.ChangePercent.String(),
+			Volume:        msg.Volume.String(),
+			QuoteVolume:   optional(msg.QuoteVolume),
+			High:          msg.High.String(),
+			Low:           msg.Low.String(),
+			Bid:           optional(msg.Bid),
+			Ask:           optional(msg.Ask),
+			Timestamp:     msg.Timestamp,
+		}}
+	case "candle":
+		var msg types.CandleMessage
+		if err := json.Unmarshal(ev.Message, &msg); err != nil {
+			return nil, err
+		}
+		out.Payload = &marketpb.MarketEvent_Candle{Candle: &marketpb.Candle{
+			Symbol:     msg.Symbol,
+			Interval:   msg.Interval,
+			OpenTime:   msg.OpenTime,
+			Open:       msg.Open.String(),
+			High:       msg.High.String(),
+			Low:        msg.Low.String(),
+			Close:      msg.Close.String(),
+			Volume:     msg.Volume.String(),
+			CloseTime:  msg.CloseTime,
+			Backfilled: msg.Backfilled,
+		}}
+	default:
+		out.Payload = &marketpb.MarketEvent_Json{Json: ev.Message}
+	}
+	return out, nil
+}
+
+// optional leaves fields the feed did not send empty instead of "0".
+func optional(d decimal.Decimal) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.String()
+}
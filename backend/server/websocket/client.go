@@ -1,6 +1,8 @@
 package websocket
 
 import (
+	"cropto-dashboard/auth"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -8,16 +10,29 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-const (
-	pingPeriod     = (pongWait * 9) / 10
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	maxMessageSize = 512
+const writeWait = 10 * time.Second
+
+// Connection limits, set before the hub starts.
+var (
+	// PongWait is how long a client may stay silent before it is dropped.
+	PongWait = 60 * time.Second
+	// MaxMessageSize caps a client command in bytes.
+	MaxMessageSize int64 = 512
+	// SendBuffer is how many messages a client may fall behind before it
+	// counts as slow and is disconnected.
+	SendBuffer = 256
 )
 
+func pingPeriod() time.Duration {
+	return PongWait * 9 / 10
+}
+
 var (
 	newline = []byte{'\n'}
 	space   = []byte{' '}
+
+	errInvalidCommand = errors.New("invalid command, expected {\"action\": \"...\"}")
+	errUnknownAction  = errors.New("unknown action")
 )
 
 type Conn struct {
@@ -30,16 +45,31 @@ var wsUpgrader = websocket.Upgrader{
 	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
+// SetCheckOrigin replaces the upgrader's origin check. It must be called
+// before serving.
+func SetCheckOrigin(fn func(r *http.Request) bool) {
+	wsUpgrader.CheckOrigin = fn
+}
+
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		if c.onClose != nil {
+			c.onClose()
+		}
+		c.mutex.RLock()
+		closers := c.closers
+		c.mutex.RUnlock()
+		for _, fn := range closers {
+			fn()
+		}
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(PongWait))
 		return nil
 	})
 
@@ -50,12 +80,12 @@ func (c *Client) readPump() {
 			break
 		}
 
-		log.Println("received message from client: ", message)
+		c.hub.dispatch(c, message)
 	}
 }
 
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(pingPeriod())
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -93,16 +123,24 @@ func (c *Client) writePump() {
 	}
 }
 
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// ServeWS upgrades the request and returns, the client runs until it
+// disconnects. onClose, which may be nil, is called when it does.
+func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, onClose func()) {
 	conn, err := wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("error while upgrading: ", err)
+		if onClose != nil {
+			onClose()
+		}
 		return
 	}
 	client := &Client{
-		hub:  hub,
-		conn: &Conn{conn},
-		send: make(chan []byte, 256),
+		hub:      hub,
+		conn:     &Conn{conn},
+		send:     make(chan []byte, SendBuffer),
+		channels: make(map[string]bool),
+		identity: auth.FromContext(r.Context()),
+		onClose:  onClose,
 	}
 	client.hub.register <- client
 	go client.writePump()
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOnCloseRunsAfterDisconnect(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	closed := make(chan struct{})
+	hub.HandleCommand("watch", func(c *Client, cmd Command) error {
+		c.OnClose(func() { close(closed) })
+		return nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWS(hub, w, r, nil)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"watch"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// The reply means the handler ran.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, reply, err := conn.ReadMessage(); err != nil || !strings.Contains(string(reply), `"ok":true`) {
+		t.Fatalf("reply %s, %v", reply, err)
+	}
+	select {
+	case <-closed:
+		t.Fatal("OnClose ran while the client was connected")
+	default:
+	}
+
+	conn.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnClose did not run after the disconnect")
+	}
+}
@@ -1,6 +1,9 @@
 package websocket
 
 import (
+	"cropto-dashboard/auth"
+	"cropto-dashboard/metrics"
+	"encoding/json"
 	"sync"
 )
 
@@ -8,23 +11,153 @@ type Client struct {
 	hub  *Hub
 	conn *Conn
 	send chan []byte
+
+	mutex    sync.RWMutex
+	channels map[string]bool
+	sub      *Subscription
+
+	// identity is who opened the socket, nil when auth is not required.
+	identity *auth.Identity
+	// onClose runs once the client has disconnected, then closers.
+	onClose func()
+	closers []func()
+}
+
+func (c *Client) Identity() *auth.Identity {
+	return c.identity
+}
+
+// Join subscribes the client to a private channel such as "paper:<account>".
+func (c *Client) Join(channel string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.channels[channel] = true
+}
+
+func (c *Client) Leave(channel string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.channels, channel)
+}
+
+// OnClose registers fn to run once the client has disconnected, for command
+// handlers that keep state per client.
+func (c *Client) OnClose(fn func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closers = append(c.closers, fn)
+}
+
+func (c *Client) inChannel(channel string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.channels[channel]
+}
+
+// Send queues a message for this client only, dropping it if the client is slow.
+func (c *Client) Send(message []byte) {
+	c.hub.direct <- directMessage{client: c, message: message}
+}
+
+// Command is a JSON message sent by a client, e.g. {"action":"paper.subscribe",...}.
+type Command struct {
+	Action string          `json:"action"`
+	Raw    json.RawMessage `json:"-"`
+}
+
+// CommandHandler handles one client action. A returned error is reported back to the client.
+type CommandHandler func(c *Client, cmd Command) error
+
+type directMessage struct {
+	client  *Client
+	channel string
+	message []byte
 }
 
 type Hub struct {
 	Clients    map[*Client]bool
 	broadcast  chan []byte
+	direct     chan directMessage
 	register   chan *Client
 	unregister chan *Client
 	mutex      sync.RWMutex
+
+	handlerMutex sync.RWMutex
+	handlers     map[string]CommandHandler
+
+	converter Converter
+
+	// seq numbers broadcasts for listeners resuming from the replay buffer.
+	seq       uint64
+	replay    replayBuffer
+	listeners map[*Listener]bool
 }
 
+// BroadcastBuffer is how many messages may queue for the hub loop before
+// Broadcast blocks.
+var BroadcastBuffer = 256
+
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan []byte, BroadcastBuffer),
+		direct:     make(chan directMessage, BroadcastBuffer),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		Clients:    make(map[*Client]bool),
+		handlers:   make(map[string]CommandHandler),
+		replay:     replayBuffer{events: make([]Event, DefaultReplaySize)},
+		listeners:  make(map[*Listener]bool),
+	}
+}
+
+// SetConverter sets how messages are re-quoted for clients that subscribed
+// with a quote currency. It must be called before Run.
+func (h *Hub) SetConverter(convert Converter) {
+	h.converter = convert
+}
+
+// HandleCommand registers the handler for a client action.
+func (h *Hub) HandleCommand(action string, handler CommandHandler) {
+	h.handlerMutex.Lock()
+	defer h.handlerMutex.Unlock()
+	h.handlers[action] = handler
+}
+
+func (h *Hub) dispatch(c *Client, data []byte) {
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil || cmd.Action == "" {
+		c.reply("", errInvalidCommand)
+		return
+	}
+	cmd.Raw = data
+
+	h.handlerMutex.RLock()
+	handler, ok := h.handlers[cmd.Action]
+	h.handlerMutex.RUnlock()
+
+	if !ok {
+		c.reply(cmd.Action, errUnknownAction)
+		return
+	}
+	c.reply(cmd.Action, handler(c, cmd))
+}
+
+func (c *Client) reply(action string, err error) {
+	resp := map[string]interface{}{
+		"eventType": "command",
+		"action":    action,
+		"ok":        err == nil,
 	}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	data, _ := json.Marshal(resp)
+	c.Send(data)
+}
+
+// SendTo delivers a message to every client that joined channel.
+func (h *Hub) SendTo(channel string, message []byte) {
+	h.direct <- directMessage{channel: channel, message: message}
 }
 
 func (h *Hub) Run() {
@@ -43,16 +176,47 @@ func (h *Hub) Run() {
 			}
 			h.mutex.Unlock()
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			h.mutex.Lock()
+			d := newDelivery(message, h.converter)
+			delivered := 0
 			for Client := range h.Clients {
+				out := d.forSubscription(Client.subscription())
+				if out == nil {
+					continue
+				}
 				select {
-				case Client.send <- message:
+				case Client.send <- out:
+					delivered++
 				default:
 					close(Client.send)
 					delete(h.Clients, Client)
+					metrics.Dropped.WithLabelValues(metrics.DropSlowClient).Inc()
 				}
 			}
-			h.mutex.RUnlock()
+			delivered += h.deliverLocked(d)
+			h.mutex.Unlock()
+			if delivered > 0 {
+				env := d.envelope()
+				metrics.MessagesOut.WithLabelValues(env.Symbol, env.EventType).Add(float64(delivered))
+			}
+		case dm := <-h.direct:
+			h.mutex.Lock()
+			for Client := range h.Clients {
+				if dm.client != nil && dm.client != Client {
+					continue
+				}
+				if dm.channel != "" && !Client.inChannel(dm.channel) {
+					continue
+				}
+				select {
+				case Client.send <- dm.message:
+				default:
+					close(Client.send)
+					delete(h.Clients, Client)
+					metrics.Dropped.WithLabelValues(metrics.DropSlowClient).Inc()
+				}
+			}
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"cropto-dashboard/metrics"
+	"fmt"
+)
+
+// DefaultReplaySize is how many recent broadcasts the hub keeps for resuming streams.
+const DefaultReplaySize = 1024
+
+// ListenerBuffer is how many events a listener may fall behind before the
+// hub drops it.
+var ListenerBuffer = 256
+
+// Event is a broadcast message with its position in the hub's stream.
+type Event struct {
+	ID      uint64
+	Message []byte
+}
+
+// Listener receives broadcasts outside of WebSocket clients, such as an SSE
+// response. C is closed when the hub drops a listener that fell behind.
+type Listener struct {
+	C   chan Event
+	sub *Subscription
+}
+
+// replayBuffer is a ring of the most recent broadcasts.
+type replayBuffer struct {
+	events []Event
+	next   int
+	count  int
+}
+
+func (b *replayBuffer) add(ev Event) {
+	if len(b.events) == 0 {
+		return
+	}
+	b.events[b.next] = ev
+	b.next = (b.next + 1) % len(b.events)
+	if b.count < len(b.events) {
+		b.count++
+	}
+}
+
+// since returns the buffered events after id, oldest first, and whether
+// events between id and the oldest buffered one were already dropped.
+func (b *replayBuffer) since(id uint64) ([]Event, bool) {
+	events := []Event{}
+	start := (b.next - b.count + len(b.events)) % max(len(b.events), 1)
+	for i := 0; i < b.count; i++ {
+		ev := b.events[(start+i)%len(b.events)]
+		if ev.ID > id {
+			events = append(events, ev)
+		}
+	}
+	missed := b.count > 0 && b.events[start].ID > id+1
+	return events, missed
+}
+
+// SetReplaySize sets how many broadcasts are kept for resuming, 0 for none.
+// It must be called before Run.
+func (h *Hub) SetReplaySize(n int) error {
+	if n < 0 {
+		return fmt.Errorf("replay size cannot be negative, got %d", n)
+	}
+	h.replay = replayBuffer{events: make([]Event, n)}
+	return nil
+}
+
+// Listen registers a listener for broadcasts matching sub (nil for all).
+// With resume set it also returns the matching buffered events after lastID,
+// and missed reports that some of them are no longer buffered. Registration
+// and the backlog are taken together, so nothing falls in between.
+func (h *Hub) Listen(sub *Subscription, lastID uint64, resume bool) (l *Listener, backlog []Event, missed bool) {
+	l = &Listener{C: make(chan Event, ListenerBuffer), sub: sub}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if resume {
+		var buffered []Event
+		buffered, missed = h.replay.since(lastID)
+		// An ID from before a restart is ahead of this hub's sequence.
+		if lastID > h.seq {
+			missed = true
+		}
+		for _, ev := range buffered {
+			if out := Filtered(sub, ev.Message, h.converter); out != nil {
+				backlog = append(backlog, Event{ID: ev.ID, Message: out})
+			}
+		}
+	}
+	h.listeners[l] = true
+	return l, backlog, missed
+}
+
+func (h *Hub) Unlisten(l *Listener) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.listeners[l] {
+		delete(h.listeners, l)
+		close(l.C)
+	}
+}
+
+func (h *Hub) ListenerCount() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.listeners)
+}
+
+// deliverLocked records a broadcast in the replay buffer and hands it to
+// every listener, returning how many received it.
+func (h *Hub) deliverLocked(d *delivery) int {
+	h.seq++
+	h.replay.add(Event{ID: h.seq, Message: d.message})
+
+	delivered := 0
+	for l := range h.listeners {
+		out := d.forSubscription(l.sub)
+		if out == nil {
+			continue
+		}
+		select {
+		case l.C <- Event{ID: h.seq, Message: out}:
+			delivered++
+		default:
+			delete(h.listeners, l)
+			close(l.C)
+			metrics.Dropped.WithLabelValues(metrics.DropSlowListener).Inc()
+		}
+	}
+	return delivered
+}
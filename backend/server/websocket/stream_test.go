@@ -0,0 +1,28 @@
+package websocket
+
+import "testing"
+
+func TestSetReplaySize(t *testing.T) {
+	h := NewHub()
+	if err := h.SetReplaySize(-1); err == nil {
+		t.Fatal("expected an error for a negative replay size")
+	}
+	if err := h.SetReplaySize(0); err != nil {
+		t.Fatal(err)
+	}
+	h.replay.add(Event{ID: 1})
+	if events, missed := h.replay.since(0); len(events) != 0 || missed {
+		t.Fatalf("replay disabled but got %d events, missed %v", len(events), missed)
+	}
+
+	if err := h.SetReplaySize(2); err != nil {
+		t.Fatal(err)
+	}
+	for id := uint64(1); id <= 3; id++ {
+		h.replay.add(Event{ID: id})
+	}
+	events, missed := h.replay.since(0)
+	if len(events) != 2 || events[0].ID != 2 || !missed {
+		t.Fatalf("got %v, missed %v; want events 2 and 3 with a gap", events, missed)
+	}
+}
@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Subscription narrows what a client receives from the shared stream. Empty
+// sets match everything and an empty Quote keeps the exchange's own prices.
+type Subscription struct {
+	Symbols map[string]bool
+	Events  map[string]bool
+	Quote   string
+}
+
+func NewSubscription(symbols, events []string, quote string) *Subscription {
+	s := &Subscription{
+		Symbols: make(map[string]bool),
+		Events:  make(map[string]bool),
+		Quote:   strings.ToUpper(strings.TrimSpace(quote)),
+	}
+	for _, symbol := range symbols {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			s.Symbols[symbol] = true
+		}
+	}
+	for _, event := range events {
+		if event = strings.TrimSpace(event); event != "" {
+			s.Events[event] = true
+		}
+	}
+	return s
+}
+
+// Match reports whether a message is wanted. Messages without a symbol, such
+// as market overviews, pass the symbol filter.
+func (s *Subscription) Match(symbol, eventType string) bool {
+	if len(s.Symbols) > 0 && symbol != "" && !s.Symbols[strings.ToUpper(symbol)] {
+		return false
+	}
+	if len(s.Events) > 0 && !s.Events[eventType] {
+		return false
+	}
+	return true
+}
+
+// Converter rewrites a stream message into another quote currency. Messages
+// without prices come back unchanged; an error means the prices could not be
+// converted.
+type Converter func(message []byte, quote string) ([]byte, error)
+
+// envelope is the part of a stream message the hub routes on.
+type envelope struct {
+	Symbol    string `json:"symbol"`
+	EventType string `json:"eventType"`
+}
+
+// delivery holds one stream message and the variants already produced for it,
+// so a broadcast parses and converts it at most once per quote.
+type delivery struct {
+	message   []byte
+	convert   Converter
+	env       *envelope
+	converted map[string][]byte
+}
+
+func newDelivery(message []byte, convert Converter) *delivery {
+	return &delivery{message: message, convert: convert}
+}
+
+func (d *delivery) envelope() *envelope {
+	if d.env == nil {
+		d.env = &envelope{}
+		json.Unmarshal(d.message, d.env)
+	}
+	return d.env
+}
+
+// forSubscription returns what a subscriber should receive, or nil to skip.
+// Messages that cannot be converted are skipped rather than delivered in the
+// wrong currency.
+func (d *delivery) forSubscription(sub *Subscription) []byte {
+	if sub == nil {
+		return d.message
+	}
+
+	if env := d.envelope(); !sub.Match(env.Symbol, env.EventType) {
+		return nil
+	}
+	if sub.Quote == "" || d.convert == nil {
+		return d.message
+	}
+
+	if d.converted == nil {
+		d.converted = make(map[string][]byte)
+	}
+	if out, ok := d.converted[sub.Quote]; ok {
+		return out
+	}
+	out, err := d.convert(d.message, sub.Quote)
+	if err != nil {
+		out = nil
+	}
+	d.converted[sub.Quote] = out
+	return out
+}
+
+// Filtered applies a subscription to a single message for transports outside the hub.
+func Filtered(sub *Subscription, message []byte, convert Converter) []byte {
+	return newDelivery(message, convert).forSubscription(sub)
+}
+
+func (c *Client) SetSubscription(sub *Subscription) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sub = sub
+}
+
+func (c *Client) subscription() *Subscription {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.sub
+}
@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubscriptionLetsSymbolLessEventsThrough(t *testing.T) {
+	sub := NewSubscription([]string{"btcusdt"}, nil, "")
+
+	if !sub.Match("BTCUSDT", "trade") {
+		t.Error("subscribed symbol should match")
+	}
+	if sub.Match("ETHUSDT", "trade") {
+		t.Error("other symbols should not match")
+	}
+	if !sub.Match("", "market.overview") {
+		t.Error("events without a symbol should pass the symbol filter")
+	}
+}
+
+func TestUnconvertedMessagesAreSkipped(t *testing.T) {
+	sub := NewSubscription(nil, nil, "EUR")
+	failing := func(message []byte, quote string) ([]byte, error) {
+		return nil, errors.New("no conversion path")
+	}
+	if out := Filtered(sub, []byte(`{"symbol":"BTCUSDT","eventType":"trade","price":"1"}`), failing); out != nil {
+		t.Fatalf("got %s, want the message skipped", out)
+	}
+
+	converting := func(message []byte, quote string) ([]byte, error) {
+		return []byte(`{"converted":true}`), nil
+	}
+	if out := Filtered(sub, []byte(`{"symbol":"BTCUSDT","eventType":"trade"}`), converting); string(out) != `{"converted":true}` {
+		t.Fatalf("got %s, want the converted message", out)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type JSONFile struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewJSONFile(path string) *JSONFile {
+	return &JSONFile{Path: path}
+}
+
+// Load decodes the file into v. A missing file is not an error and leaves v untouched.
+func (f *JSONFile) Load(v interface{}) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", f.Path, err)
+	}
+	return true, nil
+}
+
+// Save writes v to a temp file and renames it over the target so a crash never leaves a partial file.
+func (f *JSONFile) Save(v interface{}) error {
+	data, err := Encode(v)
+	if err != nil {
+		return err
+	}
+	return f.Write(data)
+}
+
+// Encode marshals v the way Save writes it.
+func Encode(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// Write saves already encoded data like Save.
+func (f *JSONFile) Write(data []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	return os.Rename(tmp, f.Path)
+}
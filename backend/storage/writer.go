@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Writer saves a JSONFile in the background. Owners call Mark after each
+// change; Delay later the writer takes one snapshot and writes it, so a
+// burst of changes costs one write and no caller waits on the disk.
+type Writer struct {
+	file     *JSONFile
+	delay    time.Duration
+	snapshot func() ([]byte, error)
+
+	dirty     chan struct{}
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWriter starts a writer for file. snapshot is called from the writer's
+// goroutine and must take whatever lock guards the state, encode it and
+// release the lock.
+func NewWriter(file *JSONFile, delay time.Duration, snapshot func() ([]byte, error)) *Writer {
+	w := &Writer{
+		file:     file,
+		delay:    delay,
+		snapshot: snapshot,
+		dirty:    make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Mark schedules a save. It never blocks.
+func (w *Writer) Mark() {
+	select {
+	case w.dirty <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.dirty:
+		case <-w.quit:
+			select {
+			case <-w.dirty:
+				w.save()
+			default:
+			}
+			return
+		}
+
+		select {
+		case <-time.After(w.delay):
+		case <-w.quit:
+		}
+		// Changes made before the snapshot are part of it.
+		select {
+		case <-w.dirty:
+		default:
+		}
+		w.save()
+
+		select {
+		case <-w.quit:
+			return
+		default:
+		}
+	}
+}
+
+func (w *Writer) save() {
+	data, err := w.snapshot()
+	if err == nil {
+		err = w.file.Write(data)
+	}
+	if err != nil {
+		log.Printf("Failed to save %s: %v", w.file.Path, err)
+	}
+}
+
+// Close writes any pending change and stops the writer.
+func (w *Writer) Close() {
+	w.closeOnce.Do(func() { close(w.quit) })
+	<-w.done
+}
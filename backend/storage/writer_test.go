@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriterCoalescesMarks(t *testing.T) {
+	file := NewJSONFile(filepath.Join(t.TempDir(), "state.json"))
+	var snapshots atomic.Int32
+	w := NewWriter(file, 50*time.Millisecond, func() ([]byte, error) {
+		snapshots.Add(1)
+		return Encode(map[string]int{"n": 1})
+	})
+
+	for i := 0; i < 100; i++ {
+		w.Mark()
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := snapshots.Load(); n != 1 {
+		t.Errorf("got %d writes for one burst, want 1", n)
+	}
+
+	w.Mark()
+	w.Close()
+	if n := snapshots.Load(); n != 2 {
+		t.Errorf("Close should write the pending change, got %d writes", n)
+	}
+
+	var saved map[string]int
+	if ok, err := file.Load(&saved); !ok || err != nil || saved["n"] != 1 {
+		t.Fatalf("saved file = %v, %v, %v", saved, ok, err)
+	}
+}
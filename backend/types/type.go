@@ -1,39 +1,87 @@
 package types
 
+import "cropto-dashboard/decimal"
+
+// Binance payloads reuse letters in both cases, "c" is the last price and
+// "C" the close time. encoding/json matches keys case-insensitively when a
+// struct has no exact match, so both cases need a field of their own.
 type BinanceTickerData struct {
-	EventType          string     `json:"e"`
-	EventTime          int64      `json:"E"`
-	Symbol             string     `json:"s"`
-	PriceChange        FlexString `json:"p"`
-	PriceChangePercent FlexString `json:"P"`
-	LastPrice          FlexString `json:"c"`
-	Volume             FlexString `json:"v"`
-	QuoteVolume        FlexString `json:"q"`
-	HighPrice          FlexString `json:"h"`
-	LowPrice           FlexString `json:"l"`
+	EventType          string          `json:"e"`
+	EventTime          int64           `json:"E"`
+	Symbol             string          `json:"s"`
+	PriceChange        decimal.Decimal `json:"p"`
+	PriceChangePercent decimal.Decimal `json:"P"`
+	OpenPrice          decimal.Decimal `json:"o"`
+	LastPrice          decimal.Decimal `json:"c"`
+	LastQuantity       decimal.Decimal `json:"Q"`
+	Volume             decimal.Decimal `json:"v"`
+	QuoteVolume        decimal.Decimal `json:"q"`
+	HighPrice          decimal.Decimal `json:"h"`
+	LowPrice           decimal.Decimal `json:"l"`
+	BidPrice           decimal.Decimal `json:"b"`
+	BidQuantity        decimal.Decimal `json:"B"`
+	AskPrice           decimal.Decimal `json:"a"`
+	AskQuantity        decimal.Decimal `json:"A"`
+	OpenTime           int64           `json:"O"`
+	CloseTime          int64           `json:"C"`
+	LastTradeID        int64           `json:"L"`
 }
 
 type BinanceTradeData struct {
-	EventType     string     `json:"e"`
-	EventTime     int64      `json:"E"`
-	Symbol        string     `json:"s"`
-	TradeID       int64      `json:"t"`
-	Price         FlexString `json:"p"`
-	Quantity      FlexString `json:"q"`
-	BuyerOrderID  int64      `json:"b"`
-	SellerOrderID int64      `json:"a"`
-	TradeTime     int64      `json:"T"`
-	IsBuyerMaker  bool       `json:"m"`
+	EventType     string          `json:"e"`
+	EventTime     int64           `json:"E"`
+	Symbol        string          `json:"s"`
+	TradeID       int64           `json:"t"`
+	Price         decimal.Decimal `json:"p"`
+	Quantity      decimal.Decimal `json:"q"`
+	BuyerOrderID  int64           `json:"b"`
+	SellerOrderID int64           `json:"a"`
+	TradeTime     int64           `json:"T"`
+	IsBuyerMaker  bool            `json:"m"`
+	Ignore        bool            `json:"M"`
 }
 
 type TickerMessage struct {
-	Symbol        string `json:"symbol"`
-	Price         string `json:"price"`
-	Change        string `json:"change"`
-	ChangePercent string `json:"changePercent"`
-	Volume        string `json:"volume"`
-	High          string `json:"high"`
-	Low           string `json:"low"`
-	Timestamp     int64  `json:"timestamp"`
-	EventType     string `json:"eventType"`
+	Symbol        string          `json:"symbol"`
+	Price         decimal.Decimal `json:"price"`
+	Change        decimal.Decimal `json:"change"`
+	ChangePercent decimal.Decimal `json:"changePercent"`
+	Volume        decimal.Decimal `json:"volume"`
+	QuoteVolume   decimal.Decimal `json:"quoteVolume,omitzero"`
+	High          decimal.Decimal `json:"high"`
+	Low           decimal.Decimal `json:"low"`
+	Bid           decimal.Decimal `json:"bid,omitzero"`
+	Ask           decimal.Decimal `json:"ask,omitzero"`
+	Timestamp     int64           `json:"timestamp"`
+	EventType     string          `json:"eventType"`
+	TradeID       int64           `json:"tradeId,omitempty"`
+	Backfilled    bool            `json:"backfilled,omitempty"`
+	// Quote is set when prices were converted out of the symbol's own quote asset.
+	Quote string `json:"quote,omitempty"`
+}
+
+type CandleMessage struct {
+	Symbol     string          `json:"symbol"`
+	Interval   string          `json:"interval"`
+	OpenTime   int64           `json:"openTime"`
+	Open       decimal.Decimal `json:"open"`
+	High       decimal.Decimal `json:"high"`
+	Low        decimal.Decimal `json:"low"`
+	Close      decimal.Decimal `json:"close"`
+	Volume     decimal.Decimal `json:"volume"`
+	CloseTime  int64           `json:"closeTime"`
+	EventType  string          `json:"eventType"`
+	Backfilled bool            `json:"backfilled,omitempty"`
+	Quote      string          `json:"quote,omitempty"`
+}
+
+// QuoteMessage is a top of book update from a venue other than Binance.
+type QuoteMessage struct {
+	Venue     string          `json:"venue"`
+	Symbol    string          `json:"symbol"`
+	Price     decimal.Decimal `json:"price"`
+	Bid       decimal.Decimal `json:"bid"`
+	Ask       decimal.Decimal `json:"ask"`
+	Timestamp int64           `json:"timestamp"`
+	EventType string          `json:"eventType"`
 }